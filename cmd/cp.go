@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+
+	ecs "github.com/justmiles/ecs-cli/lib"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func init() {
+	log.SetFlags(0)
+
+	rootCmd.AddCommand(cpCmd)
+	cpCmd.PersistentFlags().StringVarP(&execInput.Cluster, "cluster", "c", "", "ECS cluster")
+	cpCmd.PersistentFlags().StringVarP(&execInput.Service, "service", "s", "", "ECS service")
+	cpCmd.PersistentFlags().StringVar(&execInput.Container, "container", "", "ECS container")
+}
+
+var cpCmd = &cobra.Command{
+	Use:   "cp SRC DEST",
+	Short: "Copy files between a running container and the local filesystem",
+	Long: `Copy files between a running container and the local filesystem.
+
+A container path is written [[CLUSTER/]TASK[:CONTAINER]]:/PATH. Omitted parts are prompted
+for like ecs exec, eg ":/tmp/heap.hprof". A path starting with / or . is always local.
+The container must provide sh, tar and base64.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		src, srcRemote, err := ecs.ParseCopyTarget(args[0])
+		check(err)
+		dest, destRemote, err := ecs.ParseCopyTarget(args[1])
+		check(err)
+
+		if srcRemote == destRemote {
+			log.Fatal("Please pass exactly one container path, eg qa/0123456789:app:/tmp/report.csv")
+		}
+
+		remote := src
+		if destRemote {
+			remote = dest
+		}
+
+		if remote.Cluster != "" {
+			execInput.Cluster = remote.Cluster
+		}
+		if remote.Task != "" {
+			execInput.Task = remote.Task
+		}
+		if remote.Container != "" {
+			execInput.Container = remote.Container
+		}
+
+		client := newClient("")
+		promptCluster(client)
+		promptService(client)
+		promptTask(client)
+		promptContainer(client)
+
+		// report progress while attached to a terminal
+		if term.IsTerminal(int(os.Stderr.Fd())) {
+			execInput.Progress = os.Stderr
+		}
+
+		if srcRemote {
+			check(client.CopyFromContainer(context.Background(), &execInput, src.Path, args[1]))
+		} else {
+			check(client.CopyToContainer(context.Background(), &execInput, args[0], dest.Path))
+		}
+	},
+}
@@ -1,9 +1,12 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/AlecAivazis/survey/v2"
 	ecs "github.com/justmiles/ecs-cli/lib"
@@ -11,7 +14,11 @@ import (
 )
 
 var (
-	execInput ecs.ExecInput
+	execInput       ecs.ExecInput
+	execAllTasks    bool
+	execCapture     bool
+	execConcurrency int
+	execFormat      string
 )
 
 func init() {
@@ -24,19 +31,63 @@ func init() {
 	ExecCmd.PersistentFlags().StringVar(&execInput.Container, "container", "", "ECS container")
 	ExecCmd.PersistentFlags().StringVar(&execInput.Command, "cmd", "", "ECS container")
 	ExecCmd.PersistentFlags().BoolVarP(&execInput.Interactive, "interactive", "i", true, "open interative session")
+	ExecCmd.PersistentFlags().BoolVar(&execCapture, "non-interactive", false, "run the command without a terminal, capture its output and exit with its exit code")
+	ExecCmd.PersistentFlags().BoolVar(&execAllTasks, "all-tasks", false, "run the command in every running task of the service (requires --non-interactive)")
+	ExecCmd.PersistentFlags().IntVar(&execConcurrency, "concurrency", 5, "number of tasks to run the command in at once with --all-tasks")
+	ExecCmd.PersistentFlags().StringVar(&execFormat, "format", "text", "output format for --non-interactive, text or json")
 }
 
 var ExecCmd = &cobra.Command{
 	Use:   "exec",
 	Short: "Start and interactive prompt to select and esc-exec into a running container.",
 	Run: func(cmd *cobra.Command, args []string) {
-		promptCluster()
-		promptService()
-		promptTask()
-		promptContainer()
+		if execAllTasks && !execCapture {
+			log.Fatal("--all-tasks requires --non-interactive")
+		}
+		if execFormat != "text" && execFormat != "json" {
+			log.Fatalf("unsupported format %s, expected text or json", execFormat)
+		}
+
+		client := newClient("")
+		ctx := context.Background()
+
+		promptCluster(client)
+		promptService(client)
+
+		if execAllTasks {
+			tasks, err := client.GetRunningTasks(ctx, execInput.Cluster, execInput.Service)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			if len(tasks) == 0 {
+				log.Fatalf("No running tasks found for service %s", execInput.Service)
+			}
+
+			// tasks of a service share their containers, choose from the first one
+			execInput.Task = tasks[0]
+			promptContainer(client)
+			execInput.Task = ""
+			promptCommand()
+
+			results := client.CaptureCommandAll(ctx, &execInput, tasks, execConcurrency)
+			os.Exit(printExecResults(results))
+		}
+
+		promptTask(client)
+		promptContainer(client)
 		promptCommand()
 
-		err := ecs.ExecuteCommand(&execInput)
+		if execCapture {
+			result := client.CaptureCommand(ctx, &execInput)
+			printExecResults([]*ecs.ExecResult{result})
+			if result.Error != "" {
+				os.Exit(1)
+			}
+			os.Exit(result.ExitCode)
+		}
+
+		err := client.ExecuteCommand(ctx, &execInput)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -44,9 +95,43 @@ var ExecCmd = &cobra.Command{
 	},
 }
 
-func promptCluster() {
+// printExecResults prints captured output grouped by task and returns the exit code for the CLI
+func printExecResults(results []*ecs.ExecResult) int {
+	exitCode := 0
+	for _, result := range results {
+		if result.Failed() {
+			exitCode = 1
+		}
+	}
+
+	if execFormat == "json" {
+		b, err := json.MarshalIndent(results, "", "  ")
+		check(err)
+		fmt.Println(string(b))
+		return exitCode
+	}
+
+	for i, result := range results {
+		if len(results) > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("==> %s (%s) exit code %d <==\n", result.Task, result.Container, result.ExitCode)
+		}
+		fmt.Print(result.Output)
+		if result.Output != "" && !strings.HasSuffix(result.Output, "\n") {
+			fmt.Println()
+		}
+		if result.Error != "" {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", result.Task, result.Error)
+		}
+	}
+	return exitCode
+}
+
+func promptCluster(client *ecs.Client) {
 	if execInput.Cluster == "" {
-		clusters, err := ecs.GetClusters()
+		clusters, err := client.GetClusters(context.Background())
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -65,10 +150,10 @@ func promptCluster() {
 	}
 }
 
-func promptService() {
+func promptService(client *ecs.Client) {
 	if execInput.Service == "" && execInput.Task == "" {
 
-		services, err := ecs.GetServices(execInput.Cluster)
+		services, err := client.GetServices(context.Background(), execInput.Cluster)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -87,9 +172,9 @@ func promptService() {
 	}
 }
 
-func promptTask() {
+func promptTask(client *ecs.Client) {
 	if execInput.Task == "" {
-		tasks, err := ecs.GetRunningTasks(execInput.Cluster, execInput.Service)
+		tasks, err := client.GetRunningTasks(context.Background(), execInput.Cluster, execInput.Service)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -112,9 +197,9 @@ func promptTask() {
 	}
 }
 
-func promptContainer() {
+func promptContainer(client *ecs.Client) {
 	if execInput.Container == "" {
-		containers, err := ecs.GetContainers(execInput.Cluster, execInput.Task)
+		containers, err := client.GetContainers(context.Background(), execInput.Cluster, execInput.Task)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"time"
+
+	ecs "github.com/justmiles/ecs-cli/lib"
+	"github.com/spf13/cobra"
+)
+
+var (
+	logsInput ecs.LogsInput
+	logsSince string
+	logsUntil string
+)
+
+func init() {
+	log.SetFlags(0)
+
+	rootCmd.AddCommand(logsCmd)
+	logsCmd.PersistentFlags().StringVarP(&logsInput.Cluster, "cluster", "c", "", "ECS cluster")
+	logsCmd.PersistentFlags().StringVarP(&logsInput.Task, "task", "t", "", "Show logs of this task")
+	logsCmd.PersistentFlags().StringVarP(&logsInput.Service, "service", "s", "", "Show logs of the tasks of this service")
+	logsCmd.PersistentFlags().StringVar(&logsInput.Family, "family", "", "Show logs of the tasks of the latest ACTIVE revision of this task definition family")
+	logsCmd.PersistentFlags().StringVar(&logsInput.Container, "container", "", "Only show logs of this container")
+	logsCmd.PersistentFlags().StringVar(&logsInput.FilterPattern, "filter-pattern", "", "CloudWatch Logs filter pattern to match events against")
+	logsCmd.PersistentFlags().StringVar(&logsSince, "since", "", "Show logs since a timestamp (eg 2006-01-02T15:04:05Z) or relative duration (eg 1h)")
+	logsCmd.PersistentFlags().StringVar(&logsUntil, "until", "", "Show logs before a timestamp (eg 2006-01-02T15:04:05Z) or relative duration (eg 10m)")
+	logsCmd.PersistentFlags().IntVarP(&logsInput.Tail, "tail", "n", 0, "Number of lines to show from the end of the logs")
+	logsCmd.PersistentFlags().BoolVarP(&logsInput.Follow, "follow", "f", false, "Follow log output")
+}
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Fetch the logs of an existing task, service or task definition family",
+	Run: func(cmd *cobra.Command, args []string) {
+		if logsInput.Cluster == "" && logsInput.Family == "" {
+			log.Fatal("Please pass a cluster")
+		}
+
+		selected := 0
+		for _, s := range []string{logsInput.Task, logsInput.Service, logsInput.Family} {
+			if s != "" {
+				selected++
+			}
+		}
+		if selected != 1 {
+			log.Fatal("Please pass exactly one of --task, --service or --family")
+		}
+
+		var err error
+		now := time.Now()
+		logsInput.Since, err = ecs.ParseTimeArg(logsSince, now)
+		check(err)
+		logsInput.Until, err = ecs.ParseTimeArg(logsUntil, now)
+		check(err)
+
+		check(newClient("").Logs(context.Background(), &logsInput))
+	},
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+
+	ecs "github.com/justmiles/ecs-cli/lib"
+	"github.com/spf13/cobra"
+)
+
+var (
+	portForwardInput ecs.PortForwardInput
+)
+
+func init() {
+	log.SetFlags(0)
+
+	rootCmd.AddCommand(portForwardCmd)
+	portForwardCmd.PersistentFlags().StringVarP(&execInput.Cluster, "cluster", "c", "", "ECS cluster")
+	portForwardCmd.PersistentFlags().StringVarP(&execInput.Service, "service", "s", "", "ECS service")
+	portForwardCmd.PersistentFlags().StringVarP(&execInput.Task, "task", "t", "", "ECS task")
+	portForwardCmd.PersistentFlags().StringVar(&execInput.Container, "container", "", "ECS container")
+	portForwardCmd.PersistentFlags().StringVar(&portForwardInput.Host, "host", "", "forward to a host reachable from the task (eg an RDS endpoint) instead of the container")
+	portForwardCmd.PersistentFlags().StringVar(&portForwardInput.LocalAddress, "address", "localhost", "local address to listen on")
+}
+
+var portForwardCmd = &cobra.Command{
+	Use:   "port-forward [LOCAL_PORT:]REMOTE_PORT",
+	Short: "Forward a local port to a running container, or a host reachable from it, through SSM",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		portForwardInput.LocalPort, portForwardInput.RemotePort, err = ecs.ParsePortMapping(args[0])
+		check(err)
+
+		client := newClient("")
+		promptCluster(client)
+		promptService(client)
+		promptTask(client)
+		promptContainer(client)
+
+		portForwardInput.Cluster = execInput.Cluster
+		portForwardInput.Task = execInput.Task
+		portForwardInput.Container = execInput.Container
+
+		// forward until ^C
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		check(client.PortForward(ctx, &portForwardInput))
+	},
+}
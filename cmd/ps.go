@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	ecs "github.com/justmiles/ecs-cli/lib"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	psInput   ecs.ListTasksInput
+	psFormat  string
+	lsCluster string
+	lsFormat  string
+)
+
+func init() {
+	log.SetFlags(0)
+
+	rootCmd.AddCommand(psCmd)
+	psCmd.PersistentFlags().StringVarP(&psInput.Cluster, "cluster", "c", "", "ECS cluster, all clusters when omitted")
+	psCmd.PersistentFlags().StringVarP(&psInput.Service, "service", "s", "", "Only list tasks of this service")
+	psCmd.PersistentFlags().StringVar(&psInput.Family, "family", "", "Only list tasks of this task definition family")
+	psCmd.PersistentFlags().StringVar(&psInput.Status, "status", "RUNNING", "Desired status of the tasks to list, RUNNING, PENDING or STOPPED")
+	psCmd.PersistentFlags().StringVar(&psInput.StartedBy, "started-by", "", "Only list tasks started by this value. ECS only lists running tasks by this filter")
+	psCmd.PersistentFlags().StringVar(&psFormat, "format", "table", "output format, table, json or yaml")
+
+	rootCmd.AddCommand(lsCmd)
+	lsCmd.PersistentFlags().StringVarP(&lsCluster, "cluster", "c", "", "List the services of this cluster instead of the clusters")
+	lsCmd.PersistentFlags().StringVar(&lsFormat, "format", "table", "output format, table, json or yaml")
+}
+
+var psCmd = &cobra.Command{
+	Use:   "ps",
+	Short: "List tasks",
+	Run: func(cmd *cobra.Command, args []string) {
+		checkListFormat(psFormat)
+		if psInput.Service != "" && psInput.Cluster == "" {
+			log.Fatal("Please pass the cluster of the service")
+		}
+
+		tasks, err := newClient("").ListTasks(context.Background(), &psInput)
+		check(err)
+
+		if psFormat != "table" {
+			printStructured(psFormat, tasks)
+			return
+		}
+
+		now := time.Now()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TASK ID\tCLUSTER\tTASK DEFINITION\tSTATUS\tLAUNCH TYPE\tCAPACITY PROVIDER\tSTARTED\tUPTIME\tIP\tAZ\tHEALTH\tSTARTED BY")
+		for _, t := range tasks {
+			status := t.LastStatus
+			if t.DesiredStatus != t.LastStatus {
+				status += " -> " + t.DesiredStatus
+			}
+
+			started, uptime := "-", "-"
+			if t.StartedAt != nil {
+				started = humanize.Time(*t.StartedAt)
+				uptime = t.Uptime(now).String()
+			}
+
+			ips := append([]string{}, t.PrivateIPs...)
+			if t.PublicIP != "" {
+				ips = append(ips, t.PublicIP)
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				t.TaskID, t.Cluster, t.TaskDefinition, status, orDash(t.LaunchType), orDash(t.CapacityProvider),
+				started, uptime, orDash(strings.Join(ips, ",")), orDash(t.AvailabilityZone), orDash(t.HealthStatus), orDash(t.StartedBy))
+		}
+		w.Flush()
+	},
+}
+
+var lsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List clusters, or the services of a cluster",
+	Run: func(cmd *cobra.Command, args []string) {
+		checkListFormat(lsFormat)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+		if lsCluster == "" {
+			clusters, err := newClient("").ListClusters(context.Background())
+			check(err)
+
+			if lsFormat != "table" {
+				printStructured(lsFormat, clusters)
+				return
+			}
+
+			fmt.Fprintln(w, "CLUSTER\tSTATUS\tSERVICES\tRUNNING\tPENDING\tCAPACITY PROVIDERS")
+			for _, c := range clusters {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\n",
+					c.Name, c.Status, c.ActiveServices, c.RunningTasks, c.PendingTasks, orDash(strings.Join(c.CapacityProviders, ",")))
+			}
+			w.Flush()
+			return
+		}
+
+		services, err := newClient("").ListServices(context.Background(), lsCluster)
+		check(err)
+
+		if lsFormat != "table" {
+			printStructured(lsFormat, services)
+			return
+		}
+
+		fmt.Fprintln(w, "SERVICE\tSTATUS\tDESIRED\tRUNNING\tPENDING\tLAUNCH TYPE\tCAPACITY PROVIDER\tTASK DEFINITION")
+		for _, s := range services {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\n",
+				s.Name, s.Status, s.DesiredCount, s.RunningCount, s.PendingCount, orDash(s.LaunchType), orDash(s.CapacityProvider), s.TaskDefinition)
+		}
+		w.Flush()
+	},
+}
+
+func checkListFormat(format string) {
+	if format != "table" && format != "json" && format != "yaml" {
+		log.Fatalf("unsupported format %s, expected table, json or yaml", format)
+	}
+}
+
+// printStructured prints v as json or yaml
+func printStructured(format string, v interface{}) {
+	if format == "yaml" {
+		b, err := yaml.Marshal(v)
+		check(err)
+		fmt.Print(string(b))
+		return
+	}
+
+	b, err := json.MarshalIndent(v, "", "  ")
+	check(err)
+	fmt.Println(string(b))
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
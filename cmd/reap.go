@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	ecs "github.com/justmiles/ecs-cli/lib"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reapInput  ecs.ReapInput
+	reapFormat string
+)
+
+func init() {
+	log.SetFlags(0)
+
+	rootCmd.AddCommand(reapCmd)
+	reapCmd.PersistentFlags().StringVarP(&reapInput.Cluster, "cluster", "c", "", "ECS cluster, all clusters when omitted")
+	reapCmd.PersistentFlags().BoolVar(&reapInput.DryRun, "dry-run", false, "List the overdue tasks without stopping them")
+	reapCmd.PersistentFlags().StringVar(&reapFormat, "format", "table", "output format, table, json or yaml")
+}
+
+var reapCmd = &cobra.Command{
+	Use:   "reap",
+	Short: "Stop tasks running past the deadline set by --timeout",
+	Long:  "Stop running tasks whose " + ecs.DeadlineTag + " tag has passed. Run it on a schedule to enforce the --timeout of detached tasks.",
+	Run: func(cmd *cobra.Command, args []string) {
+		checkListFormat(reapFormat)
+
+		reaped, err := newClient("").Reap(context.Background(), &reapInput)
+		check(err)
+
+		if reapFormat != "table" {
+			printStructured(reapFormat, reaped)
+			return
+		}
+
+		now := time.Now()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TASK ID\tCLUSTER\tDEADLINE\tOVERDUE")
+		for _, t := range reaped {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", t.TaskID, t.Cluster, t.Deadline.Format(time.RFC3339), now.Sub(t.Deadline).Truncate(time.Second))
+		}
+		w.Flush()
+	},
+}
@@ -2,19 +2,42 @@ package cmd
 
 import (
 	"log"
+	"os"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	ecs "github.com/justmiles/ecs-cli/lib"
 	"github.com/spf13/cobra"
 )
 
 var (
-	cluster string
+	cluster   string
+	output    string
+	renderer  ecs.Renderer
+	endpoints ecs.Endpoints
 )
 
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", "text", "how task lifecycle events are written: text, or json/ndjson for one JSON event per line on stdout")
+	rootCmd.PersistentFlags().StringVar(&endpoints.URL, "endpoint-url", "", "send every AWS request to this URL, eg http://localhost:4566 for LocalStack (default $AWS_ENDPOINT_URL)")
+	rootCmd.PersistentFlags().StringVar(&endpoints.ECS, "ecs-endpoint", "", "ECS endpoint URL (default $AWS_ENDPOINT_URL_ECS)")
+	rootCmd.PersistentFlags().StringVar(&endpoints.Logs, "logs-endpoint", "", "CloudWatch Logs endpoint URL (default $AWS_ENDPOINT_URL_CLOUDWATCH_LOGS)")
+	rootCmd.PersistentFlags().StringVar(&endpoints.EC2, "ec2-endpoint", "", "EC2 endpoint URL (default $AWS_ENDPOINT_URL_EC2)")
+	rootCmd.PersistentFlags().StringVar(&endpoints.SSM, "ssm-endpoint", "", "SSM endpoint URL (default $AWS_ENDPOINT_URL_SSM)")
+}
+
 // Configure the root command
 var rootCmd = &cobra.Command{
 	Use:   "ecs",
 	Short: "Manage ECS",
 	Long:  `A lightweight tool for working with ECS`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		var err error
+		renderer, err = ecs.NewRenderer(output)
+		check(err)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		cmd.Help()
 	},
@@ -28,6 +51,29 @@ func Execute(version string) {
 	}
 }
 
+// newClient builds a client from the shared AWS config and endpoint flags, assuming roleArn when set
+func newClient(roleArn string) *ecs.Client {
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+
+	resolved, err := ecs.ResolveEndpoints(endpoints, os.LookupEnv)
+	check(err)
+
+	awsConfig := aws.NewConfig()
+	if roleArn != "" {
+		stsConfig := aws.NewConfig()
+		if resolved.STS != "" {
+			stsConfig.Endpoint = aws.String(resolved.STS)
+		}
+		awsConfig.Credentials = stscreds.NewCredentialsWithClient(sts.New(sess, stsConfig), roleArn)
+	}
+
+	client := ecs.NewSessionClient(sess, resolved, awsConfig)
+	client.Events = renderer
+	return client
+}
+
 // Log errors if exist and exit
 func check(err error) {
 	if err != nil {
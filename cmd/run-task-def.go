@@ -1,76 +1,85 @@
 package cmd
 
 import (
+	"context"
 	"log"
-	"os"
-	"os/signal"
 
-	// "os"
-	// "os/signal"
-	// "strings"
-	// "sync"
-	// ecs "github.com/justmiles/ecs-cli/lib"
+	ecs "github.com/justmiles/ecs-cli/lib"
 	"github.com/spf13/cobra"
 )
 
+// taskDefTask is kept apart from the run command's task so the defaults of one command's
+// flags don't leak into the other's overrides
+var taskDefTask ecs.Task
+
 func init() {
 	log.SetFlags(0)
 	rootCmd.AddCommand(runTaskDefCmd)
-	runTaskDefCmd.PersistentFlags().StringVarP(&task.Cluster, "cluster", "", "default", "ECS cluster")
-	runTaskDefCmd.PersistentFlags().StringVarP(&task.Family, "family", "", "", "The family name of the task definition. The latest ACTIVE revision is used.")
-	runTaskDefCmd.PersistentFlags().StringVarP(&task.ImageVersion, "image-version", "", "", "Optionally pass in an image-version to override the current image version.")
-	runTaskDefCmd.PersistentFlags().StringVarP(&task.Name, "name", "n", "ephemeral-task-from-ecs-cli", "Assign a name to the task")
+	runTaskDefCmd.PersistentFlags().StringVarP(&taskDefTask.Cluster, "cluster", "", "default", "ECS cluster")
+	runTaskDefCmd.PersistentFlags().StringVarP(&taskDefTask.Family, "family", "", "", "The family name of the task definition. The latest ACTIVE revision is used.")
+	runTaskDefCmd.PersistentFlags().StringVar(&taskDefTask.LikeService, "like-service", "", "Run like this service, inheriting its task definition, network configuration, launch type or capacity provider strategy, platform version and placement constraints")
+	runTaskDefCmd.PersistentFlags().StringVar(&taskDefTask.PlatformVersion, "platform-version", "", "Fargate platform version")
+	runTaskDefCmd.PersistentFlags().StringVarP(&taskDefTask.ImageVersion, "image-version", "", "", "Optionally pass in an image-version to override the current image version. Registers a new revision.")
+	runTaskDefCmd.PersistentFlags().StringVarP(&taskDefTask.Name, "name", "n", "ephemeral-task-from-ecs-cli", "Assign a name to the task")
 	// TODO: attach a specific security group
-	runTaskDefCmd.PersistentFlags().StringArrayVar(&task.SecurityGroups, "security-groups", nil, "attach security groups to task")
-	runTaskDefCmd.PersistentFlags().StringArrayVar(&task.SubnetFilters, "subnet-filter", nil, "'Key=Value' filters for your subnet, eg tag:Name=private")
+	runTaskDefCmd.PersistentFlags().StringArrayVar(&taskDefTask.SecurityGroups, "security-groups", nil, "attach security groups to task")
+	runTaskDefCmd.PersistentFlags().StringArrayVar(&taskDefTask.SubnetFilters, "subnet-filter", nil, "'Key=Value' filters for your subnet, eg tag:Name=private")
 	// TODO: support assigning public ip address
-	runTaskDefCmd.PersistentFlags().BoolVar(&task.Public, "public", false, "assign public ip")
-	runTaskDefCmd.PersistentFlags().BoolVar(&task.Wait, "wait", false, "wait for container to finish")
-	runTaskDefCmd.PersistentFlags().BoolVarP(&task.Detach, "detach", "d", false, "Run the task in the background")
-	runTaskDefCmd.PersistentFlags().BoolVar(&task.Deregister, "deregister", false, "deregister the task definition after completion")
+	runTaskDefCmd.PersistentFlags().BoolVar(&taskDefTask.Public, "public", false, "assign public ip")
+	runTaskDefCmd.PersistentFlags().StringVar(&taskDefTask.CapacityProvider, "capacity-provider", "", "Capacity provider strategy as NAME[:WEIGHT[:BASE]],... (eg FARGATE_SPOT:3:1,FARGATE:1)")
+	runTaskDefCmd.PersistentFlags().BoolVar(&taskDefTask.UseClusterDefaultCapacity, "use-cluster-default-capacity", false, "Use the cluster's default capacity provider strategy instead of a launch type")
+	runTaskDefCmd.PersistentFlags().BoolVar(&taskDefTask.Wait, "wait", false, "wait for container to finish")
+	runTaskDefCmd.PersistentFlags().BoolVarP(&taskDefTask.Detach, "detach", "d", false, "Run the task in the background")
+	runTaskDefCmd.PersistentFlags().StringVar(&taskDefTask.ExitCodeFrom, "exit-code-from", "", "Exit with the exit code of this container instead of the main container")
+	runTaskDefCmd.PersistentFlags().StringVar(&taskDefTask.ExitPolicy, "exit-policy", "any-failure", "How the exit codes of --count tasks are combined: any-failure, all-failure, first or max")
+	runTaskDefCmd.PersistentFlags().BoolVar(&stopOnExit, "stop-on-exit", true, "Stop the task on SIGINT or SIGTERM. With false the CLI exits and leaves the task running")
+	runTaskDefCmd.PersistentFlags().StringVar(&taskDefTask.Timeout, "timeout", "", "Stop the task after this long (eg 30m) and exit with 124. Detached tasks are tagged with their deadline for ecs reap")
+	runTaskDefCmd.PersistentFlags().BoolVar(&taskDefTask.Deregister, "deregister", false, "deregister the task definition after completion")
+
+	// overrides, the task definition itself is left untouched
+	runTaskDefCmd.PersistentFlags().StringVar(&taskDefTask.Container, "container", "", "Container to override, defaults to the main container")
+	runTaskDefCmd.PersistentFlags().StringArrayVarP(&taskDefTask.Environment, "env", "e", nil, "Set environment variables")
+	runTaskDefCmd.PersistentFlags().StringArrayVar(&taskDefTask.EnvironmentFiles, "env-file", nil, "Read environment variables from a local dotenv file, or have the agent read them from s3://bucket/key")
+	runTaskDefCmd.PersistentFlags().Int64Var(&taskDefTask.CPUReservation, "cpu-reservation", 0, "Override the container's CPU reservation")
+	runTaskDefCmd.PersistentFlags().Int64VarP(&taskDefTask.Memory, "memory", "m", 0, "Override the container's memory limit")
+	runTaskDefCmd.PersistentFlags().Int64Var(&taskDefTask.MemoryReservation, "memory-reservation", 0, "Override the container's memory reservation")
+	runTaskDefCmd.PersistentFlags().StringVar(&taskDefTask.TaskCPU, "task-cpu", "", "Override the task's CPU, eg 1024 or '1 vCPU'")
+	runTaskDefCmd.PersistentFlags().StringVar(&taskDefTask.TaskMemory, "task-memory", "", "Override the task's memory, eg 2048 or '2 GB'")
+	runTaskDefCmd.PersistentFlags().StringVar(&taskDefTask.TaskRoleArn, "role", "", "Override the task role ARN")
+	runTaskDefCmd.PersistentFlags().StringVar(&taskDefTask.ExecutionRoleArn, "execution-role", "", "Override the execution role ARN")
 
-	runTaskDefCmd.PersistentFlags().BoolVar(&task.Debug, "debug", false, "Verbose logging")
+	runTaskDefCmd.PersistentFlags().BoolVar(&taskDefTask.Debug, "debug", false, "Verbose logging")
 
-	runTaskDefCmd.PersistentFlags().StringVar(&task.CLIRoleArn, "cli-role", "", "An IAM role ARN to assume before creating/executing a task")
+	runTaskDefCmd.PersistentFlags().StringVar(&taskDefTask.CLIRoleArn, "cli-role", "", "An IAM role ARN to assume before creating/executing a task")
 
-	runTaskDefCmd.PersistentFlags().Int64VarP(&task.Count, "count", "c", 1, "Spawn n tasks")
+	runTaskDefCmd.PersistentFlags().Int64VarP(&taskDefTask.Count, "count", "c", 1, "Spawn n tasks")
 	runTaskDefCmd.Flags().SetInterspersed(false)
 }
 
 // process the list command
 var runTaskDefCmd = &cobra.Command{
-	Use:   "run-task-def",
+	Use:   "run-task-def [flags] [-- COMMAND [ARG...]]",
 	Short: "Run a task from an existing task definition",
 	Run: func(cmd *cobra.Command, args []string) {
-		task.Fargate = true
+		taskDefTask.PublicSet = cmd.Flags().Changed("public")
 
-		if len(task.SubnetFilters) == 0 {
-			log.Fatal("Fargate requires at least one subnet")
-		}
+		// a service brings its own launch and network configuration
+		if taskDefTask.LikeService == "" {
+			taskDefTask.Fargate = true
 
-		// Run the task
-		err := task.RunTaskDef()
-		check(err)
-		if task.Detach {
-			task.Check()
-		} else {
-			defer task.Stop()
-			wg.Add(2)
-			go task.Stream()
-			go task.Check()
-			if err != nil {
-				log.Fatal(err.Error())
+			if taskDefTask.Family == "" {
+				log.Fatal("Please pass a task definition family or a service to run like")
 			}
-			c := make(chan os.Signal, 1)
-			signal.Notify(c, os.Interrupt)
-			go func() {
-				for sig := range c {
-					log.Printf("I got a %T\n", sig)
-					task.Stop()
-					os.Exit(0)
-				}
-			}()
-			wg.Wait()
 		}
+
+		// Override the command of the container
+		if len(args) > 0 {
+			taskDefTask.Command = args
+		}
+
+		// Run the task
+		client := newClient(taskDefTask.CLIRoleArn)
+		check(client.RunTaskDef(context.Background(), &taskDefTask))
+		follow(client, &taskDefTask)
 	},
 }
@@ -1,20 +1,28 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
+	"text/tabwriter"
 
 	ecs "github.com/justmiles/ecs-cli/lib"
 	"github.com/spf13/cobra"
 )
 
 var (
-	task        ecs.Task
-	wg          sync.WaitGroup
-	validMemCPU map[int][]int
+	task      ecs.Task
+	specFile  string
+	printSpec bool
+	sidecars  []string
+
+	// stop the tasks when the CLI is interrupted, shared by run and run-task-def
+	stopOnExit bool
 )
 
 func init() {
@@ -28,34 +36,64 @@ func init() {
 	runCmd.PersistentFlags().StringVar(&task.TaskRoleArn, "role", "", "Task role ARN")
 	runCmd.PersistentFlags().StringVar(&task.CLIRoleArn, "cli-role", "", "An IAM role ARN to assume before creating/executing a task")
 	runCmd.PersistentFlags().BoolVarP(&task.Detach, "detach", "d", false, "Run the task in the background")
+	runCmd.PersistentFlags().StringVar(&task.ExitCodeFrom, "exit-code-from", "", "Exit with the exit code of this container instead of the main container")
+	runCmd.PersistentFlags().StringVar(&task.ExitPolicy, "exit-policy", "any-failure", "How the exit codes of --count tasks are combined: any-failure, all-failure, first or max")
+	runCmd.PersistentFlags().BoolVar(&stopOnExit, "stop-on-exit", true, "Stop the task on SIGINT or SIGTERM. With false the CLI exits and leaves the task running")
+	runCmd.PersistentFlags().StringVar(&task.Timeout, "timeout", "", "Stop the task after this long (eg 30m) and exit with 124. Detached tasks are tagged with their deadline for ecs reap")
 	runCmd.PersistentFlags().BoolVar(&task.NoCleanup, "no-cleanup", false, "do not deregister and delete the task definition revision")
 	runCmd.PersistentFlags().Int64VarP(&task.Count, "count", "c", 1, "Spawn n tasks")
 	runCmd.PersistentFlags().Int64VarP(&task.Memory, "memory", "m", 0, "Memory limit")
 	runCmd.PersistentFlags().Int64Var(&task.CPUReservation, "cpu-reservation", 256, "CPU reservation")
 	runCmd.PersistentFlags().Int64Var(&task.MemoryReservation, "memory-reservation", 2048, "Memory reservation")
 	runCmd.PersistentFlags().StringArrayVarP(&task.Environment, "env", "e", nil, "Set environment variables")
-	runCmd.PersistentFlags().StringArrayVarP(&task.Publish, "publish", "p", nil, "Publish a container's port(s) to the host")
+	runCmd.PersistentFlags().StringArrayVar(&task.EnvironmentFiles, "env-file", nil, "Read environment variables from a local dotenv file, or have the agent read them from s3://bucket/key")
+	runCmd.PersistentFlags().StringArrayVar(&task.Secrets, "secret", nil, "Inject a secret as an environment variable, NAME=ARN of an SSM parameter or Secrets Manager secret, or NAME=ssm:/path/name")
+	runCmd.PersistentFlags().StringArrayVarP(&task.Publish, "publish", "p", nil, "Publish a container's port(s) to the host, [[IP:][HOST_PORT]:]CONTAINER_PORT[/PROTOCOL][,name=NAME][,appProtocol=http|http2|grpc]")
+	runCmd.PersistentFlags().BoolVarP(&task.PublishAll, "publish-all", "P", false, "Publish all ports the image EXPOSEs (ECR images only)")
 	// TODO: attach a specific security group
 	runCmd.PersistentFlags().StringArrayVar(&task.SecurityGroups, "security-groups", nil, "attach security groups to task")
 	runCmd.PersistentFlags().StringArrayVar(&task.SubnetFilters, "subnet-filter", nil, "'Key=Value' filters for your subnet, eg tag:Name=private")
 	runCmd.PersistentFlags().StringArrayVarP(&task.Volumes, "volume", "v", nil, "Map volume to ECS Container Instance")
 	runCmd.PersistentFlags().StringArrayVarP(&task.EfsVolumes, "efs-volume", "", nil, "Map EFS volume to ECS Container Instance (ex. fs-23kj2f:/efs/dir:/container/mnt/dir)")
-	runCmd.PersistentFlags().StringArrayVarP(&task.Tag, "tag", "t", nil, "Tag task definition on creation (eg key=value). Multiple uses for multiple tags")
+	runCmd.PersistentFlags().StringArrayVar(&sidecars, "sidecar", nil, "Run an additional container (eg name=proxy,image=envoyproxy/envoy,essential=false,publish=9901,env=KEY=VALUE,depends-on=other:HEALTHY)")
+	runCmd.PersistentFlags().StringArrayVar(&task.DependsOn, "depends-on", nil, "Start the main container once another container reaches a condition (eg proxy:HEALTHY). Conditions are START, HEALTHY, COMPLETE and SUCCESS")
+	runCmd.PersistentFlags().StringArrayVar(&task.Tag, "tag", nil, "Tag task definition on creation (eg key=value). Multiple uses for multiple tags")
 	// TODO: support assigning public ip address
 	runCmd.PersistentFlags().BoolVar(&task.Public, "public", false, "assign public ip")
+	runCmd.PersistentFlags().StringVar(&task.CapacityProvider, "capacity-provider", "", "Capacity provider strategy as NAME[:WEIGHT[:BASE]],... (eg FARGATE_SPOT:3:1,FARGATE:1)")
+	runCmd.PersistentFlags().BoolVar(&task.UseClusterDefaultCapacity, "use-cluster-default-capacity", false, "Use the cluster's default capacity provider strategy instead of a launch type")
 	runCmd.PersistentFlags().BoolVar(&task.Fargate, "fargate", false, "Launch in Fargate")
 	runCmd.PersistentFlags().BoolVar(&task.Debug, "debug", false, "Verbose logging")
-	runCmd.Flags().SetInterspersed(false)
 
-	// Init CPU/Memory configs
-	validMemCPU = make(map[int][]int)
-	validMemCPU[256] = []int{512, 1024, 2048}
-	validMemCPU[512] = []int{1024, 2048, 3072, 4096}
-	validMemCPU[1024] = []int{2048, 3072, 4096, 5120, 6144, 7168, 8192}
-	validMemCPU[2048] = []int{4096, 5120, 6144, 7168, 8192, 9216, 10240, 11264, 12288, 13312, 14336, 15360, 16384}
-	validMemCPU[4096] = []int{8192, 9216, 10240, 11264, 12288, 13312, 14336, 15360, 16384, 17408, 18432, 19456, 20480, 21504, 22528, 23552, 24575, 25600, 26624, 27648, 28678, 29696, 30720}
-	validMemCPU[8192] = []int{16384, 20480, 24576, 28672, 32768, 36864, 40960, 45056, 49152, 53248, 57344, 61440}
-	validMemCPU[16384] = []int{32768, 40960, 49152, 57344, 65536, 73728, 81920, 90112, 98304, 106496, 114688, 122880}
+	// docker run options
+	runCmd.PersistentFlags().StringVar(&task.EntryPoint, "entrypoint", "", "Overwrite the default ENTRYPOINT of the image")
+	runCmd.PersistentFlags().StringVarP(&task.WorkingDirectory, "workdir", "w", "", "Working directory inside the container")
+	runCmd.PersistentFlags().StringVarP(&task.User, "user", "u", "", "Username or UID (format: <name|uid>[:<group|gid>])")
+	runCmd.PersistentFlags().StringVar(&task.Hostname, "hostname", "", "Container host name (not supported on Fargate)")
+	runCmd.PersistentFlags().StringArrayVarP(&task.Labels, "label", "l", nil, "Set docker labels on the container (eg key=value)")
+	runCmd.PersistentFlags().StringArrayVar(&task.Ulimits, "ulimit", nil, "Ulimit options (eg nofile=1024:4096)")
+	runCmd.PersistentFlags().StringArrayVar(&task.CapAdd, "cap-add", nil, "Add Linux capabilities")
+	runCmd.PersistentFlags().StringArrayVar(&task.CapDrop, "cap-drop", nil, "Drop Linux capabilities")
+	runCmd.PersistentFlags().BoolVar(&task.Privileged, "privileged", false, "Give extended privileges to the container (not supported on Fargate)")
+	runCmd.PersistentFlags().BoolVar(&task.ReadOnly, "read-only", false, "Mount the container's root filesystem as read only")
+	runCmd.PersistentFlags().BoolVar(&task.Init, "init", false, "Run an init inside the container that forwards signals and reaps processes")
+	runCmd.PersistentFlags().StringArrayVar(&task.Tmpfs, "tmpfs", nil, "Mount a tmpfs directory (eg /run:size=64m,noexec, not supported on Fargate)")
+	runCmd.PersistentFlags().StringVar(&task.ShmSize, "shm-size", "", "Size of /dev/shm (eg 64m, not supported on Fargate)")
+	runCmd.PersistentFlags().StringArrayVar(&task.Sysctls, "sysctl", nil, "Sysctl options (eg net.ipv4.tcp_keepalive_time=60)")
+	runCmd.PersistentFlags().StringArrayVar(&task.AddHosts, "add-host", nil, "Add a custom host-to-IP mapping (host:ip, not supported on Fargate)")
+	runCmd.PersistentFlags().StringArrayVar(&task.DNS, "dns", nil, "Set custom DNS servers (not supported on Fargate)")
+	runCmd.PersistentFlags().Int64Var(&task.StopTimeout, "stop-timeout", 0, "Seconds to wait for the container to stop before it is killed")
+	runCmd.PersistentFlags().StringVar(&task.HealthCmd, "health-cmd", "", "Command to run to check health")
+	runCmd.PersistentFlags().StringVar(&task.HealthInterval, "health-interval", "", "Time between running the check (eg 30s)")
+	runCmd.PersistentFlags().StringVar(&task.HealthTimeout, "health-timeout", "", "Maximum time to allow one check to run (eg 5s)")
+	runCmd.PersistentFlags().StringVar(&task.HealthStartPeriod, "health-start-period", "", "Start period for the container to initialize before counting retries (eg 60s)")
+	runCmd.PersistentFlags().Int64Var(&task.HealthRetries, "health-retries", 0, "Consecutive failures needed to report unhealthy")
+	runCmd.PersistentFlags().StringVar(&task.Platform, "platform", "", "Set the platform of the task (eg linux/arm64)")
+	runCmd.PersistentFlags().Int64Var(&task.EphemeralStorage, "ephemeral-storage", 0, "Ephemeral storage in GiB (Fargate only, 21-200)")
+	runCmd.PersistentFlags().BoolVarP(&task.Tty, "tty", "t", false, "Allocate a pseudo-TTY")
+	runCmd.PersistentFlags().StringVarP(&specFile, "file", "f", "", "Load the task from a YAML or JSON spec file. Flags override values from the file")
+	runCmd.PersistentFlags().BoolVar(&printSpec, "print-spec", false, "Print the task spec implied by the current flags and exit")
+	runCmd.Flags().SetInterspersed(false)
 }
 
 // process the list command
@@ -64,79 +102,111 @@ var runCmd = &cobra.Command{
 	Short: "Run a command in a new task",
 	Run: func(cmd *cobra.Command, args []string) {
 
-		if len(args) < 1 {
-			log.Fatal("Please pass an image to run")
+		if specFile != "" {
+			applyTaskSpec(cmd, &task, specFile)
 		}
 
-		task.Image = args[0]
+		for _, sidecar := range sidecars {
+			container, err := ecs.ParseContainer(sidecar)
+			check(err)
+			task.Containers = append(task.Containers, container)
+		}
 
-		if len(args) > 1 {
-			task.Command = args[1:len(args)]
+		if len(args) > 0 {
+			task.Image = args[0]
+		}
+
+		// -t used to be the shorthand of --tag, and an image name can't contain =
+		if task.Tty && strings.Contains(task.Image, "=") {
+			log.Fatalf("-t is the shorthand of --tty, use --tag %s to tag the task definition", task.Image)
 		}
 
-		// fargate validation
-		if task.Fargate {
-			if len(task.SubnetFilters) == 0 {
-				log.Fatal("Fargate requires at least one subnet")
-			}
-			if !isValidMemCPU(int(task.CPUReservation), int(task.MemoryReservation)) {
-				log.Fatal("CPU/Memory unsupported. See supported values here: https://docs.aws.amazon.com/AmazonECS/latest/developerguide/task-cpu-memory-error.html")
-			}
+		if len(args) > 1 {
+			task.Command = args[1:len(args)]
 		}
 
-		// efs-volume validation
-		for _, volume := range task.EfsVolumes {
-			av := strings.Split(volume, ":")
-			if len(av) != 3 {
-				log.Fatal("Incorrect usage (--efs-volume)")
-			}
+		if printSpec {
+			spec, err := task.Spec()
+			check(err)
+			os.Stdout.Write(spec)
+			return
 		}
 
-		// tag validation
-		for _, tag := range task.Tag {
-			av := strings.Split(tag, "=")
-			if len(av) != 2 {
-				log.Fatal("Incorrect usage (--tag)")
-			}
+		if task.Image == "" {
+			log.Fatal("Please pass an image to run")
 		}
 
 		// Run the task
-		err := task.Run()
+		client := newClient(task.CLIRoleArn)
+		check(client.Run(context.Background(), &task))
+		follow(client, &task)
+	},
+}
+
+// follow streams the logs of the started tasks and exits with their exit code once they have
+// stopped. Detached tasks are left running. SIGINT and SIGTERM are handled by
+// ecs.EscalateSignals.
+func follow(client *ecs.Client, t *ecs.Task) {
+	if t.Detach {
+		_, err := client.Wait(context.Background(), t)
 		check(err)
+		return
+	}
 
-		if task.Detach {
-			task.Check()
-		} else {
-			defer task.Stop()
-			wg.Add(2)
-			go task.Stream()
-			go task.Check()
-
-			if err != nil {
-				log.Fatal(err.Error())
-			}
-			c := make(chan os.Signal, 1)
-			signal.Notify(c, os.Interrupt)
-			go func() {
-				for sig := range c {
-					log.Printf("I got a %T\n", sig)
-					task.Stop()
-					os.Exit(0)
-				}
-			}()
-
-			wg.Wait()
-		}
+	sigs := make(chan os.Signal, 3)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	interrupted := ecs.EscalateSignals(sigs, stopOnExit, ecs.SignalHooks{
+		Stop:    func(reason string) { client.Stop(context.Background(), t, reason) },
+		Exit:    os.Exit,
+		Warning: renderer.Warning,
+	})
+
+	var (
+		wg        sync.WaitGroup
+		exitCode  int64
+		streamErr error
+		waitErr   error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		streamErr = client.Stream(context.Background(), t)
+	}()
+	go func() {
+		defer wg.Done()
+		exitCode, waitErr = client.Wait(context.Background(), t)
+	}()
+	wg.Wait()
+	printResults(t.Results)
+
+	if code, ok := interrupted(); ok {
+		os.Exit(code)
+	}
 
-	},
+	check(waitErr)
+	check(streamErr)
+	os.Exit(int(exitCode))
 }
 
-func isValidMemCPU(cpu, memory int) bool {
-	for _, allowedCpuValue := range validMemCPU[cpu] {
-		if allowedCpuValue == memory {
-			return true
-		}
+// printResults prints the exit code of every container when there are several, as text output
+// only since the JSON events already carry them
+func printResults(results []ecs.TaskResult) {
+	if len(results) < 2 || output == "json" || output == "ndjson" {
+		return
 	}
 
-	return false
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TASK ID\tCONTAINER\tEXIT CODE\tSTOP REASON")
+	for _, r := range results {
+		exitCode := "-"
+		if r.ExitCode != nil {
+			exitCode = fmt.Sprintf("%d", *r.ExitCode)
+		}
+		container := r.Container
+		if r.Counted {
+			container += " *"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.TaskID, container, exitCode, orDash(r.Reason))
+	}
+	w.Flush()
 }
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	ecs "github.com/justmiles/ecs-cli/lib"
+	"github.com/spf13/cobra"
+)
+
+// taskFlagFields maps the flags of run to the Task fields they set
+var taskFlagFields = map[string]string{
+	"cluster":                      "Cluster",
+	"name":                         "Name",
+	"family":                       "Family",
+	"execution-role":               "ExecutionRoleArn",
+	"role":                         "TaskRoleArn",
+	"cli-role":                     "CLIRoleArn",
+	"detach":                       "Detach",
+	"exit-code-from":               "ExitCodeFrom",
+	"exit-policy":                  "ExitPolicy",
+	"timeout":                      "Timeout",
+	"no-cleanup":                   "NoCleanup",
+	"count":                        "Count",
+	"memory":                       "Memory",
+	"cpu-reservation":              "CPUReservation",
+	"memory-reservation":           "MemoryReservation",
+	"env":                          "Environment",
+	"env-file":                     "EnvironmentFiles",
+	"secret":                       "Secrets",
+	"publish":                      "Publish",
+	"publish-all":                  "PublishAll",
+	"security-groups":              "SecurityGroups",
+	"subnet-filter":                "SubnetFilters",
+	"volume":                       "Volumes",
+	"efs-volume":                   "EfsVolumes",
+	"depends-on":                   "DependsOn",
+	"tag":                          "Tag",
+	"public":                       "Public",
+	"capacity-provider":            "CapacityProvider",
+	"use-cluster-default-capacity": "UseClusterDefaultCapacity",
+	"fargate":                      "Fargate",
+	"debug":                        "Debug",
+	"entrypoint":                   "EntryPoint",
+	"workdir":                      "WorkingDirectory",
+	"user":                         "User",
+	"hostname":                     "Hostname",
+	"label":                        "Labels",
+	"ulimit":                       "Ulimits",
+	"cap-add":                      "CapAdd",
+	"cap-drop":                     "CapDrop",
+	"privileged":                   "Privileged",
+	"read-only":                    "ReadOnly",
+	"init":                         "Init",
+	"tmpfs":                        "Tmpfs",
+	"shm-size":                     "ShmSize",
+	"sysctl":                       "Sysctls",
+	"add-host":                     "AddHosts",
+	"dns":                          "DNS",
+	"stop-timeout":                 "StopTimeout",
+	"health-cmd":                   "HealthCmd",
+	"health-interval":              "HealthInterval",
+	"health-timeout":               "HealthTimeout",
+	"health-start-period":          "HealthStartPeriod",
+	"health-retries":               "HealthRetries",
+	"platform":                     "Platform",
+	"ephemeral-storage":            "EphemeralStorage",
+	"tty":                          "Tty",
+}
+
+// overriddenTaskFields returns the names of the Task fields whose flags were set explicitly
+func overriddenTaskFields(cmd *cobra.Command) map[string]bool {
+	overridden := map[string]bool{}
+	for flag, field := range taskFlagFields {
+		if cmd.Flags().Changed(flag) {
+			overridden[field] = true
+		}
+	}
+	return overridden
+}
+
+// applyTaskSpec loads a task spec file underneath the flags explicitly set on cmd
+func applyTaskSpec(cmd *cobra.Command, t *ecs.Task, path string) {
+	spec, err := ecs.LoadTaskSpec(path)
+	check(err)
+	t.ApplySpec(spec, overriddenTaskFields(cmd))
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	ecs "github.com/justmiles/ecs-cli/lib"
+)
+
+func TestOverriddenTaskFields(t *testing.T) {
+	if err := runCmd.ParseFlags([]string{"-e", "A=flag", "--publish", "80", "--cluster", "qa"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for flag, field := range taskFlagFields {
+		if runCmd.Flags().Lookup(flag) == nil {
+			t.Errorf("run has no flag %s", flag)
+		}
+		if _, ok := reflect.TypeOf(ecs.Task{}).FieldByName(field); !ok {
+			t.Errorf("flag %s: Task has no field %s", flag, field)
+		}
+	}
+
+	overridden := overriddenTaskFields(runCmd)
+	for _, field := range []string{"Environment", "Publish", "Cluster"} {
+		if !overridden[field] {
+			t.Errorf("expected %s to be overridden, got %v", field, overridden)
+		}
+	}
+	if overridden["Volumes"] {
+		t.Errorf("expected flags left unset not to be overridden")
+	}
+}
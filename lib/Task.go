@@ -1,16 +1,15 @@
 package ecs
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
-	"regexp"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/cenkalti/backoff"
@@ -20,79 +19,174 @@ import (
 // Task represents a single, runnable task
 type Task struct {
 	// Used by CLI to perform aws actions
-	CLIRoleArn string
-
-	Cluster            string
-	TaskDefinitionName string
-	Name               string
-	Image              string
-	ImageVersion       string
-	ExecutionRoleArn   string
-	TaskRoleArn        string
-	Family             string
-	LogGroupName       string
-	Detach             bool
-	Public             bool
-	Fargate            bool
-	Deregister         bool
-	NoCleanup          bool
-	Wait               bool
-	Count              int64
-	Memory             int64
-	MemoryReservation  int64
-	CPUReservation     int64
-	Publish            []string
-	Environment        []string
-	SecurityGroups     []string
-	SubnetFilters      []string
-	Volumes            []string
-	EfsVolumes         []string
-	Command            []string
-	Tag                []string
-	TaskDefinition     ecs.TaskDefinition
-	Tasks              []*ecs.Task
-	Debug              bool
+	CLIRoleArn string `yaml:"cliRoleArn,omitempty"`
+
+	Cluster            string   `yaml:"cluster,omitempty"`
+	TaskDefinitionName string   `yaml:"taskDefinitionName,omitempty"`
+	Name               string   `yaml:"name,omitempty"`
+	Image              string   `yaml:"image,omitempty"`
+	ImageVersion       string   `yaml:"imageVersion,omitempty"`
+	ExecutionRoleArn   string   `yaml:"executionRoleArn,omitempty"`
+	TaskRoleArn        string   `yaml:"taskRoleArn,omitempty"`
+	Family             string   `yaml:"family,omitempty"`
+	LogGroupName       string   `yaml:"logGroupName,omitempty"`
+	Detach             bool     `yaml:"detach,omitempty"`
+	Public             bool     `yaml:"public,omitempty"`
+	Fargate            bool     `yaml:"fargate,omitempty"`
+	Deregister         bool     `yaml:"deregister,omitempty"`
+	NoCleanup          bool     `yaml:"noCleanup,omitempty"`
+	Wait               bool     `yaml:"wait,omitempty"`
+	Count              int64    `yaml:"count,omitempty"`
+	Memory             int64    `yaml:"memory,omitempty"`
+	MemoryReservation  int64    `yaml:"memoryReservation,omitempty"`
+	CPUReservation     int64    `yaml:"cpuReservation,omitempty"`
+	Publish            []string `yaml:"publish,omitempty"`
+	PublishAll         bool     `yaml:"publishAll,omitempty"`
+	Environment        []string `yaml:"environment,omitempty"`
+	SecurityGroups     []string `yaml:"securityGroups,omitempty"`
+	SubnetFilters      []string `yaml:"subnetFilters,omitempty"`
+	Volumes            []string `yaml:"volumes,omitempty"`
+	EfsVolumes         []string `yaml:"efsVolumes,omitempty"`
+	Command            []string `yaml:"command,omitempty"`
+	Tag                []string `yaml:"tags,omitempty"`
+	Debug              bool     `yaml:"debug,omitempty"`
+
+	// Secrets as NAME=ARN or NAME=ssm:/path, read by the execution role at launch
+	Secrets []string `yaml:"secrets,omitempty"`
+
+	// docker run options of the main container
+	EntryPoint        string   `yaml:"entryPoint,omitempty"`
+	WorkingDirectory  string   `yaml:"workingDirectory,omitempty"`
+	User              string   `yaml:"user,omitempty"`
+	Hostname          string   `yaml:"hostname,omitempty"`
+	Labels            []string `yaml:"labels,omitempty"`
+	Ulimits           []string `yaml:"ulimits,omitempty"`
+	CapAdd            []string `yaml:"capAdd,omitempty"`
+	CapDrop           []string `yaml:"capDrop,omitempty"`
+	Privileged        bool     `yaml:"privileged,omitempty"`
+	ReadOnly          bool     `yaml:"readOnly,omitempty"`
+	Init              bool     `yaml:"init,omitempty"`
+	Tmpfs             []string `yaml:"tmpfs,omitempty"`
+	ShmSize           string   `yaml:"shmSize,omitempty"`
+	Sysctls           []string `yaml:"sysctls,omitempty"`
+	AddHosts          []string `yaml:"addHosts,omitempty"`
+	DNS               []string `yaml:"dns,omitempty"`
+	StopTimeout       int64    `yaml:"stopTimeout,omitempty"`
+	HealthCmd         string   `yaml:"healthCmd,omitempty"`
+	HealthInterval    string   `yaml:"healthInterval,omitempty"`
+	HealthTimeout     string   `yaml:"healthTimeout,omitempty"`
+	HealthStartPeriod string   `yaml:"healthStartPeriod,omitempty"`
+	HealthRetries     int64    `yaml:"healthRetries,omitempty"`
+	Tty               bool     `yaml:"tty,omitempty"`
+
+	// Runtime platform as OS/ARCH, eg linux/arm64
+	Platform string `yaml:"platform,omitempty"`
+	// Fargate ephemeral storage in GiB
+	EphemeralStorage int64 `yaml:"ephemeralStorage,omitempty"`
+
+	// Containers the main container waits on, as NAME:CONDITION (eg proxy:HEALTHY)
+	DependsOn []string `yaml:"dependsOn,omitempty"`
+	// Additional containers to run alongside the main container
+	Containers []Container `yaml:"containers,omitempty"`
+
+	// Container of an existing task definition to override, the main container by default
+	Container string `yaml:"container,omitempty"`
+	// Local dotenv files, or S3 objects (s3://bucket/key or ARN) read by the agent
+	EnvironmentFiles []string `yaml:"environmentFiles,omitempty"`
+	// Task level cpu and memory overrides, eg 1024 or "1 vCPU"
+	TaskCPU    string `yaml:"taskCpu,omitempty"`
+	TaskMemory string `yaml:"taskMemory,omitempty"`
+
+	// Run like this service, inheriting its task definition, network and launch configuration
+	LikeService     string `yaml:"likeService,omitempty"`
+	PlatformVersion string `yaml:"platformVersion,omitempty"`
+	// Public was passed explicitly and overrides the inherited setting
+	PublicSet bool `yaml:"-"`
+
+	// Capacity provider strategy as NAME[:WEIGHT[:BASE]],... eg FARGATE_SPOT:3:1,FARGATE:1
+	CapacityProvider string `yaml:"capacityProvider,omitempty"`
+	// Omit the launch type so the cluster's default capacity provider strategy is used
+	UseClusterDefaultCapacity bool `yaml:"useClusterDefaultCapacity,omitempty"`
+
+	// Container whose exit code is the task's, the main container by default
+	ExitCodeFrom string `yaml:"exitCodeFrom,omitempty"`
+	// How the exit codes of several tasks are combined: any-failure (default), all-failure,
+	// first or max
+	ExitPolicy string `yaml:"exitPolicy,omitempty"`
+
+	// Stop the tasks once they have run this long, eg 30m. Detached tasks are only tagged with
+	// their deadline for Reap to stop them.
+	Timeout string `yaml:"timeout,omitempty"`
+
+	TaskDefinition ecs.TaskDefinition `yaml:"-"`
+	Tasks          []*ecs.Task        `yaml:"-"`
+	// Exit code of every container of the stopped tasks, set by Wait
+	Results []TaskResult `yaml:"-"`
+
+	// set when an identical, existing revision was used instead of registering a new one
+	reusedTaskDefinition bool
+	// when the tasks are stopped, set from Timeout
+	deadline time.Time
 }
 
-// Stop a task
-func (t *Task) Stop() {
-	logInfo("Stopping tasks")
+// Stop the tasks, giving ECS the reason
+func (c *Client) Stop(ctx context.Context, t *Task, reason string) error {
+	c.logInfo("Stopping tasks")
+	var failed error
 	for _, task := range t.Tasks {
-		_, err := ecsClient.StopTask(&ecs.StopTaskInput{
+		_, err := c.ECS.StopTaskWithContext(ctx, &ecs.StopTaskInput{
 			Cluster: task.ClusterArn,
-			Reason:  aws.String("recieved a ^C"),
+			Reason:  aws.String(reason),
 			Task:    task.TaskArn,
 		})
 
 		if err != nil {
-			logError(err)
+			c.logError(err)
+			failed = err
 		} else {
-			logInfo("Successfully stopped " + *task.TaskArn)
+			c.logInfo("Successfully stopped " + *task.TaskArn)
 		}
 	}
+	return failed
 }
 
-// Run a task
-func (t *Task) Run() error {
-	if t.CLIRoleArn != "" {
-		initAWSClients(t.CLIRoleArn)
-	}
+// Run registers a task definition for the task and runs it
+func (c *Client) Run(ctx context.Context, t *Task) error {
 
 	var launchType string
 	var publicIP string
-	// var svc = ecs.New(sess)
-	t.createLogGroup()
 
-	// If fargate, ignore bind mounts
-	if t.Fargate {
-		t.Volumes = []string{}
+	t.LogGroupName = "/" + t.Cluster + "/ecs/" + t.Name
+
+	v, m, err := buildMountPoint(t.Volumes, t.EfsVolumes)
+	if err != nil {
+		return err
 	}
-	v, m := buildMountPoint(t.Volumes, t.EfsVolumes)
 
 	if t.Family == "" {
 		t.Family = t.Name
 	}
 
+	dependsOn, err := buildContainerDependencies(t.DependsOn)
+	if err != nil {
+		return err
+	}
+
+	secrets, err := c.buildSecrets(ctx, t.Secrets)
+	if err != nil {
+		return err
+	}
+
+	environment, environmentFiles, err := t.environment(c.Region)
+	if err != nil {
+		return err
+	}
+
+	portMappings, err := c.buildPortMapping(t.Publish)
+	if err != nil {
+		return err
+	}
+
 	taskDefInput := ecs.RegisterTaskDefinitionInput{
 		ContainerDefinitions: []*ecs.ContainerDefinition{
 			&ecs.ContainerDefinition{
@@ -104,15 +198,18 @@ func (t *Task) Run() error {
 					LogDriver: aws.String("awslogs"),
 					Options: aws.StringMap(map[string]string{
 						"awslogs-group":         t.LogGroupName,
-						"awslogs-region":        *sess.Config.Region,
+						"awslogs-region":        c.Region,
 						"awslogs-stream-prefix": t.Name,
 					}),
 				},
-				Essential:    aws.Bool(true),
-				Environment:  buildEnvironmentKeyValuePair(t.Environment),
-				PortMappings: buildPortMapping(t.Publish),
-				MountPoints:  m,
-				VolumesFrom:  []*ecs.VolumeFrom{},
+				Essential:        aws.Bool(true),
+				Environment:      environment,
+				EnvironmentFiles: environmentFiles,
+				Secrets:          secrets,
+				PortMappings:     portMappings,
+				MountPoints:      m,
+				VolumesFrom:      []*ecs.VolumeFrom{},
+				DependsOn:        dependsOn,
 			},
 		},
 		Volumes:     v,
@@ -120,6 +217,14 @@ func (t *Task) Run() error {
 		TaskRoleArn: aws.String(t.TaskRoleArn),
 	}
 
+	for _, container := range t.Containers {
+		def, err := c.buildContainerDefinition(t, container)
+		if err != nil {
+			return err
+		}
+		taskDefInput.ContainerDefinitions = append(taskDefInput.ContainerDefinitions, def)
+	}
+
 	if t.Memory > 0 {
 		taskDefInput.ContainerDefinitions[0].Memory = aws.Int64(t.Memory)
 	}
@@ -128,13 +233,40 @@ func (t *Task) Run() error {
 		taskDefInput.ContainerDefinitions[0].MemoryReservation = aws.Int64(t.MemoryReservation)
 	}
 
+	if err := t.applyDockerOptions(taskDefInput.ContainerDefinitions[0]); err != nil {
+		return err
+	}
+
 	if len(t.Tag) > 0 {
-		taskDefInput.Tags = buildTags(t.Tag)
+		if taskDefInput.Tags, err = buildTags(t.Tag); err != nil {
+			return err
+		}
+	}
+
+	if t.Platform != "" {
+		if taskDefInput.RuntimePlatform, err = parsePlatform(t.Platform); err != nil {
+			return err
+		}
+	}
+
+	// publish every port the image EXPOSEs, like docker run -P
+	if t.PublishAll {
+		exposed, err := c.exposedPorts(ctx, t.Image, imageArchitecture(taskDefInput.RuntimePlatform))
+		if err != nil {
+			return err
+		}
+		main := taskDefInput.ContainerDefinitions[0]
+		main.PortMappings = mergePortMappings(main.PortMappings, exposed)
+	}
+
+	if t.EphemeralStorage > 0 {
+		taskDefInput.EphemeralStorage = &ecs.EphemeralStorage{SizeInGiB: aws.Int64(t.EphemeralStorage)}
 	}
 
 	if t.Fargate {
 		taskDefInput.RequiresCompatibilities = aws.StringSlice([]string{"FARGATE"})
 		taskDefInput.NetworkMode = aws.String("awsvpc")
+		c.warnAwsvpcPorts(taskDefInput.ContainerDefinitions)
 		taskDefInput.ExecutionRoleArn = aws.String(t.ExecutionRoleArn)
 		taskDefInput.Cpu = aws.String(fmt.Sprintf("%d", t.CPUReservation))
 		taskDefInput.Memory = aws.String(fmt.Sprintf("%d", t.MemoryReservation))
@@ -148,23 +280,15 @@ func (t *Task) Run() error {
 		if t.ExecutionRoleArn == "" && t.TaskRoleArn != "" {
 			taskDefInput.ExecutionRoleArn = aws.String(t.TaskRoleArn)
 		}
+	} else if t.ExecutionRoleArn != "" {
+		taskDefInput.ExecutionRoleArn = aws.String(t.ExecutionRoleArn)
 	}
 
-	// Register a new task definition
-	arn, err := t.upsertTaskDefinition(ecsClient, &taskDefInput)
-	if err != nil {
-		fmt.Printf("Error creating task definition: %s", err.Error())
-		os.Exit(1)
-	}
-
-	logInfo("Running task definition: " + *arn)
-
 	// Build the task parametes
 	runTaskInput := &ecs.RunTaskInput{
 		Cluster:              aws.String(t.Cluster),
 		Count:                aws.Int64(t.Count),
 		StartedBy:            aws.String("ecs cli"),
-		TaskDefinition:       arn,
 		EnableExecuteCommand: aws.Bool(true),
 	}
 
@@ -183,15 +307,18 @@ func (t *Task) Run() error {
 			},
 		}
 
-		subnets, err := getSubnetsByFilter(t.SubnetFilters)
-		if err != nil {
-			return err
-		}
+		// without filters every subnet would match, leave them empty for validation to report
+		if len(t.SubnetFilters) > 0 {
+			subnets, err := c.getSubnetsByFilter(ctx, t.SubnetFilters)
+			if err != nil {
+				return err
+			}
 
-		runTaskInput.NetworkConfiguration.AwsvpcConfiguration.Subnets = subnets
+			runTaskInput.NetworkConfiguration.AwsvpcConfiguration.Subnets = subnets
+		}
 
 		for _, groupName := range t.SecurityGroups {
-			id, err := getSecurityGroupByName(groupName)
+			id, err := c.getSecurityGroupByName(ctx, groupName)
 			if err != nil {
 				return err
 			}
@@ -205,51 +332,93 @@ func (t *Task) Run() error {
 
 	runTaskInput.LaunchType = aws.String(launchType)
 
+	if err := c.applyCapacityStrategy(ctx, t, runTaskInput); err != nil {
+		return err
+	}
+
+	if err := t.applyTimeout(runTaskInput, time.Now()); err != nil {
+		return err
+	}
+
+	if err := t.checkExitOptions(taskDefInput.ContainerDefinitions); err != nil {
+		return err
+	}
+
+	// report every problem before anything is created or registered
+	if err := Validate(&taskDefInput, runTaskInput); err != nil {
+		return err
+	}
+
+	// fail before registering anything if the secrets can't be read at launch
+	if err := c.checkSecretAccess(ctx, aws.StringValue(taskDefInput.ExecutionRoleArn), secrets); err != nil {
+		return err
+	}
+
+	c.createLogGroup(ctx, t)
+
+	// Register a new task definition
+	arn, err := c.upsertTaskDefinition(ctx, t, &taskDefInput)
+	if err != nil {
+		return fmt.Errorf("Error creating task definition: %s", err)
+	}
+
+	c.emit(Event{Type: EventTaskDefinitionRegistered, TaskDefinitionArn: *arn, Reused: t.reusedTaskDefinition})
+	runTaskInput.TaskDefinition = arn
+
 	// Run the task
-	runTaskResponse, err := ecsClient.RunTask(runTaskInput)
+	runTaskResponse, err := c.ECS.RunTaskWithContext(ctx, runTaskInput)
 	if err != nil {
 		return err
 	}
 
 	// Deregister and delete task definition
 	if t.NoCleanup {
-		logInfo("Preserving task definition.")
+		c.logInfo("Preserving task definition.")
+	} else if t.reusedTaskDefinition {
+		c.logInfo("Preserving reused task definition.")
 	} else {
-		t.delete(ecsClient, *arn)
+		c.deleteTaskDefinition(ctx, t)
 	}
 
-	for _, failure := range runTaskResponse.Failures {
-		fmt.Printf("Unable to schedule task on: %s\n\t%s\n", *failure.Arn, *failure.Reason)
-	}
-
-	if len(runTaskResponse.Failures) > 0 && len(runTaskResponse.Tasks) == 0 {
-		return errors.New("Unable to schedule task")
-	}
-
-	t.Tasks = runTaskResponse.Tasks
-	return nil
+	return c.started(t, runTaskResponse)
 }
 
-func (t *Task) RunTaskDef() error {
-	if t.CLIRoleArn != "" {
-		initAWSClients(t.CLIRoleArn)
-	}
+// RunTaskDef runs an existing task definition, or the one of the service to run like
+func (c *Client) RunTaskDef(ctx context.Context, t *Task) error {
 
-	var launchType string
-	var publicIP string
 	var arn *string
 	var err error
 
-	describeTaskDefinitionOuput, err := ecsClient.DescribeTaskDefinition(&ecs.DescribeTaskDefinitionInput{
+	var service *ecs.Service
+	if t.LikeService != "" {
+		if service, err = c.describeService(ctx, t.Cluster, t.LikeService); err != nil {
+			return err
+		}
+	}
+
+	// the service's revision is used unless a family is passed
+	taskDefinition := t.Family
+	if taskDefinition == "" && service != nil {
+		taskDefinition = aws.StringValue(service.TaskDefinition)
+	}
+	if taskDefinition == "" {
+		return errors.New("Please pass a task definition family or a service to run like")
+	}
+
+	describeTaskDefinitionOuput, err := c.ECS.DescribeTaskDefinitionWithContext(ctx, &ecs.DescribeTaskDefinitionInput{
 		Include:        aws.StringSlice([]string{"TAGS"}),
-		TaskDefinition: &t.Family,
+		TaskDefinition: aws.String(taskDefinition),
 	})
 	if err != nil {
 		return fmt.Errorf("Error describing task def: %s", err)
 	}
+	if t.Family == "" {
+		t.Family = aws.StringValue(describeTaskDefinitionOuput.TaskDefinition.Family)
+	}
 
 	var taskDefinitionInput ecs.RegisterTaskDefinitionInput
 	arn = describeTaskDefinitionOuput.TaskDefinition.TaskDefinitionArn
+	t.TaskDefinition = *describeTaskDefinitionOuput.TaskDefinition
 
 	tmpVar, _ := json.Marshal(describeTaskDefinitionOuput.TaskDefinition)
 	err = json.Unmarshal(tmpVar, &taskDefinitionInput)
@@ -257,81 +426,98 @@ func (t *Task) RunTaskDef() error {
 		return fmt.Errorf("Error Unmarshalling TaskDefOutput: %s", err)
 	}
 
-	// Update image version if provided
+	// Only the image requires a new revision, everything else is passed as an override
 	if len(t.ImageVersion) > 0 {
-		previousImage := *taskDefinitionInput.ContainerDefinitions[0].Image
-
-		// Parse image
-		image := strings.Split(previousImage, ":")
-		image[1] = t.ImageVersion
-		taskDefinitionInput.ContainerDefinitions[0].Image = aws.String(strings.Join(image, ":"))
+		containerName := t.overrideContainerName()
+		var container *ecs.ContainerDefinition
+		for _, def := range taskDefinitionInput.ContainerDefinitions {
+			if aws.StringValue(def.Name) == containerName {
+				container = def
+			}
+		}
+		if container == nil {
+			return fmt.Errorf("container %s not found in task definition %s", containerName, t.Family)
+		}
 
-		logInfo(fmt.Sprintf("Updating image version. %s -> %s", strings.Split(previousImage, "/")[1], strings.Split(*taskDefinitionInput.ContainerDefinitions[0].Image, "/")[1]))
+		previousImage := aws.StringValue(container.Image)
+		container.Image = aws.String(replaceImageTag(previousImage, t.ImageVersion))
 
-		// Register a new task definition
-		arn, err = t.upsertTaskDefinition(ecsClient, &taskDefinitionInput)
-		if err != nil {
-			fmt.Printf("Error creating task definition: %s", err.Error())
-			os.Exit(1)
-		}
+		c.logInfo(fmt.Sprintf("Updating image version. %s -> %s", previousImage, *container.Image))
 	}
 
-	logInfo("Running task definition: " + *arn)
+	overrides, err := t.buildTaskOverride(c.Region)
+	if err != nil {
+		return err
+	}
 
 	// Build the task parametes
 	runTaskInput := &ecs.RunTaskInput{
 		Cluster:              aws.String(t.Cluster),
 		Count:                aws.Int64(t.Count),
 		StartedBy:            aws.String("ecs cli"),
-		TaskDefinition:       arn,
 		EnableExecuteCommand: aws.Bool(true),
+		Overrides:            overrides,
 	}
 
-	// Configure for Fargate
-	if t.Fargate {
+	if service != nil {
+		c.logInfo(fmt.Sprintf("Running like service %s", aws.StringValue(service.ServiceName)))
+		inheritServiceConfiguration(runTaskInput, service)
+	} else if t.Fargate {
+		runTaskInput.LaunchType = aws.String("FARGATE")
+	} else {
+		runTaskInput.LaunchType = aws.String("EC2")
+	}
 
-		if t.Public {
-			publicIP = "ENABLED"
-		} else {
-			publicIP = "DISABLED"
-		}
-		launchType = "FARGATE"
-		runTaskInput.NetworkConfiguration = &ecs.NetworkConfiguration{
-			AwsvpcConfiguration: &ecs.AwsVpcConfiguration{
-				AssignPublicIp: aws.String(publicIP),
-			},
-		}
+	// Explicit flags override inherited values
+	if err := c.applyNetworkFlags(ctx, t, runTaskInput); err != nil {
+		return err
+	}
 
-		subnets, err := getSubnetsByFilter(t.SubnetFilters)
-		if err != nil {
-			return err
-		}
+	if err := c.applyCapacityStrategy(ctx, t, runTaskInput); err != nil {
+		return err
+	}
 
-		runTaskInput.NetworkConfiguration.AwsvpcConfiguration.Subnets = subnets
+	if err := t.applyTimeout(runTaskInput, time.Now()); err != nil {
+		return err
+	}
 
-		for _, groupName := range t.SecurityGroups {
-			id, err := getSecurityGroupByName(groupName)
-			if err != nil {
-				return err
-			}
-			runTaskInput.NetworkConfiguration.AwsvpcConfiguration.SecurityGroups = append(runTaskInput.NetworkConfiguration.AwsvpcConfiguration.SecurityGroups, id)
+	if err := t.checkExitOptions(t.TaskDefinition.ContainerDefinitions); err != nil {
+		return err
+	}
+
+	// report every problem before a revision is registered for the new image
+	if err := Validate(&taskDefinitionInput, runTaskInput); err != nil {
+		return err
+	}
+
+	if len(t.ImageVersion) > 0 {
+		arn, err = c.upsertTaskDefinition(ctx, t, &taskDefinitionInput)
+		if err != nil {
+			return fmt.Errorf("Error creating task definition: %s", err)
 		}
-	} else {
-		launchType = "EC2"
 	}
 
-	runTaskInput.LaunchType = aws.String(launchType)
+	// only a new image registers a revision, otherwise the existing one is run as is
+	c.emit(Event{Type: EventTaskDefinitionRegistered, TaskDefinitionArn: *arn, Reused: len(t.ImageVersion) == 0 || t.reusedTaskDefinition})
+	runTaskInput.TaskDefinition = arn
 
-	fmt.Println(runTaskInput)
+	if t.Debug {
+		c.logInfo(runTaskInput.String())
+	}
 
 	// Run the task
-	runTaskResponse, err := ecsClient.RunTask(runTaskInput)
+	runTaskResponse, err := c.ECS.RunTaskWithContext(ctx, runTaskInput)
 	if err != nil {
 		return err
 	}
 
+	return c.started(t, runTaskResponse)
+}
+
+// started records the tasks RunTask placed and reports those it couldn't
+func (c *Client) started(t *Task, runTaskResponse *ecs.RunTaskOutput) error {
 	for _, failure := range runTaskResponse.Failures {
-		fmt.Printf("Unable to schedule task on: %s\n\t%s\n", *failure.Arn, *failure.Reason)
+		c.logWarning(fmt.Sprintf("Unable to schedule task on: %s\n\t%s", aws.StringValue(failure.Arn), aws.StringValue(failure.Reason)))
 	}
 
 	if len(runTaskResponse.Failures) > 0 && len(runTaskResponse.Tasks) == 0 {
@@ -339,66 +525,78 @@ func (t *Task) RunTaskDef() error {
 	}
 
 	t.Tasks = runTaskResponse.Tasks
+	for _, task := range t.Tasks {
+		c.emit(Event{
+			Type:              EventTaskStarted,
+			ClusterArn:        aws.StringValue(task.ClusterArn),
+			TaskDefinitionArn: aws.StringValue(task.TaskDefinitionArn),
+			TaskArn:           aws.StringValue(task.TaskArn),
+		})
+	}
 	return nil
 }
 
-// Stream logs to stdout
-func (t *Task) Stream() {
-	logInfo("Streaming from Cloudwatch Logs")
-	var re = regexp.MustCompile("[^/]*$")
-	nextToken := ""
-
-	for _, task := range t.Tasks {
-		for {
-			logEventsInput := cloudwatchlogs.GetLogEventsInput{
-				StartFromHead: aws.Bool(true),
-				LogGroupName:  aws.String(*t.TaskDefinition.ContainerDefinitions[0].LogConfiguration.Options["awslogs-group"]),
-				LogStreamName: aws.String(*t.TaskDefinition.ContainerDefinitions[0].LogConfiguration.Options["awslogs-stream-prefix"] + "/" + *t.TaskDefinition.ContainerDefinitions[0].Name + "/" + re.FindString(*task.TaskArn)),
-			}
+// Stream emits the logs of every container of every task. The containers are followed
+// concurrently and their lines merged in timestamp order. Returns once all tasks have stopped
+// and their logs have been read.
+func (c *Client) Stream(ctx context.Context, t *Task) error {
+	c.logInfo("Streaming from Cloudwatch Logs")
+	followers := t.logFollowers()
 
-			if nextToken != "" {
-				logEventsInput.NextToken = aws.String(nextToken)
+	for {
+		stopped := c.stoppedTasks(ctx, t)
+
+		var (
+			mu     sync.Mutex
+			wg     sync.WaitGroup
+			events []*followedEvent
+		)
+		for _, f := range followers {
+			if f.done {
+				continue
 			}
-
-			logEvents, err := cloudwatchlogsClient.GetLogEvents(&logEventsInput)
-			if err != nil {
-				if awsErr, ok := err.(awserr.Error); ok {
-					// Get error details
-					if awsErr.Code() == "ResourceNotFoundException" {
-						time.Sleep(time.Second * 5)
-						continue
-					} else {
-						fmt.Println(err)
-					}
-				} else {
-					logFatalError(err)
+			wg.Add(1)
+			go func(f *logFollower) {
+				defer wg.Done()
+				e, err := f.poll(ctx, c.CloudWatchLogs)
+				if err != nil {
+					c.logError(err)
+					return
 				}
-			}
+				f.observe(len(e), stopped[f.taskArn])
 
-			for _, log := range logEvents.Events {
-				logCloudWatchEvent(log)
-			}
+				mu.Lock()
+				events = append(events, e...)
+				mu.Unlock()
+			}(f)
+		}
+		wg.Wait()
 
-			if logEvents.NextForwardToken != nil {
-				nextToken = *logEvents.NextForwardToken
-			}
+		c.emitFollowedEvents(events)
 
-			time.Sleep(time.Second * 5)
+		if allFollowersDone(followers) {
+			return nil
+		}
+
+		if err := c.sleep(ctx); err != nil {
+			return err
 		}
 	}
 }
 
-// Check the container is still running
-func (t *Task) Check() {
+// Wait follows the tasks until they have all stopped and returns the exit code of their main
+// container, or of ExitCodeFrom, combined by the ExitPolicy. Returns ExitCodeTimeout when they
+// were stopped for exceeding their timeout. The exit code of every container is left in Results.
+func (c *Client) Wait(ctx context.Context, t *Task) (int64, error) {
 	var cluster *string
-	var stoppedCount int
-	var exitCode int64
-	var reportedPorts = false
-	var ip *string
-	var re = regexp.MustCompile("[^/]*$")
+	var timedOut bool
+	var reportedPorts = map[string]bool{}
+	var placed = map[string]bool{}
+	var addresses = map[string]string{}
+	var tracker = newTaskTracker(t)
+	defer func() { t.Results = tracker.results }()
 	for _, task := range t.Tasks {
 		cluster = task.ClusterArn
-		logInfo(fmt.Sprintf("https://console.aws.amazon.com/ecs/home?#/clusters/%s/tasks/%s/details", t.Cluster, re.FindString(*task.TaskArn)))
 	}
 
 	for {
@@ -408,109 +606,175 @@ func (t *Task) Check() {
 		}
 
 		if len(describeTasksInput.Tasks) == 0 {
-			fmt.Println("Task not yet registered")
-			time.Sleep(time.Second * 5)
-			continue
+			return 0, errors.New("there are no tasks to wait for")
 		}
 
-		res, err := ecsClient.DescribeTasks(&describeTasksInput)
-		logError(err)
+		res, err := c.ECS.DescribeTasksWithContext(ctx, &describeTasksInput)
+		if err != nil {
+			return 0, err
+		}
 
 		for _, ecsTask := range res.Tasks {
 
-			if ip == nil && ecsTask.ContainerInstanceArn != nil {
-				res, err := ecsClient.DescribeContainerInstances(&ecs.DescribeContainerInstancesInput{
-					Cluster:            &t.Cluster,
-					ContainerInstances: aws.StringSlice([]string{*ecsTask.ContainerInstanceArn}),
-				})
-				logError(err)
-				// getEc2Ip
-				ip = getEc2InstanceIp(*res.ContainerInstances[0].Ec2InstanceId)
-				logInfo(fmt.Sprintf("Container is starting on EC2 instance %v (%v).", *res.ContainerInstances[0].Ec2InstanceId, *ip))
+			// each task is looked up once, a failed lookup only loses its address
+			if !placed[*ecsTask.TaskArn] && ecsTask.ContainerInstanceArn != nil {
+				placed[*ecsTask.TaskArn] = true
+				instanceID, ip, err := c.taskInstance(ctx, ecsTask)
+				if err != nil {
+					c.logError(err)
+				} else {
+					addresses[*ecsTask.TaskArn] = ip
+					c.emit(Event{
+						Type:       EventPlacedOnInstance,
+						ClusterArn: aws.StringValue(ecsTask.ClusterArn),
+						TaskArn:    aws.StringValue(ecsTask.TaskArn),
+						InstanceID: instanceID,
+						IPAddress:  ip,
+					})
+				}
 			}
 
-			if !reportedPorts {
+			if !reportedPorts[*ecsTask.TaskArn] {
+				var ports []PortBinding
 				for _, container := range ecsTask.Containers {
-
-					if container.NetworkBindings != nil {
-						for _, networkBind := range container.NetworkBindings {
-							//  get container instance ip from container.ContainerInstanceArn
-							logInfo(fmt.Sprintf("Container is available here\n\thttp://%v:%v\n\tTCP %v %v", *ip, *networkBind.HostPort, *ip, *networkBind.HostPort))
-							reportedPorts = true
-						}
+					//  get container instance ip from container.ContainerInstanceArn
+					for _, networkBind := range container.NetworkBindings {
+						ports = append(ports, PortBinding{
+							ContainerName: aws.StringValue(container.Name),
+							ContainerPort: aws.Int64Value(networkBind.ContainerPort),
+							HostPort:      aws.Int64Value(networkBind.HostPort),
+							Protocol:      aws.StringValue(networkBind.Protocol),
+						})
 					}
 				}
+				if len(ports) > 0 {
+					c.emit(Event{
+						Type:       EventPortsAvailable,
+						ClusterArn: aws.StringValue(ecsTask.ClusterArn),
+						TaskArn:    aws.StringValue(ecsTask.TaskArn),
+						IPAddress:  addresses[*ecsTask.TaskArn],
+						Ports:      ports,
+					})
+					reportedPorts[*ecsTask.TaskArn] = true
+				}
 			}
 
 			if *ecsTask.LastStatus == "STOPPED" {
-				logInfo(fmt.Sprintf("Task %v has stopped:\n\t%v", *ecsTask.TaskArn, *ecsTask.StoppedReason))
-				for _, container := range ecsTask.Containers {
-					if container.ExitCode != nil && *container.ExitCode >= exitCode {
-						exitCode = *container.ExitCode
-					} else {
-						exitCode = 1
-					}
+				// sidecars are only reported, and a task is only reported once
+				taskExitCode, first := tracker.stopped(ecsTask)
+				if !first {
+					continue
+				}
 
-					logInfo(fmt.Sprintf("Container %v has stopped (exit code %v)", *container.ContainerArn, exitCode))
-					if container.Reason != nil {
-						logInfo(fmt.Sprintf("\t%v", *container.Reason))
-					}
+				// the containers stop before their task
+				for _, container := range ecsTask.Containers {
+					c.emit(Event{
+						Type:          EventContainerStopped,
+						ClusterArn:    aws.StringValue(ecsTask.ClusterArn),
+						TaskArn:       aws.StringValue(ecsTask.TaskArn),
+						ContainerName: aws.StringValue(container.Name),
+						ContainerArn:  aws.StringValue(container.ContainerArn),
+						Reason:        aws.StringValue(container.Reason),
+						ExitCode:      container.ExitCode,
+					})
 				}
-				stoppedCount++
+				c.emit(Event{
+					Type:              EventTaskStopped,
+					ClusterArn:        aws.StringValue(ecsTask.ClusterArn),
+					TaskDefinitionArn: aws.StringValue(ecsTask.TaskDefinitionArn),
+					TaskArn:           aws.StringValue(ecsTask.TaskArn),
+					StopCode:          aws.StringValue(ecsTask.StopCode),
+					Reason:            aws.StringValue(ecsTask.StoppedReason),
+					ExitCode:          taskExitCode,
+				})
 			}
 		}
-		if stoppedCount == len(res.Tasks) && len(res.Tasks) != 0 {
-			logInfo("All containers have exited")
-			time.Sleep(time.Second * 5) // give the logs another chance to come in
-			os.Exit(int(exitCode))
+		for _, failure := range res.Failures {
+			if tracker.missing(failure) {
+				c.logWarning(fmt.Sprintf("Unable to describe task %s: %s", aws.StringValue(failure.Arn), aws.StringValue(failure.Reason)))
+			}
+		}
+		if tracker.done() {
+			c.logInfo("All containers have exited")
+			if timedOut {
+				return ExitCodeTimeout, nil
+			}
+			return tracker.exitCode(t.ExitPolicy), nil
 		}
+		// detached tasks are only described once
 		if t.Detach {
-			return
+			return 0, nil
 		}
-		time.Sleep(time.Second * 5)
+		if !timedOut && !t.deadline.IsZero() && time.Now().After(t.deadline) {
+			timedOut = true
+			reason := fmt.Sprintf("ecs-cli: timeout of %s exceeded", t.Timeout)
+			c.emit(Event{Type: EventTimedOut, ClusterArn: aws.StringValue(cluster), Reason: reason})
+			c.Stop(ctx, t, reason)
+		}
+		if err := c.sleep(ctx); err != nil {
+			return 0, err
+		}
+	}
+}
 
+// taskInstance returns the EC2 instance a task was placed on and its address
+func (c *Client) taskInstance(ctx context.Context, task *ecs.Task) (string, string, error) {
+	res, err := c.ECS.DescribeContainerInstancesWithContext(ctx, &ecs.DescribeContainerInstancesInput{
+		Cluster:            task.ClusterArn,
+		ContainerInstances: []*string{task.ContainerInstanceArn},
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if len(res.ContainerInstances) == 0 {
+		return "", "", fmt.Errorf("unable to find container instance %s", aws.StringValue(task.ContainerInstanceArn))
 	}
 
+	instanceID := aws.StringValue(res.ContainerInstances[0].Ec2InstanceId)
+	ip, err := c.getEc2InstanceIp(ctx, instanceID)
+	if err != nil {
+		return "", "", err
+	}
+	return instanceID, aws.StringValue(ip), nil
 }
 
-func (t *Task) createLogGroup() {
-	t.LogGroupName = "/" + t.Cluster + "/ecs/" + t.Name
-
+func (c *Client) createLogGroup(ctx context.Context, t *Task) {
 	// var svc = cloudwatchlogs.New(sess)
 	var logGroupName = aws.String(t.LogGroupName)
 
-	output, err := cloudwatchlogsClient.DescribeLogGroups(&cloudwatchlogs.DescribeLogGroupsInput{
+	output, err := c.CloudWatchLogs.DescribeLogGroupsWithContext(ctx, &cloudwatchlogs.DescribeLogGroupsInput{
 		LogGroupNamePrefix: logGroupName,
 	})
 	if err != nil {
-		logError(err)
+		c.logError(err)
 		return
 	}
 	if len(output.LogGroups) == 0 {
-		logInfo(fmt.Sprintf("Creating Log Group %s\n", *logGroupName))
-		cloudwatchlogsClient.CreateLogGroup(&cloudwatchlogs.CreateLogGroupInput{
+		c.logInfo(fmt.Sprintf("Creating Log Group %s\n", *logGroupName))
+		_, err := c.CloudWatchLogs.CreateLogGroupWithContext(ctx, &cloudwatchlogs.CreateLogGroupInput{
 			LogGroupName: logGroupName,
 		})
+		c.logError(err)
 	}
 }
 
-func (t *Task) delete(svc *ecs.ECS, arn string) {
+func (c *Client) deleteTaskDefinition(ctx context.Context, t *Task) {
 	const maxRetries = 10
-	backoffWithRetries := backoff.WithMaxRetries(backoff.NewExponentialBackOff(), maxRetries)
+	backoffWithRetries := backoff.WithContext(backoff.WithMaxRetries(backoff.NewExponentialBackOff(), maxRetries), ctx)
 	// deregister
 	tdi := &ecs.DeregisterTaskDefinitionInput{
 		TaskDefinition: t.TaskDefinition.TaskDefinitionArn,
 	}
 
 	deregister := func() error {
-		_, err := svc.DeregisterTaskDefinition(tdi)
+		_, err := c.ECS.DeregisterTaskDefinitionWithContext(ctx, tdi)
 		return err
 	}
 
 	// Retry the operation using exponential backoff
 	err := backoff.Retry(deregister, backoffWithRetries)
 	if err != nil {
-		fmt.Println("Failed to deregister task definition:", err)
+		c.logWarning(fmt.Sprintf("Failed to deregister task definition: %s", err))
 		return
 	}
 
@@ -520,47 +784,62 @@ func (t *Task) delete(svc *ecs.ECS, arn string) {
 	}
 
 	delete := func() error {
-		_, err := svc.DeleteTaskDefinitions(dtdi)
+		_, err := c.ECS.DeleteTaskDefinitionsWithContext(ctx, dtdi)
 		return err
 	}
 
 	// Retry the operation using exponential backoff
 	err = backoff.Retry(delete, backoffWithRetries)
 	if err != nil {
-		fmt.Println("Failed to delete task definition:", err)
+		c.logWarning(fmt.Sprintf("Failed to delete task definition: %s", err))
 		return
 	}
+
+	c.emit(Event{Type: EventCleanupDone, TaskDefinitionArn: aws.StringValue(t.TaskDefinition.TaskDefinitionArn)})
 }
 
-func (t *Task) upsertTaskDefinition(svc *ecs.ECS, taskDefInput *ecs.RegisterTaskDefinitionInput) (*string, error) {
-	var td ecs.TaskDefinition
-	td.ContainerDefinitions = taskDefInput.ContainerDefinitions
+func (c *Client) upsertTaskDefinition(ctx context.Context, t *Task, taskDefInput *ecs.RegisterTaskDefinitionInput) (*string, error) {
+	t.reusedTaskDefinition = false
+
+	// reuse the latest active revision if it is identical to what we would register
+	latest, tags, err := c.latestTaskDefinition(ctx, aws.StringValue(taskDefInput.Family))
+	if err != nil {
+		c.logWarning(fmt.Sprintf("Unable to describe task definition %s: %s", aws.StringValue(taskDefInput.Family), err))
+	} else if taskDefinitionMatches(latest, tags, taskDefInput) {
+		c.logInfo("Reusing task definition: " + *latest.TaskDefinitionArn)
+		t.reusedTaskDefinition = true
+		t.TaskDefinition = *latest
+		return latest.TaskDefinitionArn, nil
+	}
 
-	// unable to find an old task definition, register a new one
-	req, taskDef := svc.RegisterTaskDefinitionRequest(taskDefInput)
+	// unable to find a matching task definition, register a new one
+	var taskDef *ecs.RegisterTaskDefinitionOutput
+	opts := []request.Option{func(r *request.Request) {
+		r.Retryable = aws.Bool(true)
+	}}
 
 	// An operation that may fail.
 	var retryCount int
 	const maxRetries = 50
-	backoffWithRetries := backoff.WithMaxRetries(backoff.NewExponentialBackOff(), maxRetries)
+	backoffWithRetries := backoff.WithContext(backoff.WithMaxRetries(backoff.NewExponentialBackOff(), maxRetries), ctx)
 
 	if t.Debug {
-		req.Config = *req.Config.WithLogLevel(aws.LogDebugWithRequestRetries)
+		opts = append(opts, request.WithLogLevel(aws.LogDebugWithRequestRetries))
 	}
 	operation := func() error {
-		logInfo("Creating task definition")
-		req.Retryable = aws.Bool(true)
-		err := req.Send()
+		c.logInfo("Creating task definition")
+		var err error
+		taskDef, err = c.ECS.RegisterTaskDefinitionWithContext(ctx, taskDefInput, opts...)
 		if err != nil {
 			t := time.Now()
 			t = t.Add(backoffWithRetries.NextBackOff())
-			logInfo(fmt.Sprintf("error creating task definition (attempt %d of %d). Will retry %s: %s\n", retryCount, maxRetries, humanize.Time(t), err))
+			c.logInfo(fmt.Sprintf("error creating task definition (attempt %d of %d). Will retry %s: %s\n", retryCount, maxRetries, humanize.Time(t), err))
 		}
 		retryCount++
 		return err
 	}
 
-	err := backoff.Retry(operation, backoffWithRetries)
+	err = backoff.Retry(operation, backoffWithRetries)
 	if err != nil {
 		return nil, err
 	}
@@ -1,72 +1,354 @@
 package ecs
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go/service/ecs"
-	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/justmiles/ecs-cli/lib/fake"
 )
 
+// newFakeClient returns a client backed by a fake cluster qa with a single EC2 instance
+func newFakeClient() (*Client, *fake.Backend, *recordingRenderer) {
+	b := fake.New()
+	b.ECS.AddCluster("qa")
+	b.ECS.AddContainerInstance("qa", "i-0123456789abcdef0")
+	b.EC2.AddInstance("i-0123456789abcdef0", "10.0.0.5", "203.0.113.5")
+
+	recorder := &recordingRenderer{}
+	c := NewClient(b.ECS.Region, b.ECS, b.Logs, b.EC2)
+	c.Events = recorder
+	c.PollInterval = time.Millisecond
+	return c, b, recorder
+}
+
+func newFakeTask() *Task {
+	return &Task{
+		Name:              "test",
+		Cluster:           "qa",
+		Image:             "alpine",
+		Command:           []string{"echo", "hello"},
+		Count:             1,
+		MemoryReservation: 512,
+	}
+}
+
+// follow streams and waits like ecs run does
+func follow(c *Client, task *Task) (int64, error) {
+	var wg sync.WaitGroup
+	var streamErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		streamErr = c.Stream(context.Background(), task)
+	}()
+
+	exitCode, err := c.Wait(context.Background(), task)
+	wg.Wait()
+	if err == nil {
+		err = streamErr
+	}
+	return exitCode, err
+}
+
+func eventsOfType(events []Event, eventType EventType) (matching []Event) {
+	for _, e := range events {
+		if e.Type == eventType {
+			matching = append(matching, e)
+		}
+	}
+	return matching
+}
+
 func TestRun(t *testing.T) {
-	// err := Run("qa", "test", "hello-world", false, false, false, 1, 0, 1024, nil, nil, nil, nil, nil, nil)
-	// if err != nil {
-	// 	t.Errorf("got: %v", err.Error())
-	// }
-	x := []string{
-		"x=test",
-		"y=thisisthevalueforY",
-		"SOMEENVVAR",
-	}
-
-	// task := Task{
-	// 	Name: "test",
-	// }
-	// task.Stream()
-	buildEnvironmentKeyValuePair(x)
-	return
+	c, b, recorder := newFakeClient()
+	b.ECS.Run = func(task *ecs.Task, container *ecs.ContainerDefinition) fake.ContainerRun {
+		return fake.ContainerRun{Output: []string{"hello", "world"}, RunTime: 10 * time.Second, ExitCode: 3}
+	}
+
+	task := newFakeTask()
+	task.Publish = []string{"8080:80"}
+	if err := c.Run(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+
+	exitCode, err := follow(c, task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 3 {
+		t.Errorf("expected the exit code of the container, got %d", exitCode)
+	}
+
+	events := recorder.events
+	registered := eventsOfType(events, EventTaskDefinitionRegistered)
+	if len(registered) != 1 || registered[0].TaskDefinitionArn != "arn:aws:ecs:us-east-1:000000000000:task-definition/test:1" {
+		t.Errorf("unexpected registration %v", registered)
+	}
+	if placed := eventsOfType(events, EventPlacedOnInstance); len(placed) != 1 || placed[0].IPAddress != "203.0.113.5" {
+		t.Errorf("expected the task to be placed on the instance, got %v", placed)
+	}
+	if ports := eventsOfType(events, EventPortsAvailable); len(ports) != 1 || ports[0].Ports[0].HostPort != 8080 {
+		t.Errorf("expected the published port, got %v", ports)
+	}
+	lines := eventsOfType(events, EventLogLine)
+	if len(lines) != 2 || lines[0].Message != "hello" || lines[1].Message != "world" || lines[0].ContainerName != "test" {
+		t.Errorf("expected the output of the container, got %v", lines)
+	}
+	stopped := eventsOfType(events, EventTaskStopped)
+	if len(stopped) != 1 || aws.Int64Value(stopped[0].ExitCode) != 3 || stopped[0].StopCode != ecs.TaskStopCodeEssentialContainerExited {
+		t.Errorf("unexpected stop %v", stopped)
+	}
+
+	// the task definition is cleaned up once the task has started
+	if len(eventsOfType(events, EventCleanupDone)) != 1 {
+		t.Errorf("expected the task definition to be deleted")
+	}
+	if td := b.ECS.TaskDefinition("test:1"); aws.StringValue(td.Status) != ecs.TaskDefinitionStatusDeleteInProgress {
+		t.Errorf("expected the task definition to be deleted, got %s", aws.StringValue(td.Status))
+	}
 }
 
-type mockedReceiveMsgs struct {
-	ecsiface.ECSAPI
-	Resp ecs.RunTaskOutput
+func TestRunReusesTaskDefinition(t *testing.T) {
+	c, b, recorder := newFakeClient()
+
+	for i := 0; i < 2; i++ {
+		task := newFakeTask()
+		task.NoCleanup = true
+		if err := c.Run(context.Background(), task); err != nil {
+			t.Fatal(err)
+		}
+		if exitCode, err := follow(c, task); err != nil || exitCode != 0 {
+			t.Fatalf("expected the task to succeed, got %d %v", exitCode, err)
+		}
+	}
+
+	registered := eventsOfType(recorder.events, EventTaskDefinitionRegistered)
+	if len(registered) != 2 || registered[0].Reused || !registered[1].Reused || registered[1].TaskDefinitionArn != registered[0].TaskDefinitionArn {
+		t.Errorf("expected the second run to reuse the first revision, got %v", registered)
+	}
+	if b.ECS.TaskDefinition("test:2") != nil {
+		t.Errorf("expected a single revision")
+	}
 }
 
-func (m mockedReceiveMsgs) ReceiveMessage(in *ecs.RunTaskInput) (*ecs.RunTaskOutput, error) {
-	// Only need to return mocked response output
-	res := `{
-    Failures: [],
-    Tasks: [{
-        Attachments: [],
-        ClusterArn: "arn:aws:ecs:us-east-1:965579072529:cluster/qa",
-        ContainerInstanceArn: "arn:aws:ecs:us-east-1:965579072529:container-instance/90da1657-b5bd-461e-84f7-62775f670a09",
-        Containers: [{
-            ContainerArn: "arn:aws:ecs:us-east-1:965579072529:container/01f8b585-7688-48f4-8a68-31e7b0c594a5",
-            LastStatus: "PENDING",
-            Name: "test",
-            NetworkInterfaces: [],
-            TaskArn: "arn:aws:ecs:us-east-1:965579072529:task/8ce3fc54-1630-4703-9bfd-3b4d55dc6219"
-          }],
-        Cpu: "0",
-        CreatedAt: 2018-08-02 14:39:10 +0000 UTC,
-        DesiredStatus: "RUNNING",
-        Group: "family:ephemeral-task-from-ecs-cli",
-        LastStatus: "PENDING",
-        LaunchType: "EC2",
-        Memory: "100",
-        Overrides: {
-          ContainerOverrides: [{
-              Name: "test"
-            }]
-        },
-        StartedBy: "ecs cli",
-        TaskArn: "arn:aws:ecs:us-east-1:965579072529:task/8ce3fc54-1630-4703-9bfd-3b4d55dc6219",
-        TaskDefinitionArn: "arn:aws:ecs:us-east-1:965579072529:task-definition/ephemeral-task-from-ecs-cli:22",
-        Version: 1
-      }]
-  }`
-
-	json.Unmarshal([]byte(res), &m.Resp)
-
-	return &m.Resp, nil
+func TestRunTaskDef(t *testing.T) {
+	c, b, recorder := newFakeClient()
+	b.ECS.Run = func(task *ecs.Task, container *ecs.ContainerDefinition) fake.ContainerRun {
+		// mirror the command override
+		return fake.ContainerRun{Output: aws.StringValueSlice(task.Overrides.ContainerOverrides[0].Command), RunTime: time.Second}
+	}
+
+	_, err := b.ECS.RegisterTaskDefinitionWithContext(context.Background(), &ecs.RegisterTaskDefinitionInput{
+		Family: aws.String("migrate"),
+		ContainerDefinitions: []*ecs.ContainerDefinition{{
+			Name:      aws.String("app"),
+			Image:     aws.String("app:1"),
+			Essential: aws.Bool(true),
+			Memory:    aws.Int64(512),
+			LogConfiguration: &ecs.LogConfiguration{
+				LogDriver: aws.String("awslogs"),
+				Options:   aws.StringMap(map[string]string{"awslogs-group": "/qa/app", "awslogs-stream-prefix": "app"}),
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task := &Task{Cluster: "qa", Family: "migrate", Count: 1, Command: []string{"migrate", "up"}}
+	if err := c.RunTaskDef(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+	if exitCode, err := follow(c, task); err != nil || exitCode != 0 {
+		t.Fatalf("expected the task to succeed, got %d %v", exitCode, err)
+	}
+
+	lines := eventsOfType(recorder.events, EventLogLine)
+	if len(lines) != 2 || lines[0].Message != "migrate" || lines[1].Message != "up" {
+		t.Errorf("expected the overridden command, got %v", lines)
+	}
+	if td := b.ECS.TaskDefinition("migrate"); td == nil {
+		t.Errorf("expected an existing task definition to be kept")
+	}
+}
+
+func TestRunInvalid(t *testing.T) {
+	c, b, _ := newFakeClient()
+
+	// Fargate without a subnet filter
+	task := newFakeTask()
+	task.Fargate = true
+	err := c.Run(context.Background(), task)
+	if err == nil || !strings.Contains(err.Error(), "networkConfiguration.awsvpcConfiguration.subnets") {
+		t.Fatalf("expected the missing subnets to be reported, got %v", err)
+	}
+	if b.ECS.TaskDefinition(task.Family) != nil {
+		t.Errorf("expected no task definition to be registered")
+	}
+	groups, _ := b.Logs.DescribeLogGroupsWithContext(context.Background(), &cloudwatchlogs.DescribeLogGroupsInput{})
+	if len(groups.LogGroups) != 0 {
+		t.Errorf("expected no log group to be created, got %v", groups.LogGroups)
+	}
+
+	_, err = b.ECS.RegisterTaskDefinitionWithContext(context.Background(), &ecs.RegisterTaskDefinitionInput{
+		Family: aws.String("migrate"),
+		ContainerDefinitions: []*ecs.ContainerDefinition{{
+			Name:      aws.String("app"),
+			Image:     aws.String("app:1"),
+			Essential: aws.Bool(true),
+			Memory:    aws.Int64(512),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a new image revision of a bridge task definition can't run on Fargate
+	task = &Task{Cluster: "qa", Family: "migrate", Count: 1, ImageVersion: "2", Fargate: true}
+	if err := c.RunTaskDef(context.Background(), task); err == nil || !strings.Contains(err.Error(), "networkMode") {
+		t.Fatalf("expected the task definition to be validated, got %v", err)
+	}
+	if td := b.ECS.TaskDefinition("migrate"); aws.Int64Value(td.Revision) != 1 {
+		t.Errorf("expected no revision to be registered, got %d", aws.Int64Value(td.Revision))
+	}
+}
+
+func TestStop(t *testing.T) {
+	c, b, _ := newFakeClient()
+	b.ECS.Run = func(task *ecs.Task, container *ecs.ContainerDefinition) fake.ContainerRun {
+		return fake.ContainerRun{RunTime: time.Hour}
+	}
+
+	task := newFakeTask()
+	if err := c.Run(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+
+	// let the task reach RUNNING before stopping it
+	b.Clock.Advance(time.Minute)
+	if err := c.Stop(context.Background(), task, "recieved a ^C"); err != nil {
+		t.Fatal(err)
+	}
+
+	exitCode, err := c.Wait(context.Background(), task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 143 {
+		t.Errorf("expected the container to exit on SIGTERM, got %d", exitCode)
+	}
+
+	stopped := b.ECS.Tasks()[0]
+	if aws.StringValue(stopped.StopCode) != ecs.TaskStopCodeUserInitiated || aws.StringValue(stopped.StoppedReason) != "recieved a ^C" {
+		t.Errorf("unexpected stop %v", stopped)
+	}
+}
+
+func TestRunFaults(t *testing.T) {
+	c, b, _ := newFakeClient()
+	b.ECS.Fail("RunTask", errors.New("throttled"))
+
+	if err := c.Run(context.Background(), newFakeTask()); err == nil || err.Error() != "throttled" {
+		t.Errorf("expected the RunTask error, got %v", err)
+	}
+
+	task := newFakeTask()
+	if err := c.Run(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+	b.ECS.Fail("DescribeTasks", errors.New("unavailable"))
+	if _, err := c.Wait(context.Background(), task); err == nil || err.Error() != "unavailable" {
+		t.Errorf("expected the DescribeTasks error, got %v", err)
+	}
+
+	// no container instance to place an EC2 task on
+	b.ECS.AddCluster("empty")
+	task = newFakeTask()
+	task.Cluster = "empty"
+	if err := c.Run(context.Background(), task); err == nil {
+		t.Errorf("expected an error without container instances")
+	}
+}
+
+func TestWaitCancelled(t *testing.T) {
+	c, b, _ := newFakeClient()
+	b.ECS.Run = func(task *ecs.Task, container *ecs.ContainerDefinition) fake.ContainerRun {
+		return fake.ContainerRun{RunTime: 24 * time.Hour}
+	}
+
+	task := newFakeTask()
+	if err := c.Run(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.Wait(ctx, task); err != context.DeadlineExceeded {
+		t.Errorf("expected Wait to stop with its context, got %v", err)
+	}
+}
+
+func TestWaitPlacement(t *testing.T) {
+	c, b, recorder := newFakeClient()
+	b.ECS.AddContainerInstance("qa", "i-0fedcba9876543210")
+	b.EC2.AddInstance("i-0fedcba9876543210", "10.0.0.6", "203.0.113.6")
+
+	task := newFakeTask()
+	task.Count = 2
+	task.Publish = []string{"8080:80"}
+	if err := c.Run(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := follow(c, task); err != nil {
+		t.Fatal(err)
+	}
+
+	ports := eventsOfType(recorder.events, EventPortsAvailable)
+	if len(ports) != 2 || ports[0].IPAddress == ports[1].IPAddress {
+		t.Errorf("expected the address of each task's instance, got %v", ports)
+	}
+
+	// a task whose instance can't be looked up is still followed until it stops
+	c, b, recorder = newFakeClient()
+	b.EC2.Fail("DescribeInstances", errors.New("UnauthorizedOperation"))
+	task = newFakeTask()
+	if err := c.Run(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := follow(c, task); err != nil {
+		t.Fatal(err)
+	}
+	if len(eventsOfType(recorder.events, EventTaskStopped)) != 1 || len(eventsOfType(recorder.events, EventPlacedOnInstance)) != 0 {
+		t.Errorf("expected the task to stop without a placement, got %v", recorder.events)
+	}
+}
+
+func TestStoppedEventOrder(t *testing.T) {
+	c, _, recorder := newFakeClient()
+	task := newFakeTask()
+	task.Containers = []Container{{Name: "proxy", Image: "envoyproxy/envoy", MemoryReservation: 128}}
+	if err := c.Run(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := follow(c, task); err != nil {
+		t.Fatal(err)
+	}
+
+	var order []EventType
+	for _, e := range recorder.events {
+		if e.Type == EventContainerStopped || e.Type == EventTaskStopped {
+			order = append(order, e.Type)
+		}
+	}
+	expected := []EventType{EventContainerStopped, EventContainerStopped, EventTaskStopped}
+	if len(order) != len(expected) || order[0] != expected[0] || order[1] != expected[1] || order[2] != expected[2] {
+		t.Errorf("expected the containers to stop before their task, got %v", order)
+	}
 }
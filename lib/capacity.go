@@ -0,0 +1,138 @@
+package ecs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// ParseCapacityProviderStrategy parses a comma separated list of NAME[:WEIGHT[:BASE]], eg
+// FARGATE_SPOT:3:1,FARGATE:1. The weight defaults to 1.
+func ParseCapacityProviderStrategy(s string) ([]*ecs.CapacityProviderStrategyItem, error) {
+	var strategy []*ecs.CapacityProviderStrategyItem
+	seen := map[string]bool{}
+	withBase := 0
+
+	for _, entry := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if parts[0] == "" || len(parts) > 3 {
+			return nil, fmt.Errorf("invalid capacity provider %q, expected NAME[:WEIGHT[:BASE]]", entry)
+		}
+		if seen[parts[0]] {
+			return nil, fmt.Errorf("capacity provider %s is listed more than once", parts[0])
+		}
+		seen[parts[0]] = true
+
+		item := &ecs.CapacityProviderStrategyItem{
+			CapacityProvider: aws.String(parts[0]),
+			Weight:           aws.Int64(1),
+		}
+
+		if len(parts) > 1 {
+			weight, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil || weight < 0 || weight > 1000 {
+				return nil, fmt.Errorf("invalid weight %q for capacity provider %s, expected 0-1000", parts[1], parts[0])
+			}
+			item.Weight = aws.Int64(weight)
+		}
+
+		if len(parts) > 2 {
+			base, err := strconv.ParseInt(parts[2], 10, 64)
+			if err != nil || base < 0 || base > 100000 {
+				return nil, fmt.Errorf("invalid base %q for capacity provider %s, expected 0-100000", parts[2], parts[0])
+			}
+			item.Base = aws.Int64(base)
+			if base > 0 {
+				withBase++
+			}
+		}
+
+		strategy = append(strategy, item)
+	}
+
+	if withBase > 1 {
+		return nil, errors.New("only one capacity provider can have a base")
+	}
+
+	totalWeight := int64(0)
+	for _, item := range strategy {
+		totalWeight += *item.Weight
+	}
+	if totalWeight == 0 {
+		return nil, errors.New("at least one capacity provider needs a weight above 0")
+	}
+
+	return strategy, nil
+}
+
+// applyCapacityStrategy replaces the launch type of input with the requested capacity provider
+// strategy, or with the cluster's default strategy
+func (c *Client) applyCapacityStrategy(ctx context.Context, t *Task, input *ecs.RunTaskInput) error {
+	if t.CapacityProvider == "" && !t.UseClusterDefaultCapacity {
+		return nil
+	}
+	if t.CapacityProvider != "" && t.UseClusterDefaultCapacity {
+		return errors.New("pass either a capacity provider strategy or use the cluster's default, not both")
+	}
+
+	// without a cluster ECS describes and runs on the default one
+	name := t.Cluster
+	describeInput := &ecs.DescribeClustersInput{}
+	if name == "" {
+		name = "default"
+	} else {
+		describeInput.Clusters = aws.StringSlice([]string{t.Cluster})
+	}
+
+	output, err := c.ECS.DescribeClustersWithContext(ctx, describeInput)
+	if err != nil {
+		return err
+	}
+	if len(output.Clusters) == 0 {
+		return fmt.Errorf("unable to find cluster %s", name)
+	}
+	cluster := output.Clusters[0]
+
+	input.LaunchType = nil
+
+	if t.UseClusterDefaultCapacity {
+		if len(cluster.DefaultCapacityProviderStrategy) == 0 {
+			return fmt.Errorf("cluster %s has no default capacity provider strategy", name)
+		}
+		// omitting both the launch type and the strategy uses the cluster's default
+		input.CapacityProviderStrategy = nil
+		return nil
+	}
+
+	strategy, err := ParseCapacityProviderStrategy(t.CapacityProvider)
+	if err != nil {
+		return err
+	}
+	if err := validateCapacityProviders(strategy, aws.StringValueSlice(cluster.CapacityProviders)); err != nil {
+		return fmt.Errorf("cluster %s: %s", name, err)
+	}
+
+	input.CapacityProviderStrategy = strategy
+	return nil
+}
+
+// validateCapacityProviders checks every provider of a strategy is associated with the cluster
+func validateCapacityProviders(strategy []*ecs.CapacityProviderStrategyItem, clusterProviders []string) error {
+	associated := map[string]bool{}
+	for _, p := range clusterProviders {
+		associated[p] = true
+	}
+
+	for _, item := range strategy {
+		name := aws.StringValue(item.CapacityProvider)
+		if !associated[name] {
+			return fmt.Errorf("capacity provider %s is not associated with the cluster (available: %s)", name, strings.Join(clusterProviders, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package ecs
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/justmiles/ecs-cli/lib/fake"
+)
+
+func TestParseCapacityProviderStrategy(t *testing.T) {
+	strategy, err := ParseCapacityProviderStrategy("FARGATE_SPOT:3:1,FARGATE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(strategy) != 2 {
+		t.Fatalf("expected two providers, got %v", strategy)
+	}
+	if aws.StringValue(strategy[0].CapacityProvider) != "FARGATE_SPOT" || aws.Int64Value(strategy[0].Weight) != 3 || aws.Int64Value(strategy[0].Base) != 1 {
+		t.Errorf("unexpected provider %v", strategy[0])
+	}
+	if aws.Int64Value(strategy[1].Weight) != 1 || strategy[1].Base != nil {
+		t.Errorf("expected the default weight and no base, got %v", strategy[1])
+	}
+
+	for _, invalid := range []string{
+		"",
+		"FARGATE:x",
+		"FARGATE:1:1:1",
+		"FARGATE:1001",
+		"FARGATE,FARGATE",
+		"FARGATE:1:1,FARGATE_SPOT:1:1",
+		"FARGATE:0,FARGATE_SPOT:0",
+	} {
+		if _, err := ParseCapacityProviderStrategy(invalid); err == nil {
+			t.Errorf("expected %q to fail", invalid)
+		}
+	}
+}
+
+func TestValidateCapacityProviders(t *testing.T) {
+	strategy, _ := ParseCapacityProviderStrategy("FARGATE_SPOT:1")
+
+	if err := validateCapacityProviders(strategy, []string{"FARGATE", "FARGATE_SPOT"}); err != nil {
+		t.Errorf("got: %v", err)
+	}
+	if err := validateCapacityProviders(strategy, []string{"FARGATE"}); err == nil {
+		t.Errorf("expected a provider missing from the cluster to fail")
+	}
+}
+
+func TestApplyCapacityStrategyDefaultCluster(t *testing.T) {
+	b := fake.New()
+	b.ECS.AddCluster("default", "FARGATE")
+	c := NewClient(b.ECS.Region, b.ECS, b.Logs, b.EC2)
+
+	input := &ecs.RunTaskInput{LaunchType: aws.String(ecs.LaunchTypeFargate)}
+	if err := c.applyCapacityStrategy(context.Background(), &Task{CapacityProvider: "FARGATE:1"}, input); err != nil {
+		t.Fatal(err)
+	}
+	if input.LaunchType != nil || len(input.CapacityProviderStrategy) != 1 {
+		t.Errorf("expected the strategy to replace the launch type, got %v", input)
+	}
+
+	err := c.applyCapacityStrategy(context.Background(), &Task{CapacityProvider: "FARGATE_SPOT:1"}, &ecs.RunTaskInput{})
+	if err == nil || !strings.HasPrefix(err.Error(), "cluster default: ") {
+		t.Errorf("expected the default cluster to be named, got %v", err)
+	}
+}
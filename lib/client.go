@@ -0,0 +1,130 @@
+package ecs
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+// defaultPollInterval is how often tasks and log streams are polled
+const defaultPollInterval = 5 * time.Second
+
+// Client runs and inspects ECS tasks. It never prints or exits: progress is reported to
+// Events and failures are returned to the caller.
+type Client struct {
+	ECS            ecsiface.ECSAPI
+	CloudWatchLogs cloudwatchlogsiface.CloudWatchLogsAPI
+	EC2            ec2iface.EC2API
+
+	// Only needed for secrets (SSM, STS and IAM), port forwarding (SSM) and publishing the
+	// ports of an image (ECR)
+	SSM ssmiface.SSMAPI
+	STS stsiface.STSAPI
+	IAM iamiface.IAMAPI
+	ECR ecriface.ECRAPI
+
+	// Region the clients talk to, used for log configuration and ARNs
+	Region string
+
+	// Events receives progress, nil discards it
+	Events Renderer
+
+	// PollInterval between checks on running tasks, 5s when unset
+	PollInterval time.Duration
+
+	// regionalECR returns an ECR client for images hosted in another region
+	regionalECR func(region string) ecriface.ECRAPI
+}
+
+// NewClient builds a client from the ECS, CloudWatch Logs and EC2 implementations to use
+func NewClient(region string, ecsAPI ecsiface.ECSAPI, logsAPI cloudwatchlogsiface.CloudWatchLogsAPI, ec2API ec2iface.EC2API) *Client {
+	return &Client{
+		ECS:            ecsAPI,
+		CloudWatchLogs: logsAPI,
+		EC2:            ec2API,
+		Region:         region,
+	}
+}
+
+// NewSessionClient builds a client with every AWS service from a session, reaching each service
+// at its endpoint when set
+func NewSessionClient(sess *session.Session, endpoints Endpoints, cfgs ...*aws.Config) *Client {
+	with := func(endpoint string) []*aws.Config {
+		configs := append([]*aws.Config{}, cfgs...)
+		if endpoint != "" {
+			configs = append(configs, aws.NewConfig().WithEndpoint(endpoint))
+		}
+		return configs
+	}
+
+	c := NewClient(aws.StringValue(sess.Config.Region), ecs.New(sess, with(endpoints.ECS)...), cloudwatchlogs.New(sess, with(endpoints.Logs)...), ec2.New(sess, with(endpoints.EC2)...))
+	c.SSM = ssm.New(sess, with(endpoints.SSM)...)
+	c.STS = sts.New(sess, with(endpoints.STS)...)
+	c.IAM = iam.New(sess, with(endpoints.IAM)...)
+	c.ECR = ecr.New(sess, with(endpoints.ECR)...)
+	c.regionalECR = func(region string) ecriface.ECRAPI {
+		return ecr.New(sess, append(with(endpoints.ECR), aws.NewConfig().WithRegion(region))...)
+	}
+	return c
+}
+
+func (c *Client) emit(e Event) {
+	if c.Events == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	c.Events.Event(e)
+}
+
+func (c *Client) logInfo(s string) {
+	if c.Events != nil {
+		c.Events.Info(s)
+	}
+}
+
+func (c *Client) logWarning(s string) {
+	if c.Events != nil {
+		c.Events.Warning(s)
+	}
+}
+
+func (c *Client) logError(err error) {
+	if err != nil && c.Events != nil {
+		c.Events.Error(err.Error())
+	}
+}
+
+func (c *Client) pollInterval() time.Duration {
+	if c.PollInterval > 0 {
+		return c.PollInterval
+	}
+	return defaultPollInterval
+}
+
+// sleep waits for the poll interval unless the context is done first
+func (c *Client) sleep(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(c.pollInterval()):
+		return nil
+	}
+}
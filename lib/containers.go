@@ -0,0 +1,164 @@
+package ecs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// ParseContainer parses a comma separated KEY=VALUE description of an additional container
+// (eg name=proxy,image=envoyproxy/envoy,essential=true,publish=9901,env=LEVEL=debug,depends-on=app:START).
+// env, publish and depends-on may be repeated.
+func ParseContainer(s string) (Container, error) {
+	var c Container
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return c, fmt.Errorf("unable to parse container option %q", pair)
+		}
+
+		switch kv[0] {
+		case "name":
+			c.Name = kv[1]
+		case "image":
+			c.Image = kv[1]
+		case "env":
+			c.Environment = append(c.Environment, kv[1])
+		case "publish":
+			c.Publish = append(c.Publish, kv[1])
+		case "depends-on":
+			c.DependsOn = append(c.DependsOn, kv[1])
+		case "essential":
+			essential, err := strconv.ParseBool(kv[1])
+			if err != nil {
+				return c, fmt.Errorf("invalid value for essential: %s", kv[1])
+			}
+			c.Essential = essential
+		case "cpu":
+			cpu, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return c, fmt.Errorf("invalid value for cpu: %s", kv[1])
+			}
+			c.CPUReservation = cpu
+		case "memory-reservation":
+			memory, err := strconv.ParseInt(kv[1], 10, 64)
+			if err != nil {
+				return c, fmt.Errorf("invalid value for memory-reservation: %s", kv[1])
+			}
+			c.MemoryReservation = memory
+		default:
+			return c, fmt.Errorf("unknown container option %q", kv[0])
+		}
+	}
+
+	if c.Name == "" || c.Image == "" {
+		return c, fmt.Errorf("a container requires a name and an image: %s", s)
+	}
+
+	return c, nil
+}
+
+// buildContainerDefinition builds the definition for an additional container
+func (c *Client) buildContainerDefinition(t *Task, container Container) (*ecs.ContainerDefinition, error) {
+	dependsOn, err := buildContainerDependencies(container.DependsOn)
+	if err != nil {
+		return nil, fmt.Errorf("container %s: %s", container.Name, err)
+	}
+
+	portMappings, err := c.buildPortMapping(container.Publish)
+	if err != nil {
+		return nil, fmt.Errorf("container %s: %s", container.Name, err)
+	}
+
+	def := &ecs.ContainerDefinition{
+		Name:    aws.String(container.Name),
+		Image:   aws.String(container.Image),
+		Command: aws.StringSlice(container.Command),
+		LogConfiguration: &ecs.LogConfiguration{
+			LogDriver: aws.String("awslogs"),
+			Options: aws.StringMap(map[string]string{
+				"awslogs-group":         t.LogGroupName,
+				"awslogs-region":        c.Region,
+				"awslogs-stream-prefix": t.Name,
+			}),
+		},
+		Essential:    aws.Bool(container.Essential),
+		Environment:  buildEnvironmentKeyValuePair(container.Environment),
+		PortMappings: portMappings,
+		MountPoints:  []*ecs.MountPoint{},
+		VolumesFrom:  []*ecs.VolumeFrom{},
+		DependsOn:    dependsOn,
+	}
+
+	if container.CPUReservation > 0 {
+		def.Cpu = aws.Int64(container.CPUReservation)
+	}
+
+	if container.MemoryReservation > 0 {
+		def.MemoryReservation = aws.Int64(container.MemoryReservation)
+	}
+
+	return def, nil
+}
+
+// buildContainerDependencies converts NAME:CONDITION pairs into container dependencies
+func buildContainerDependencies(dependsOn []string) (d []*ecs.ContainerDependency, err error) {
+	for _, dep := range dependsOn {
+		s := strings.SplitN(dep, ":", 2)
+		condition := ecs.ContainerConditionStart
+		if len(s) == 2 {
+			condition = strings.ToUpper(s[1])
+		}
+
+		if !isValidContainerCondition(condition) {
+			return nil, fmt.Errorf("invalid dependency condition %q, expected one of %s", condition, strings.Join(ecs.ContainerCondition_Values(), ", "))
+		}
+
+		d = append(d, &ecs.ContainerDependency{
+			ContainerName: aws.String(s[0]),
+			Condition:     aws.String(condition),
+		})
+	}
+	return d, nil
+}
+
+func isValidContainerCondition(condition string) bool {
+	for _, c := range ecs.ContainerCondition_Values() {
+		if c == condition {
+			return true
+		}
+	}
+	return false
+}
+
+// mainContainerName returns the container whose exit code is mirrored by the CLI: the first
+// essential container of the task definition
+func (t *Task) mainContainerName() string {
+	for _, def := range t.TaskDefinition.ContainerDefinitions {
+		if aws.BoolValue(def.Essential) {
+			return aws.StringValue(def.Name)
+		}
+	}
+	if len(t.TaskDefinition.ContainerDefinitions) > 0 {
+		return aws.StringValue(t.TaskDefinition.ContainerDefinitions[0].Name)
+	}
+	return ""
+}
+
+// streamableContainers returns the containers whose logs can be read from CloudWatch Logs
+func (t *Task) streamableContainers() (defs []*ecs.ContainerDefinition) {
+	for _, def := range t.TaskDefinition.ContainerDefinitions {
+		lc := def.LogConfiguration
+		if lc == nil || aws.StringValue(lc.LogDriver) != "awslogs" {
+			continue
+		}
+		if lc.Options["awslogs-group"] == nil || lc.Options["awslogs-stream-prefix"] == nil {
+			continue
+		}
+		defs = append(defs, def)
+	}
+	return defs
+}
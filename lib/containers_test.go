@@ -0,0 +1,54 @@
+package ecs
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+func TestParseContainer(t *testing.T) {
+	c, err := ParseContainer("name=proxy,image=envoyproxy/envoy,essential=true,env=A=1,env=B=2,depends-on=app:healthy")
+	if err != nil {
+		t.Fatalf("got: %v", err)
+	}
+	if c.Name != "proxy" || c.Image != "envoyproxy/envoy" || !c.Essential {
+		t.Errorf("unexpected container %+v", c)
+	}
+	if len(c.Environment) != 2 || c.Environment[0] != "A=1" {
+		t.Errorf("unexpected environment %v", c.Environment)
+	}
+
+	if _, err := ParseContainer("name=proxy"); err == nil {
+		t.Errorf("expected a missing image to fail")
+	}
+}
+
+func TestContainerDependencies(t *testing.T) {
+	if _, err := buildContainerDependencies([]string{"proxy:READY"}); err == nil {
+		t.Errorf("expected an unknown condition to fail")
+	}
+
+	deps, err := buildContainerDependencies([]string{"proxy:healthy", "init"})
+	if err != nil {
+		t.Fatalf("got: %v", err)
+	}
+	if *deps[0].Condition != "HEALTHY" || *deps[1].Condition != "START" {
+		t.Errorf("unexpected conditions %v", deps)
+	}
+
+	taskDef := &ecs.RegisterTaskDefinitionInput{
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{Name: aws.String("app"), Image: aws.String("app"), Essential: aws.Bool(true), Memory: aws.Int64(128), DependsOn: deps},
+			{Name: aws.String("proxy"), Image: aws.String("proxy"), Memory: aws.Int64(128)},
+		},
+	}
+	if err := Validate(taskDef, nil); err == nil {
+		t.Errorf("expected a dependency on a missing container to fail")
+	}
+
+	taskDef.ContainerDefinitions = append(taskDef.ContainerDefinitions, &ecs.ContainerDefinition{Name: aws.String("init"), Image: aws.String("init"), Memory: aws.Int64(128)})
+	if err := Validate(taskDef, nil); err != nil {
+		t.Errorf("got: %v", err)
+	}
+}
@@ -0,0 +1,480 @@
+package ecs
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/justmiles/ecs-cli/lib/session"
+)
+
+// CopyTarget is a path inside a container: [[CLUSTER/]TASK[:CONTAINER]]:/PATH
+type CopyTarget struct {
+	Cluster   string
+	Task      string
+	Container string
+	Path      string
+}
+
+// ParseCopyTarget parses a container path. ok is false when s is a local path, which like docker
+// cp includes any path starting with / or ., eg ./x:/y. Omitted parts are left empty so they can
+// be prompted for.
+func ParseCopyTarget(s string) (target CopyTarget, ok bool, err error) {
+	if strings.HasPrefix(s, "/") || strings.HasPrefix(s, ".") || filepath.IsAbs(s) {
+		return target, false, nil
+	}
+
+	i := strings.Index(s, ":/")
+	if i < 0 {
+		return target, false, nil
+	}
+
+	target.Path = s[i+1:]
+	ref := s[:i]
+
+	if j := strings.Index(ref, "/"); j >= 0 {
+		target.Cluster = ref[:j]
+		ref = ref[j+1:]
+	}
+
+	parts := strings.Split(ref, ":")
+	if len(parts) > 2 {
+		return target, true, fmt.Errorf("invalid container path %q, expected [[CLUSTER/]TASK[:CONTAINER]]:/PATH", s)
+	}
+	target.Task = parts[0]
+	if len(parts) == 2 {
+		target.Container = parts[1]
+	}
+
+	return target, true, nil
+}
+
+// CopyFromContainer copies a file or directory out of a container. Like docker cp, the source
+// is copied into localPath when it is an existing directory, and renamed to it otherwise.
+func (c *Client) CopyFromContainer(ctx context.Context, input *ExecInput, remotePath, localPath string) error {
+	remotePath = path.Clean(remotePath)
+	dir, base := path.Split(remotePath)
+
+	// the exit status of the pipeline is that of base64, so check the source exists first
+	command := fmt.Sprintf("test -e %[1]s || { echo %[1]s: No such file or directory; exit 1; }; tar cf - -C %[2]s %[3]s | base64",
+		shellQuote(remotePath), shellQuote(dir), shellQuote(base))
+	sess, err := c.openCommandSession(ctx, input, wrapCommand(command))
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	progress := newCopyProgress(input.Progress, 0)
+	defer progress.stop()
+
+	// separate the base64 encoded archive from the exit status and any error messages
+	encoded, writer := io.Pipe()
+	result := make(chan error, 1)
+	var remoteOutput []string
+	var exitCode = -1
+	go func() {
+		scanner := bufio.NewScanner(sess)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := strings.TrimRight(scanner.Text(), "\r")
+			switch {
+			case strings.HasPrefix(line, exitCodeMarker):
+				_, exitCode = parseExitCode(line)
+			case isBase64Line(line):
+				writer.Write([]byte(line))
+			case line != "":
+				remoteOutput = append(remoteOutput, line)
+			}
+		}
+		writer.CloseWithError(scanner.Err())
+		result <- scanner.Err()
+	}()
+
+	archive := tar.NewReader(io.TeeReader(base64.NewDecoder(base64.StdEncoding, encoded), progress))
+	extracted, extractErr := extractArchive(archive, base, localPath)
+
+	// drain so the scanner reaches the exit status
+	io.Copy(io.Discard, encoded)
+	if err := <-result; err != nil {
+		return err
+	}
+
+	if exitCode != 0 || (extracted == 0 && len(remoteOutput) > 0) {
+		return fmt.Errorf("unable to copy %s: %s", remotePath, remoteFailure(remoteOutput, exitCode))
+	}
+	return extractErr
+}
+
+// CopyToContainer copies a local file or directory into a container. The source is copied
+// into remotePath when it ends with a slash, and renamed to it otherwise.
+func (c *Client) CopyToContainer(ctx context.Context, input *ExecInput, localPath, remotePath string) error {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+
+	dir, name := path.Dir(remotePath), path.Base(remotePath)
+	if strings.HasSuffix(remotePath, "/") {
+		dir, name = path.Clean(remotePath), filepath.Base(localPath)
+	}
+
+	// the pseudo terminal must not echo the archive back, ^D ends the input
+	command := fmt.Sprintf("stty -echo 2>/dev/null; base64 -d | tar xf - -C %s", shellQuote(dir))
+	sess, err := c.openCommandSession(ctx, input, wrapCommand(command))
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	total, err := localSize(localPath, info)
+	if err != nil {
+		return err
+	}
+	progress := newCopyProgress(input.Progress, total)
+	defer progress.stop()
+
+	// stream the archive as base64 lines, the terminal discards overly long lines
+	archive, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writeArchive(tar.NewWriter(writer), localPath, name, progress))
+	}()
+
+	sendErr := make(chan error, 1)
+	go func() {
+		sendErr <- sendBase64Lines(sess, archive)
+	}()
+
+	var remoteOutput []string
+	exitCode := -1
+	scanner := bufio.NewScanner(sess)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		switch {
+		case strings.HasPrefix(line, exitCodeMarker):
+			_, exitCode = parseExitCode(line)
+		case line != "" && !isBase64Line(line):
+			remoteOutput = append(remoteOutput, line)
+		}
+	}
+
+	select {
+	case err := <-sendErr:
+		if err != nil {
+			return err
+		}
+	default:
+	}
+
+	if exitCode != 0 {
+		return fmt.Errorf("unable to copy to %s: %s", remotePath, remoteFailure(remoteOutput, exitCode))
+	}
+	return scanner.Err()
+}
+
+// openCommandSession starts a command in the container and returns its session
+func (c *Client) openCommandSession(ctx context.Context, input *ExecInput, command string) (*session.Session, error) {
+	output, err := c.ECS.ExecuteCommandWithContext(ctx, &ecs.ExecuteCommandInput{
+		Cluster:     aws.String(input.Cluster),
+		Task:        aws.String(input.Task),
+		Container:   aws.String(input.Container),
+		Command:     aws.String(command),
+		Interactive: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err := session.Open(*output.Session.StreamUrl, *output.Session.TokenValue)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sess.WaitForHandshake(); err != nil {
+		sess.Close()
+		return nil, err
+	}
+	return sess, nil
+}
+
+// sendBase64Lines encodes r as base64 lines followed by ^D, which ends the remote input
+func sendBase64Lines(w io.Writer, r io.Reader) error {
+	buf := make([]byte, 57*64)
+	line := make([]byte, base64.StdEncoding.EncodedLen(57)+1)
+	for {
+		n, err := io.ReadFull(r, buf)
+		for i := 0; i < n; i += 57 {
+			end := i + 57
+			if end > n {
+				end = n
+			}
+			base64.StdEncoding.Encode(line, buf[i:end])
+			l := base64.StdEncoding.EncodedLen(end - i)
+			line[l] = '\n'
+			if _, err := w.Write(line[:l+1]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write([]byte{4})
+	return err
+}
+
+// writeArchive writes localPath to the archive with its top level entry renamed to name
+func writeArchive(tw *tar.Writer, localPath, name string, progress io.Writer) error {
+	root := filepath.Clean(localPath)
+	err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		header.Name = path.Join(name, filepath.ToSlash(rel))
+		if info.IsDir() {
+			header.Name += "/"
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(io.MultiWriter(tw, progress), f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// extractArchive extracts an archive whose top level entry is named base into localPath and
+// returns the number of entries extracted
+func extractArchive(tr *tar.Reader, base, localPath string) (int, error) {
+	dest := filepath.Clean(localPath)
+	if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+		dest = filepath.Join(localPath, base)
+	}
+
+	extracted := 0
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return extracted, nil
+		}
+		if err != nil {
+			return extracted, err
+		}
+
+		rel := strings.TrimPrefix(path.Clean(header.Name), base)
+		target := filepath.Join(dest, filepath.FromSlash(rel))
+		if !within(dest, target) {
+			return extracted, fmt.Errorf("refusing to extract %s outside of %s", header.Name, dest)
+		}
+		// a symlink entry replaces an existing symlink, anything else would write through it
+		if err := checkNoSymlinks(dest, target, header.Typeflag != tar.TypeSymlink); err != nil {
+			return extracted, err
+		}
+		mode := os.FileMode(header.Mode).Perm()
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, mode); err != nil {
+				return extracted, err
+			}
+			os.Chmod(target, mode)
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return extracted, err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+			if err != nil {
+				return extracted, err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return extracted, err
+			}
+			// the mode passed to OpenFile only applies to new files and is subject to the umask
+			os.Chmod(target, mode)
+		case tar.TypeSymlink:
+			link := filepath.FromSlash(header.Linkname)
+			if !filepath.IsAbs(link) {
+				link = filepath.Join(filepath.Dir(target), link)
+			}
+			if !within(dest, filepath.Clean(link)) {
+				return extracted, fmt.Errorf("refusing to extract %s, it links to %s outside of %s", header.Name, header.Linkname, dest)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return extracted, err
+			}
+			os.Remove(target)
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return extracted, err
+			}
+		}
+		extracted++
+	}
+}
+
+// within reports whether target is dest or inside it, lexically
+func within(dest, target string) bool {
+	return target == dest || strings.HasPrefix(target, dest+string(filepath.Separator))
+}
+
+// checkNoSymlinks refuses to write target through a symlink extracted earlier, checking every
+// path below dest, and target itself unless it is replaced
+func checkNoSymlinks(dest, target string, checkTarget bool) error {
+	rel, err := filepath.Rel(dest, target)
+	if err != nil || rel == "." {
+		return err
+	}
+
+	current := dest
+	parts := strings.Split(rel, string(filepath.Separator))
+	if !checkTarget {
+		parts = parts[:len(parts)-1]
+	}
+	for _, part := range parts {
+		current = filepath.Join(current, part)
+		if info, err := os.Lstat(current); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("refusing to extract %s through the symlink %s", target, current)
+		}
+	}
+	return nil
+}
+
+func localSize(localPath string, info os.FileInfo) (int64, error) {
+	if !info.IsDir() {
+		return info.Size(), nil
+	}
+
+	var size int64
+	err := filepath.Walk(localPath, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return err
+	})
+	return size, err
+}
+
+func isBase64Line(line string) bool {
+	if line == "" {
+		return false
+	}
+	for _, c := range line {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '+' || c == '/' || c == '=') {
+			return false
+		}
+	}
+	return true
+}
+
+func remoteFailure(output []string, exitCode int) string {
+	if len(output) > 0 {
+		return strings.Join(output, "\n")
+	}
+	if exitCode < 0 {
+		return "the session ended before the command completed"
+	}
+	return fmt.Sprintf("exit code %d", exitCode)
+}
+
+// shellQuote quotes s for /bin/sh
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
+}
+
+// copyProgress periodically reports the number of bytes copied
+type copyProgress struct {
+	w      io.Writer
+	total  int64
+	copied int64
+	done   chan struct{}
+}
+
+// newCopyProgress reports to w, nothing is reported when w is nil
+func newCopyProgress(w io.Writer, total int64) *copyProgress {
+	p := &copyProgress{w: w, total: total, done: make(chan struct{})}
+	if w == nil {
+		return p
+	}
+
+	go func() {
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.done:
+				p.print()
+				fmt.Fprintln(p.w)
+				return
+			case <-ticker.C:
+				p.print()
+			}
+		}
+	}()
+	return p
+}
+
+func (p *copyProgress) Write(b []byte) (int, error) {
+	atomic.AddInt64(&p.copied, int64(len(b)))
+	return len(b), nil
+}
+
+func (p *copyProgress) print() {
+	copied := uint64(atomic.LoadInt64(&p.copied))
+	if p.total > 0 {
+		fmt.Fprintf(p.w, "\rCopied %s of %s", humanize.Bytes(copied), humanize.Bytes(uint64(p.total)))
+		return
+	}
+	fmt.Fprintf(p.w, "\rCopied %s", humanize.Bytes(copied))
+}
+
+func (p *copyProgress) stop() {
+	close(p.done)
+}
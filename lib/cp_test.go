@@ -0,0 +1,126 @@
+package ecs
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseCopyTarget(t *testing.T) {
+	target, ok, err := ParseCopyTarget("qa/0123abcd:app:/var/log/app.log")
+	if !ok || err != nil {
+		t.Fatalf("got: %v %v", ok, err)
+	}
+	if target != (CopyTarget{Cluster: "qa", Task: "0123abcd", Container: "app", Path: "/var/log/app.log"}) {
+		t.Errorf("unexpected target %+v", target)
+	}
+
+	target, ok, _ = ParseCopyTarget(":/tmp/heap.hprof")
+	if !ok || target.Task != "" || target.Path != "/tmp/heap.hprof" {
+		t.Errorf("expected everything but the path to be prompted for, got %+v", target)
+	}
+
+	for _, local := range []string{"./report.csv", "/tmp/a:/b", "./x:/y", "../x:/y", ".hidden:/y"} {
+		if _, ok, _ := ParseCopyTarget(local); ok {
+			t.Errorf("expected %s to be a local path", local)
+		}
+	}
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "reports")
+	os.MkdirAll(filepath.Join(src, "daily"), 0755)
+	os.WriteFile(filepath.Join(src, "daily", "run.sh"), []byte("#!/bin/sh\necho hi\n"), 0750)
+
+	// encode the archive the way it is sent through the session
+	var archive bytes.Buffer
+	if err := writeArchive(tar.NewWriter(&archive), src, "copied", io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	var encoded bytes.Buffer
+	if err := sendBase64Lines(&encoded, &archive); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(encoded.String(), "\x04"), "\n")
+	for _, line := range lines {
+		if len(line) > 76 || (line != "" && !isBase64Line(line)) {
+			t.Fatalf("unexpected line %q", line)
+		}
+	}
+
+	dest := t.TempDir()
+	decoded := base64.NewDecoder(base64.StdEncoding, strings.NewReader(strings.Join(lines, "")))
+	if _, err := extractArchive(tar.NewReader(decoded), "copied", dest); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(filepath.Join(dest, "copied", "daily", "run.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0750 {
+		t.Errorf("expected the file mode to be preserved, got %v", info.Mode())
+	}
+}
+
+func TestExtractArchiveSymlinks(t *testing.T) {
+	archive := func(entries ...*tar.Header) *tar.Reader {
+		var b bytes.Buffer
+		tw := tar.NewWriter(&b)
+		for _, header := range entries {
+			header.Mode = 0644
+			tw.WriteHeader(header)
+			if header.Typeflag == tar.TypeReg {
+				tw.Write([]byte("pwned"))
+			}
+		}
+		tw.Close()
+		return tar.NewReader(&b)
+	}
+
+	outside := t.TempDir()
+	for name, tr := range map[string]*tar.Reader{
+		"absolute link": archive(
+			&tar.Header{Name: "copied/x", Typeflag: tar.TypeSymlink, Linkname: outside},
+		),
+		"relative link": archive(
+			&tar.Header{Name: "copied/x", Typeflag: tar.TypeSymlink, Linkname: "../../escape"},
+		),
+		"write through a link": archive(
+			&tar.Header{Name: "copied/x", Typeflag: tar.TypeSymlink, Linkname: "y"},
+			&tar.Header{Name: "copied/x/passwd", Typeflag: tar.TypeReg, Size: 5},
+		),
+		"overwrite a link": archive(
+			&tar.Header{Name: "copied/x", Typeflag: tar.TypeSymlink, Linkname: "y"},
+			&tar.Header{Name: "copied/x", Typeflag: tar.TypeReg, Size: 5},
+		),
+	} {
+		dest := t.TempDir()
+		if _, err := extractArchive(tr, "copied", dest); err == nil || !strings.Contains(err.Error(), "refusing") {
+			t.Errorf("%s: expected the archive to be refused, got %v", name, err)
+		}
+	}
+
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("expected nothing to be written outside of the destination, got %v", entries)
+	}
+
+	// links within the destination are kept
+	dest := t.TempDir()
+	tr := archive(
+		&tar.Header{Name: "copied/current", Typeflag: tar.TypeSymlink, Linkname: "releases/1"},
+		&tar.Header{Name: "copied/releases/1/app", Typeflag: tar.TypeReg, Size: 5},
+	)
+	if _, err := extractArchive(tr, "copied", dest); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dest, "copied", "current", "app")); err != nil || string(b) != "pwned" {
+		t.Errorf("expected the link to resolve, got %q %v", b, err)
+	}
+}
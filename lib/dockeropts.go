@@ -0,0 +1,282 @@
+package ecs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// applyDockerOptions maps the docker run options of a task onto its main container
+func (t *Task) applyDockerOptions(def *ecs.ContainerDefinition) error {
+	if t.EntryPoint != "" {
+		def.EntryPoint = aws.StringSlice([]string{t.EntryPoint})
+	}
+	if t.WorkingDirectory != "" {
+		def.WorkingDirectory = aws.String(t.WorkingDirectory)
+	}
+	if t.User != "" {
+		def.User = aws.String(t.User)
+	}
+	if t.Hostname != "" {
+		def.Hostname = aws.String(t.Hostname)
+	}
+	if t.Privileged {
+		def.Privileged = aws.Bool(true)
+	}
+	if t.ReadOnly {
+		def.ReadonlyRootFilesystem = aws.Bool(true)
+	}
+	if t.Tty {
+		def.PseudoTerminal = aws.Bool(true)
+	}
+	if t.StopTimeout > 0 {
+		def.StopTimeout = aws.Int64(t.StopTimeout)
+	}
+	if len(t.DNS) > 0 {
+		def.DnsServers = aws.StringSlice(t.DNS)
+	}
+
+	if len(t.Labels) > 0 {
+		def.DockerLabels = map[string]*string{}
+		for _, label := range t.Labels {
+			pair := strings.SplitN(label, "=", 2)
+			if pair[0] == "" {
+				return fmt.Errorf("invalid label %q, expected KEY=VALUE", label)
+			}
+			value := ""
+			if len(pair) == 2 {
+				value = pair[1]
+			}
+			def.DockerLabels[pair[0]] = aws.String(value)
+		}
+	}
+
+	for _, ulimit := range t.Ulimits {
+		u, err := parseUlimit(ulimit)
+		if err != nil {
+			return err
+		}
+		def.Ulimits = append(def.Ulimits, u)
+	}
+
+	for _, sysctl := range t.Sysctls {
+		pair := strings.SplitN(sysctl, "=", 2)
+		if len(pair) != 2 || pair[0] == "" {
+			return fmt.Errorf("invalid sysctl %q, expected NAME=VALUE", sysctl)
+		}
+		def.SystemControls = append(def.SystemControls, &ecs.SystemControl{
+			Namespace: aws.String(pair[0]),
+			Value:     aws.String(pair[1]),
+		})
+	}
+
+	for _, host := range t.AddHosts {
+		// the address may be IPv6, so split on the first colon only
+		pair := strings.SplitN(host, ":", 2)
+		if len(pair) != 2 || pair[0] == "" || pair[1] == "" {
+			return fmt.Errorf("invalid host %q, expected HOST:IP", host)
+		}
+		def.ExtraHosts = append(def.ExtraHosts, &ecs.HostEntry{
+			Hostname:  aws.String(pair[0]),
+			IpAddress: aws.String(pair[1]),
+		})
+	}
+
+	if t.HealthCmd != "" {
+		healthCheck, err := t.buildHealthCheck()
+		if err != nil {
+			return err
+		}
+		def.HealthCheck = healthCheck
+	} else if t.HealthInterval != "" || t.HealthTimeout != "" || t.HealthStartPeriod != "" || t.HealthRetries > 0 {
+		return errors.New("health check options require --health-cmd")
+	}
+
+	linux := &ecs.LinuxParameters{}
+	hasLinux := false
+
+	if len(t.CapAdd) > 0 || len(t.CapDrop) > 0 {
+		linux.Capabilities = &ecs.KernelCapabilities{
+			Add:  aws.StringSlice(normalizeCapabilities(t.CapAdd)),
+			Drop: aws.StringSlice(normalizeCapabilities(t.CapDrop)),
+		}
+		hasLinux = true
+	}
+
+	if t.Init {
+		linux.InitProcessEnabled = aws.Bool(true)
+		hasLinux = true
+	}
+
+	if t.ShmSize != "" {
+		size, err := parseMiB(t.ShmSize)
+		if err != nil {
+			return fmt.Errorf("invalid shm-size: %s", err)
+		}
+		linux.SharedMemorySize = aws.Int64(size)
+		hasLinux = true
+	}
+
+	for _, tmpfs := range t.Tmpfs {
+		mount, err := parseTmpfs(tmpfs)
+		if err != nil {
+			return err
+		}
+		linux.Tmpfs = append(linux.Tmpfs, mount)
+		hasLinux = true
+	}
+
+	if hasLinux {
+		def.LinuxParameters = linux
+	}
+	return nil
+}
+
+func (t *Task) buildHealthCheck() (*ecs.HealthCheck, error) {
+	healthCheck := &ecs.HealthCheck{
+		Command: aws.StringSlice([]string{"CMD-SHELL", t.HealthCmd}),
+	}
+
+	for _, option := range []struct {
+		name  string
+		value string
+		dst   **int64
+	}{
+		{"health-interval", t.HealthInterval, &healthCheck.Interval},
+		{"health-timeout", t.HealthTimeout, &healthCheck.Timeout},
+		{"health-start-period", t.HealthStartPeriod, &healthCheck.StartPeriod},
+	} {
+		if option.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(option.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", option.name, err)
+		}
+		*option.dst = aws.Int64(int64(d.Seconds()))
+	}
+
+	if t.HealthRetries > 0 {
+		healthCheck.Retries = aws.Int64(t.HealthRetries)
+	}
+	return healthCheck, nil
+}
+
+// parseUlimit parses NAME=SOFT[:HARD], eg nofile=1024:4096
+func parseUlimit(s string) (*ecs.Ulimit, error) {
+	pair := strings.SplitN(s, "=", 2)
+	if len(pair) != 2 || pair[0] == "" {
+		return nil, fmt.Errorf("invalid ulimit %q, expected NAME=SOFT[:HARD]", s)
+	}
+
+	limits := strings.SplitN(pair[1], ":", 2)
+	soft, err := strconv.ParseInt(limits[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ulimit %q: %s", s, err)
+	}
+	hard := soft
+	if len(limits) == 2 {
+		if hard, err = strconv.ParseInt(limits[1], 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid ulimit %q: %s", s, err)
+		}
+	}
+	if soft > hard {
+		return nil, fmt.Errorf("invalid ulimit %q: soft limit is above the hard limit", s)
+	}
+
+	return &ecs.Ulimit{
+		Name:      aws.String(pair[0]),
+		SoftLimit: aws.Int64(soft),
+		HardLimit: aws.Int64(hard),
+	}, nil
+}
+
+// parseTmpfs parses docker's PATH[:OPTIONS] where OPTIONS may include size=64m. ECS requires
+// a size, docker's 64m default for /dev/shm is used when none is given.
+func parseTmpfs(s string) (*ecs.Tmpfs, error) {
+	pair := strings.SplitN(s, ":", 2)
+	if !strings.HasPrefix(pair[0], "/") {
+		return nil, fmt.Errorf("invalid tmpfs %q, expected an absolute PATH[:OPTIONS]", s)
+	}
+
+	mount := &ecs.Tmpfs{
+		ContainerPath: aws.String(pair[0]),
+		Size:          aws.Int64(64),
+	}
+	if len(pair) == 2 {
+		for _, option := range strings.Split(pair[1], ",") {
+			if strings.HasPrefix(option, "size=") {
+				size, err := parseMiB(strings.TrimPrefix(option, "size="))
+				if err != nil {
+					return nil, fmt.Errorf("invalid tmpfs %q: %s", s, err)
+				}
+				mount.Size = aws.Int64(size)
+				continue
+			}
+			if option != "" {
+				mount.MountOptions = append(mount.MountOptions, aws.String(option))
+			}
+		}
+	}
+	return mount, nil
+}
+
+// parseMiB parses a docker size such as 512k, 64m or 1g into MiB, rounding up
+func parseMiB(s string) (int64, error) {
+	units := map[byte]int64{'b': 1, 'k': 1 << 10, 'm': 1 << 20, 'g': 1 << 30}
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return 0, errors.New("empty size")
+	}
+
+	multiplier := int64(1)
+	if m, ok := units[s[len(s)-1]]; ok {
+		multiplier = m
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return (n*multiplier + (1<<20 - 1)) >> 20, nil
+}
+
+// parsePlatform parses docker's OS/ARCH, eg linux/arm64, into a runtime platform
+func parsePlatform(s string) (*ecs.RuntimePlatform, error) {
+	pair := strings.SplitN(strings.ToLower(s), "/", 2)
+	platform := &ecs.RuntimePlatform{}
+
+	switch pair[0] {
+	case "linux":
+		platform.OperatingSystemFamily = aws.String(ecs.OSFamilyLinux)
+	default:
+		return nil, fmt.Errorf("unsupported platform %q, expected linux/amd64 or linux/arm64", s)
+	}
+
+	if len(pair) == 2 {
+		switch pair[1] {
+		case "amd64", "x86_64":
+			platform.CpuArchitecture = aws.String(ecs.CPUArchitectureX8664)
+		case "arm64", "aarch64":
+			platform.CpuArchitecture = aws.String(ecs.CPUArchitectureArm64)
+		default:
+			return nil, fmt.Errorf("unsupported platform %q, expected linux/amd64 or linux/arm64", s)
+		}
+	}
+	return platform, nil
+}
+
+// normalizeCapabilities strips the CAP_ prefix docker accepts but ECS doesn't
+func normalizeCapabilities(capabilities []string) []string {
+	var normalized []string
+	for _, c := range capabilities {
+		normalized = append(normalized, strings.TrimPrefix(strings.ToUpper(c), "CAP_"))
+	}
+	return normalized
+}
@@ -0,0 +1,82 @@
+package ecs
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+func TestApplyDockerOptions(t *testing.T) {
+	task := &Task{
+		EntryPoint:       "/docker-entrypoint.sh",
+		WorkingDirectory: "/app",
+		User:             "1000:1000",
+		Labels:           []string{"team=platform"},
+		Ulimits:          []string{"nofile=1024:4096"},
+		CapAdd:           []string{"cap_sys_ptrace"},
+		CapDrop:          []string{"ALL"},
+		ReadOnly:         true,
+		Init:             true,
+		Tmpfs:            []string{"/run:size=128m,noexec"},
+		ShmSize:          "1g",
+		Sysctls:          []string{"net.core.somaxconn=1024"},
+		AddHosts:         []string{"db:fd00::1"},
+		StopTimeout:      30,
+		HealthCmd:        "curl -f localhost",
+		HealthInterval:   "30s",
+		HealthRetries:    3,
+		Tty:              true,
+	}
+
+	def := &ecs.ContainerDefinition{}
+	if err := task.applyDockerOptions(def); err != nil {
+		t.Fatal(err)
+	}
+
+	if aws.StringValue(def.EntryPoint[0]) != "/docker-entrypoint.sh" || aws.StringValue(def.WorkingDirectory) != "/app" || aws.StringValue(def.User) != "1000:1000" {
+		t.Errorf("unexpected container definition %v", def)
+	}
+	if aws.StringValue(def.DockerLabels["team"]) != "platform" || aws.Int64Value(def.Ulimits[0].HardLimit) != 4096 {
+		t.Errorf("unexpected labels or ulimits %v", def)
+	}
+	if aws.StringValue(def.ExtraHosts[0].IpAddress) != "fd00::1" || aws.StringValue(def.SystemControls[0].Value) != "1024" {
+		t.Errorf("unexpected hosts or sysctls %v", def)
+	}
+	if !aws.BoolValue(def.ReadonlyRootFilesystem) || !aws.BoolValue(def.PseudoTerminal) || aws.Int64Value(def.StopTimeout) != 30 {
+		t.Errorf("unexpected container definition %v", def)
+	}
+	if aws.StringValue(def.HealthCheck.Command[0]) != "CMD-SHELL" || aws.Int64Value(def.HealthCheck.Interval) != 30 || aws.Int64Value(def.HealthCheck.Retries) != 3 {
+		t.Errorf("unexpected health check %v", def.HealthCheck)
+	}
+
+	linux := def.LinuxParameters
+	if aws.StringValue(linux.Capabilities.Add[0]) != "SYS_PTRACE" || !aws.BoolValue(linux.InitProcessEnabled) || aws.Int64Value(linux.SharedMemorySize) != 1024 {
+		t.Errorf("unexpected linux parameters %v", linux)
+	}
+	if aws.Int64Value(linux.Tmpfs[0].Size) != 128 || aws.StringValue(linux.Tmpfs[0].MountOptions[0]) != "noexec" {
+		t.Errorf("unexpected tmpfs %v", linux.Tmpfs)
+	}
+
+	for _, invalid := range []*Task{
+		{Ulimits: []string{"nofile=4096:1024"}},
+		{Ulimits: []string{"nofile"}},
+		{AddHosts: []string{"db"}},
+		{Tmpfs: []string{"run"}},
+		{ShmSize: "lots"},
+		{HealthCmd: "true", HealthInterval: "often"},
+		{HealthRetries: 3},
+	} {
+		if err := invalid.applyDockerOptions(&ecs.ContainerDefinition{}); err == nil {
+			t.Errorf("expected %+v to fail", invalid)
+		}
+	}
+}
+
+func TestParseMiB(t *testing.T) {
+	for s, expected := range map[string]int64{"64m": 64, "1g": 1024, "512k": 1, "1048576": 1, "2M": 2} {
+		if got, err := parseMiB(s); err != nil || got != expected {
+			t.Errorf("%s: expected %d, got %d %v", s, expected, got, err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package ecs
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Endpoints overrides the URLs AWS services are reached at, eg to run against LocalStack.
+// Empty fields use the regular AWS endpoints.
+type Endpoints struct {
+	// URL is used by every service without its own endpoint
+	URL string
+
+	ECS  string
+	Logs string
+	EC2  string
+	SSM  string
+	STS  string
+	IAM  string
+	ECR  string
+}
+
+// endpointServices maps the services onto the suffix of their AWS_ENDPOINT_URL_* variable,
+// the service ID in upper snake case
+var endpointServices = []struct {
+	env   string
+	field func(e *Endpoints) *string
+}{
+	{"ECS", func(e *Endpoints) *string { return &e.ECS }},
+	{"CLOUDWATCH_LOGS", func(e *Endpoints) *string { return &e.Logs }},
+	{"EC2", func(e *Endpoints) *string { return &e.EC2 }},
+	{"SSM", func(e *Endpoints) *string { return &e.SSM }},
+	{"STS", func(e *Endpoints) *string { return &e.STS }},
+	{"IAM", func(e *Endpoints) *string { return &e.IAM }},
+	{"ECR", func(e *Endpoints) *string { return &e.ECR }},
+}
+
+// ResolveEndpoints returns the endpoint of every service. An endpoint passed for the service
+// wins over the URL for all services, which wins over AWS_ENDPOINT_URL_<SERVICE> and then
+// AWS_ENDPOINT_URL from lookup. AWS_IGNORE_CONFIGURED_ENDPOINT_URLS=true ignores the environment.
+func ResolveEndpoints(e Endpoints, lookup func(string) (string, bool)) (Endpoints, error) {
+	if ignore, _ := lookup("AWS_IGNORE_CONFIGURED_ENDPOINT_URLS"); strings.EqualFold(ignore, "true") {
+		lookup = func(string) (string, bool) { return "", false }
+	}
+
+	global, _ := lookup("AWS_ENDPOINT_URL")
+	resolved := Endpoints{URL: e.URL}
+	for _, service := range endpointServices {
+		endpoint := *service.field(&e)
+		if endpoint == "" {
+			endpoint = e.URL
+		}
+		if endpoint == "" {
+			endpoint, _ = lookup("AWS_ENDPOINT_URL_" + service.env)
+		}
+		if endpoint == "" {
+			endpoint = global
+		}
+
+		if endpoint != "" {
+			u, err := url.Parse(endpoint)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return Endpoints{}, fmt.Errorf("invalid endpoint %q, expected a URL such as http://localhost:4566", endpoint)
+			}
+		}
+		*service.field(&resolved) = endpoint
+	}
+	return resolved, nil
+}
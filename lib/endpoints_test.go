@@ -0,0 +1,75 @@
+package ecs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func lookupIn(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+func TestResolveEndpoints(t *testing.T) {
+	env := map[string]string{
+		"AWS_ENDPOINT_URL":                 "http://localhost:4566",
+		"AWS_ENDPOINT_URL_CLOUDWATCH_LOGS": "http://localhost:4567",
+	}
+
+	resolved, err := ResolveEndpoints(Endpoints{}, lookupIn(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.ECS != "http://localhost:4566" || resolved.Logs != "http://localhost:4567" || resolved.ECR != "http://localhost:4566" {
+		t.Errorf("expected the environment, got %+v", resolved)
+	}
+
+	// flags win over the environment and a service flag over the global one
+	resolved, _ = ResolveEndpoints(Endpoints{URL: "http://replay:8080", ECS: "https://ecs.local"}, lookupIn(env))
+	if resolved.ECS != "https://ecs.local" || resolved.Logs != "http://replay:8080" || resolved.SSM != "http://replay:8080" {
+		t.Errorf("expected the flags, got %+v", resolved)
+	}
+
+	env["AWS_IGNORE_CONFIGURED_ENDPOINT_URLS"] = "true"
+	if resolved, _ = ResolveEndpoints(Endpoints{EC2: "http://ec2.local"}, lookupIn(env)); resolved != (Endpoints{EC2: "http://ec2.local"}) {
+		t.Errorf("expected the environment to be ignored, got %+v", resolved)
+	}
+
+	for _, endpoint := range []string{"localhost:4566", "ftp://localhost", "http://"} {
+		if _, err := ResolveEndpoints(Endpoints{URL: endpoint}, lookupIn(nil)); err == nil {
+			t.Errorf("%s: expected an invalid endpoint", endpoint)
+		}
+	}
+}
+
+func TestSessionClientEndpoints(t *testing.T) {
+	var target string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target = r.Header.Get("X-Amz-Target")
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		fmt.Fprint(w, `{"clusterArns": ["arn:aws:ecs:us-east-1:000000000000:cluster/local"]}`)
+	}))
+	defer server.Close()
+
+	sess := session.Must(session.NewSession(aws.NewConfig().
+		WithRegion("us-east-1").
+		WithCredentials(credentials.NewStaticCredentials("test", "test", ""))))
+	c := NewSessionClient(sess, Endpoints{ECS: server.URL})
+
+	clusters, err := c.GetClusters(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 1 || clusters[0] != "local" || target != "AmazonEC2ContainerServiceV20141113.ListClusters" {
+		t.Errorf("expected ListClusters to reach the endpoint, got %v (%s)", clusters, target)
+	}
+}
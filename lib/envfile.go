@@ -0,0 +1,220 @@
+package ecs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// environment merges the environment files and variables of a task the way docker does: files
+// are applied in order and --env wins over all of them. S3 files are left for the agent to read.
+func (t *Task) environment(region string) ([]*ecs.KeyValuePair, []*ecs.EnvironmentFile, error) {
+	var entries []string
+	var files []*ecs.EnvironmentFile
+
+	for _, file := range t.EnvironmentFiles {
+		if strings.HasPrefix(file, "s3://") || strings.HasPrefix(file, "arn:") {
+			arn, err := s3ObjectArn(file, region)
+			if err != nil {
+				return nil, nil, err
+			}
+			files = append(files, &ecs.EnvironmentFile{
+				Type:  aws.String("s3"),
+				Value: aws.String(arn),
+			})
+			continue
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		parsed, err := parseDotenv(f, os.LookupEnv)
+		f.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %s", file, err)
+		}
+		entries = append(entries, parsed...)
+	}
+
+	entries = append(entries, t.Environment...)
+	return buildEnvironmentKeyValuePair(mergeEnvironment(entries)), files, nil
+}
+
+// mergeEnvironment keeps the last value of every variable at the position it was first set
+func mergeEnvironment(entries []string) []string {
+	index := map[string]int{}
+	var merged []string
+	for _, entry := range entries {
+		key := strings.SplitN(entry, "=", 2)[0]
+		if i, ok := index[key]; ok {
+			merged[i] = entry
+			continue
+		}
+		index[key] = len(merged)
+		merged = append(merged, entry)
+	}
+	return merged
+}
+
+// s3ObjectArn turns s3://bucket/key into the ARN environment files are referenced by
+func s3ObjectArn(file, region string) (string, error) {
+	if strings.HasPrefix(file, "arn:") {
+		if !strings.Contains(file, ":s3:::") {
+			return "", fmt.Errorf("invalid environment file %q, expected the ARN of an S3 object", file)
+		}
+		return file, nil
+	}
+
+	path := strings.TrimPrefix(file, "s3://")
+	if i := strings.Index(path, "/"); i < 1 || i == len(path)-1 {
+		return "", fmt.Errorf("invalid environment file %q, expected s3://bucket/key", file)
+	}
+	return fmt.Sprintf("arn:%s:s3:::%s", partition(region), path), nil
+}
+
+// parseDotenv reads KEY=VALUE lines into entries. Blank lines and # comments are skipped, an
+// export prefix is allowed, single quoted values are literal, double quoted values may span
+// lines and support escapes, and ${VAR}, ${VAR:-default} and $VAR are expanded from earlier
+// entries or lookup. A bare KEY takes its value from lookup and is dropped when unset.
+func parseDotenv(r io.Reader, lookup func(string) (string, bool)) ([]string, error) {
+	values := map[string]string{}
+	resolve := func(name string) (string, bool) {
+		if v, ok := values[name]; ok {
+			return v, true
+		}
+		return lookup(name)
+	}
+
+	var entries []string
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+		pair := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(pair[0])
+		if !envKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("line %d: invalid variable name %q", lineNumber, key)
+		}
+
+		if len(pair) == 1 {
+			if v, ok := lookup(key); ok {
+				values[key] = v
+				entries = append(entries, key+"="+v)
+			}
+			continue
+		}
+
+		raw := strings.TrimSpace(pair[1])
+		var value string
+		switch {
+		case strings.HasPrefix(raw, "'"):
+			end := strings.Index(raw[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated single quoted value", lineNumber)
+			}
+			value = raw[1 : end+1]
+
+		case strings.HasPrefix(raw, `"`):
+			// keep reading lines until the closing quote
+			quoted := raw[1:]
+			for closingQuote(quoted) < 0 {
+				if !scanner.Scan() {
+					return nil, fmt.Errorf("line %d: unterminated double quoted value", lineNumber)
+				}
+				lineNumber++
+				quoted += "\n" + scanner.Text()
+			}
+			value = unescape(quoted[:closingQuote(quoted)], resolve)
+
+		default:
+			if i := strings.Index(raw, " #"); i >= 0 {
+				raw = strings.TrimSpace(raw[:i])
+			}
+			value = expand(raw, resolve)
+		}
+
+		values[key] = value
+		entries = append(entries, key+"="+value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// closingQuote returns the index of the first unescaped double quote
+func closingQuote(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// unescape handles the escapes of a double quoted value and expands variables outside of them
+func unescape(s string, resolve func(string) (string, bool)) string {
+	var b strings.Builder
+	var segment strings.Builder
+	flush := func() {
+		b.WriteString(expand(segment.String(), resolve))
+		segment.Reset()
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			segment.WriteByte(s[i])
+			continue
+		}
+		i++
+		flush()
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		default:
+			// \" \\ \$ and unknown escapes keep the character
+			b.WriteByte(s[i])
+		}
+	}
+	flush()
+	return b.String()
+}
+
+var envReferencePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// expand replaces ${VAR}, ${VAR:-default} and $VAR references
+func expand(s string, resolve func(string) (string, bool)) string {
+	return envReferencePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		m := envReferencePattern.FindStringSubmatch(ref)
+		name := m[1]
+		if name == "" {
+			name = m[4]
+		}
+		if v, ok := resolve(name); ok && (v != "" || m[2] == "") {
+			return v
+		}
+		return m[3]
+	})
+}
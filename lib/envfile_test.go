@@ -0,0 +1,104 @@
+package ecs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestParseDotenv(t *testing.T) {
+	env := map[string]string{"HOME": "/home/app", "EMPTY": "", "FROM_SHELL": "shell"}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	entries, err := parseDotenv(strings.NewReader(`
+# a comment
+PLAIN=value
+export EXPORTED=1
+SPACED = padded value # trailing comment
+HASH=a#b
+SINGLE='literal $HOME \n'
+DOUBLE="line\nbreak \"quoted\" \$HOME $HOME"
+MULTI="first
+second"
+BRACES=${HOME}/data
+DEFAULT=${EMPTY:-fallback}
+UNSET_DEFAULT=${MISSING:-fallback}
+EARLIER=$PLAIN-suffix
+FROM_SHELL
+NOT_IN_SHELL
+`), lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"PLAIN=value",
+		"EXPORTED=1",
+		"SPACED=padded value",
+		"HASH=a#b",
+		`SINGLE=literal $HOME \n`,
+		"DOUBLE=line\nbreak \"quoted\" $HOME /home/app",
+		"MULTI=first\nsecond",
+		"BRACES=/home/app/data",
+		"DEFAULT=fallback",
+		"UNSET_DEFAULT=fallback",
+		"EARLIER=value-suffix",
+		"FROM_SHELL=shell",
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %d: %q", len(expected), len(entries), entries)
+	}
+	for i := range expected {
+		if entries[i] != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], entries[i])
+		}
+	}
+
+	for _, invalid := range []string{"1BAD=x", `OPEN="never closed`, "OPEN='never closed", "BAD KEY=x"} {
+		if _, err := parseDotenv(strings.NewReader(invalid), lookup); err == nil {
+			t.Errorf("expected %q to fail", invalid)
+		}
+	}
+}
+
+func TestTaskEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.env")
+	second := filepath.Join(dir, "second.env")
+	os.WriteFile(first, []byte("A=file\nB=first\n"), 0o644)
+	os.WriteFile(second, []byte("B=second\nC=second\n"), 0o644)
+
+	task := &Task{
+		EnvironmentFiles: []string{first, "s3://config/app.env", second},
+		Environment:      []string{"C=flag"},
+	}
+	environment, files, err := task.environment("us-east-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// later files override earlier ones, and --env overrides all files
+	got := map[string]string{}
+	for _, kv := range environment {
+		got[*kv.Name] = *kv.Value
+	}
+	if len(environment) != 3 || got["A"] != "file" || got["B"] != "second" || got["C"] != "flag" {
+		t.Errorf("unexpected environment %v", got)
+	}
+
+	if len(files) != 1 || !strings.HasSuffix(aws.StringValue(files[0].Value), ":s3:::config/app.env") || aws.StringValue(files[0].Type) != "s3" {
+		t.Errorf("expected the S3 file to be left for the agent, got %v", files)
+	}
+
+	for _, invalid := range []string{"s3://bucket", "s3://bucket/", "arn:aws:ssm:us-east-1:123456789012:parameter/x"} {
+		if _, err := s3ObjectArn(invalid, "us-east-1"); err == nil {
+			t.Errorf("expected %q to fail", invalid)
+		}
+	}
+}
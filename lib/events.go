@@ -0,0 +1,167 @@
+package ecs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/fatih/color"
+)
+
+// EventType names a step in the lifecycle of a task
+type EventType string
+
+// Lifecycle events, in the order they usually happen
+const (
+	EventTaskDefinitionRegistered EventType = "task-definition-registered"
+	EventTaskStarted              EventType = "task-started"
+	EventPlacedOnInstance         EventType = "placed-on-instance"
+	EventPortsAvailable           EventType = "ports-available"
+	EventLogLine                  EventType = "log-line"
+	EventTimedOut                 EventType = "timed-out"
+	EventContainerStopped         EventType = "container-stopped"
+	EventTaskStopped              EventType = "task-stopped"
+	EventCleanupDone              EventType = "cleanup-done"
+)
+
+// Event is a single lifecycle event. Only the fields relevant to its type are set.
+type Event struct {
+	Type              EventType     `json:"type"`
+	Time              time.Time     `json:"time"`
+	ClusterArn        string        `json:"clusterArn,omitempty"`
+	TaskDefinitionArn string        `json:"taskDefinitionArn,omitempty"`
+	TaskArn           string        `json:"taskArn,omitempty"`
+	ContainerName     string        `json:"containerName,omitempty"`
+	ContainerArn      string        `json:"containerArn,omitempty"`
+	LogStream         string        `json:"logStream,omitempty"`
+	InstanceID        string        `json:"instanceId,omitempty"`
+	IPAddress         string        `json:"ipAddress,omitempty"`
+	Ports             []PortBinding `json:"ports,omitempty"`
+	Message           string        `json:"message,omitempty"`
+	StopCode          string        `json:"stopCode,omitempty"`
+	Reason            string        `json:"reason,omitempty"`
+	ExitCode          *int64        `json:"exitCode,omitempty"`
+	Reused            bool          `json:"reused,omitempty"`
+
+	// label of the log stream when lines from several streams are interleaved
+	prefix string
+}
+
+// PortBinding is a published port of a running container
+type PortBinding struct {
+	ContainerName string `json:"containerName"`
+	ContainerPort int64  `json:"containerPort"`
+	HostPort      int64  `json:"hostPort"`
+	Protocol      string `json:"protocol"`
+}
+
+// Renderer receives lifecycle events and diagnostics. The CLI renders them as text or JSON,
+// an embedding program can implement it to follow progress.
+type Renderer interface {
+	Event(e Event)
+	Info(s string)
+	Warning(s string)
+	Error(s string)
+}
+
+// NewRenderer renders events as text for humans, or with json/ndjson as one JSON event per
+// line on stdout with diagnostics moved to stderr
+func NewRenderer(format string) (Renderer, error) {
+	switch format {
+	case "", "text":
+		return &textRenderer{}, nil
+	case "json", "ndjson":
+		return &jsonRenderer{out: os.Stdout, diagnostics: os.Stderr}, nil
+	default:
+		return nil, fmt.Errorf("unsupported output %s, expected text, json or ndjson", format)
+	}
+}
+
+// textRenderer prints colored lines, as the CLI always has
+type textRenderer struct{}
+
+func (r *textRenderer) Info(s string)    { color.Green(s) }
+func (r *textRenderer) Warning(s string) { color.Yellow(s) }
+func (r *textRenderer) Error(s string)   { color.Red(s) }
+
+func (r *textRenderer) Event(e Event) {
+	switch e.Type {
+	case EventTaskDefinitionRegistered:
+		r.Info("Running task definition: " + e.TaskDefinitionArn)
+	case EventTaskStarted:
+		var re = regexp.MustCompile("[^/]*$")
+		r.Info(fmt.Sprintf("https://console.aws.amazon.com/ecs/home?#/clusters/%s/tasks/%s/details", parseClusterName(e.ClusterArn), re.FindString(e.TaskArn)))
+	case EventPlacedOnInstance:
+		r.Info(fmt.Sprintf("Container is starting on EC2 instance %v (%v).", e.InstanceID, e.IPAddress))
+	case EventPortsAvailable:
+		for _, port := range e.Ports {
+			r.Info(fmt.Sprintf("Container is available here\n\thttp://%v:%v\n\tTCP %v %v", e.IPAddress, port.HostPort, e.IPAddress, port.HostPort))
+		}
+	case EventLogLine:
+		yellow := color.New(color.FgYellow).SprintFunc()
+		timestamp := yellow(e.Time.Truncate(time.Second))
+		if e.prefix != "" {
+			fmt.Printf("%v\t%v | %v\n", timestamp, e.prefix, e.Message)
+			return
+		}
+		fmt.Printf("%v\t%v\n", timestamp, e.Message)
+	case EventTimedOut:
+		r.Warning(e.Reason)
+	case EventContainerStopped:
+		exitCode := "unknown"
+		if e.ExitCode != nil {
+			exitCode = fmt.Sprintf("%d", *e.ExitCode)
+		}
+		r.Info(fmt.Sprintf("Container %v (%v) has stopped (exit code %v)", e.ContainerName, e.ContainerArn, exitCode))
+		if e.Reason != "" {
+			r.Info(fmt.Sprintf("\t%v", e.Reason))
+		}
+	case EventTaskStopped:
+		if e.StopCode == ecs.TaskStopCodeSpotInterruption {
+			r.Warning(fmt.Sprintf("Task %v was interrupted because its Spot capacity was reclaimed:\n\t%v", e.TaskArn, e.Reason))
+		} else {
+			r.Info(fmt.Sprintf("Task %v has stopped:\n\t%v", e.TaskArn, e.Reason))
+		}
+	case EventCleanupDone:
+		r.Info("Deleted task definition: " + e.TaskDefinitionArn)
+	}
+}
+
+// jsonRenderer writes one JSON object per event
+type jsonRenderer struct {
+	mu          sync.Mutex
+	out         io.Writer
+	diagnostics io.Writer
+}
+
+func (r *jsonRenderer) Info(s string)    { r.diagnostic(s) }
+func (r *jsonRenderer) Warning(s string) { r.diagnostic(s) }
+func (r *jsonRenderer) Error(s string)   { r.diagnostic(s) }
+
+func (r *jsonRenderer) diagnostic(s string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintln(r.diagnostics, s)
+}
+
+func (r *jsonRenderer) Event(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, err := json.Marshal(e)
+	if err != nil {
+		fmt.Fprintln(r.diagnostics, err)
+		return
+	}
+	r.out.Write(append(b, '\n'))
+}
+
+// millisToTime converts a CloudWatch Logs timestamp
+func millisToTime(ms *int64) time.Time {
+	return time.Unix(0, aws.Int64Value(ms)*int64(time.Millisecond))
+}
@@ -0,0 +1,86 @@
+package ecs
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+// recordingRenderer keeps events so tests can inspect them
+type recordingRenderer struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recordingRenderer) Event(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *recordingRenderer) Info(s string)    {}
+func (r *recordingRenderer) Warning(s string) {}
+func (r *recordingRenderer) Error(s string)   {}
+
+func TestJSONRenderer(t *testing.T) {
+	var out, diagnostics bytes.Buffer
+	r := &jsonRenderer{out: &out, diagnostics: &diagnostics}
+
+	r.Info("Creating task definition")
+	r.Event(Event{Type: EventTaskDefinitionRegistered, TaskDefinitionArn: "arn:aws:ecs:us-east-1:123456789012:task-definition/app:3"})
+	r.Event(Event{Type: EventContainerStopped, TaskArn: "arn:aws:ecs:us-east-1:123456789012:task/qa/abc", ContainerName: "app", ExitCode: aws.Int64(0)})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected one line per event, got %q", out.String())
+	}
+
+	var stopped map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &stopped); err != nil {
+		t.Fatal(err)
+	}
+	if stopped["type"] != "container-stopped" || stopped["exitCode"] != float64(0) || stopped["containerName"] != "app" {
+		t.Errorf("unexpected event %v", stopped)
+	}
+	if _, ok := stopped["taskDefinitionArn"]; ok {
+		t.Errorf("expected unset fields to be omitted, got %v", stopped)
+	}
+	if diagnostics.String() != "Creating task definition\n" {
+		t.Errorf("expected diagnostics apart from events, got %q", diagnostics.String())
+	}
+}
+
+func TestNewRenderer(t *testing.T) {
+	for _, format := range []string{"text", "json", "ndjson"} {
+		if _, err := NewRenderer(format); err != nil {
+			t.Errorf("%s: %v", format, err)
+		}
+	}
+	if _, err := NewRenderer("yaml"); err == nil {
+		t.Errorf("expected yaml to be unsupported")
+	}
+}
+
+func TestEmitFollowedEvents(t *testing.T) {
+	recorder := &recordingRenderer{}
+	c := &Client{Events: recorder}
+
+	app := &logFollower{taskArn: "arn:aws:ecs:us-east-1:123456789012:task/qa/abc", container: "app", stream: "run/app/abc"}
+	proxy := &logFollower{taskArn: app.taskArn, container: "proxy", stream: "run/proxy/abc"}
+	c.emitFollowedEvents([]*followedEvent{
+		{follower: app, event: &cloudwatchlogs.OutputLogEvent{Timestamp: aws.Int64(2000), Message: aws.String("second")}},
+		{follower: proxy, event: &cloudwatchlogs.OutputLogEvent{Timestamp: aws.Int64(1000), Message: aws.String("first")}},
+	})
+
+	if len(recorder.events) != 2 || recorder.events[0].Message != "first" || recorder.events[0].ContainerName != "proxy" {
+		t.Fatalf("expected events in timestamp order, got %+v", recorder.events)
+	}
+	if e := recorder.events[1]; e.Type != EventLogLine || e.Time.UnixNano() != 2e9 || e.LogStream != "run/app/abc" {
+		t.Errorf("unexpected event %+v", e)
+	}
+}
@@ -1,15 +1,19 @@
 package ecs
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
-	"os/signal"
 	"regexp"
-	"syscall"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/justmiles/ecs-cli/lib/session"
+	"golang.org/x/term"
 )
 
 type ExecInput struct {
@@ -19,14 +23,38 @@ type ExecInput struct {
 	Container   string
 	Interactive bool
 	Command     string
+
+	// Stdin and Stdout of an interactive session, os.Stdin and os.Stdout when nil
+	Stdin  io.Reader
+	Stdout io.Writer
+
+	// Progress receives the progress of copies, nil reports none
+	Progress io.Writer
+}
+
+// ExecResult is the captured outcome of a command run in a single task
+type ExecResult struct {
+	Task      string `json:"task"`
+	Container string `json:"container"`
+	Output    string `json:"output"`
+	ExitCode  int    `json:"exitCode"`
+	Error     string `json:"error,omitempty"`
 }
 
-func GetClusters() ([]string, error) {
+// Failed reports whether the command could not be run or exited non-zero
+func (r *ExecResult) Failed() bool {
+	return r.Error != "" || r.ExitCode != 0
+}
+
+// printed after the command so its exit status can be recovered from the output
+const exitCodeMarker = "__ECS_CLI_EXIT_CODE__:"
+
+func (c *Client) GetClusters(ctx context.Context) ([]string, error) {
 
 	clusters := []string{}
 
 	pageNum := 0
-	err := ecsClient.ListClustersPages(&ecs.ListClustersInput{},
+	err := c.ECS.ListClustersPagesWithContext(ctx, &ecs.ListClustersInput{},
 		func(page *ecs.ListClustersOutput, lastPage bool) bool {
 			pageNum++
 			for _, arn := range page.ClusterArns {
@@ -37,12 +65,12 @@ func GetClusters() ([]string, error) {
 	return clusters, err
 }
 
-func GetServices(cluster string) ([]string, error) {
+func (c *Client) GetServices(ctx context.Context, cluster string) ([]string, error) {
 
 	results := []string{}
 
 	pageNum := 0
-	err := ecsClient.ListServicesPages(&ecs.ListServicesInput{
+	err := c.ECS.ListServicesPagesWithContext(ctx, &ecs.ListServicesInput{
 		Cluster: aws.String(cluster),
 	},
 		func(page *ecs.ListServicesOutput, lastPage bool) bool {
@@ -57,12 +85,12 @@ func GetServices(cluster string) ([]string, error) {
 	return results, err
 }
 
-func GetRunningTasks(cluster string, service string) ([]string, error) {
+func (c *Client) GetRunningTasks(ctx context.Context, cluster string, service string) ([]string, error) {
 
 	results := []string{}
 
 	pageNum := 0
-	err := ecsClient.ListTasksPages(&ecs.ListTasksInput{
+	err := c.ECS.ListTasksPagesWithContext(ctx, &ecs.ListTasksInput{
 		Cluster:       aws.String(cluster),
 		ServiceName:   aws.String(service),
 		DesiredStatus: aws.String("RUNNING"),
@@ -78,11 +106,11 @@ func GetRunningTasks(cluster string, service string) ([]string, error) {
 	return results, err
 }
 
-func GetContainers(cluster string, task string) ([]string, error) {
+func (c *Client) GetContainers(ctx context.Context, cluster string, task string) ([]string, error) {
 
 	results := []string{}
 
-	output, err := ecsClient.DescribeTasks(&ecs.DescribeTasksInput{
+	output, err := c.ECS.DescribeTasksWithContext(ctx, &ecs.DescribeTasksInput{
 		Cluster: aws.String(cluster),
 		Tasks:   aws.StringSlice([]string{task}),
 	})
@@ -91,63 +119,178 @@ func GetContainers(cluster string, task string) ([]string, error) {
 	}
 
 	for _, t := range output.Tasks {
-		for _, c := range t.Containers {
-			results = append(results, *c.Name)
+		for _, container := range t.Containers {
+			results = append(results, *container.Name)
 		}
 	}
 
 	return results, err
 }
 
-func ExecuteCommand(input *ExecInput) error {
-	args := []string{
-		"ecs",
-		"execute-command",
-		"--cluster",
-		input.Cluster,
-		"--task",
-		input.Task,
-		"--command",
-		input.Command,
-		"--container",
-		input.Container,
-	}
-	if input.Interactive {
-		args = append(args, "--interactive")
-	} else {
-		args = append(args, "--non-interactive")
+// ExecuteCommand opens a session in a container and attaches it to the input's Stdin and Stdout
+func (c *Client) ExecuteCommand(ctx context.Context, input *ExecInput) error {
+	output, err := c.ECS.ExecuteCommandWithContext(ctx, &ecs.ExecuteCommandInput{
+		Cluster:     aws.String(input.Cluster),
+		Task:        aws.String(input.Task),
+		Container:   aws.String(input.Container),
+		Command:     aws.String(input.Command),
+		Interactive: aws.Bool(input.Interactive),
+	})
+	if err != nil {
+		return err
 	}
 
-	if err := runCommand("aws", args...); err != nil {
+	sess, err := session.Open(*output.Session.StreamUrl, *output.Session.TokenValue)
+	if err != nil {
 		return err
 	}
-	return nil
-}
+	defer sess.Close()
+
+	if err := sess.WaitForHandshake(); err != nil {
+		return err
+	}
+	if sess.CustomerMessage != "" {
+		c.logInfo(sess.CustomerMessage)
+	}
 
-func runCommand(process string, args ...string) error {
-	cmd := exec.Command(process, args...)
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
-	cmd.Stdin = os.Stdin
+	var stdin io.Reader = os.Stdin
+	if input.Stdin != nil {
+		stdin = input.Stdin
+	}
+	var stdout io.Writer = os.Stdout
+	if input.Stdout != nil {
+		stdout = input.Stdout
+	}
 
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, os.Interrupt, syscall.SIGINT)
-	go func() {
-		for {
-			select {
-			case <-sigs:
-			}
+	// Pass keystrokes, including ^C, straight through to the remote terminal
+	if f, ok := stdin.(*os.File); ok && input.Interactive && term.IsTerminal(int(f.Fd())) {
+		fd := int(f.Fd())
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
 		}
+		defer term.Restore(fd, state)
+
+		stopResize := watchTerminalSize(sess)
+		defer stopResize()
+	}
+
+	go func() {
+		io.Copy(sess, stdin)
 	}()
-	defer close(sigs)
 
-	if err := cmd.Run(); err != nil {
+	if _, err := io.Copy(stdout, sess); err != nil {
 		return err
 	}
 
+	if err := sess.Err(); err != nil {
+		return err
+	}
+	if message := sess.ClosedMessage(); message != "" {
+		c.logInfo(message)
+	}
 	return nil
 }
 
+// CaptureCommand runs the command in a task without a terminal and returns its output and
+// exit status. The command is run through /bin/sh, which must exist in the container.
+func (c *Client) CaptureCommand(ctx context.Context, input *ExecInput) *ExecResult {
+	result := &ExecResult{
+		Task:      input.Task,
+		Container: input.Container,
+		ExitCode:  -1,
+	}
+
+	// ECS only supports interactive sessions, the exit status is echoed after the command instead
+	output, err := c.ECS.ExecuteCommandWithContext(ctx, &ecs.ExecuteCommandInput{
+		Cluster:     aws.String(input.Cluster),
+		Task:        aws.String(input.Task),
+		Container:   aws.String(input.Container),
+		Command:     aws.String(wrapCommand(input.Command)),
+		Interactive: aws.Bool(true),
+	})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	sess, err := session.Open(*output.Session.StreamUrl, *output.Session.TokenValue)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer sess.Close()
+
+	b, err := io.ReadAll(sess)
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	result.Output, result.ExitCode = parseExitCode(string(b))
+	if result.ExitCode < 0 && result.Error == "" {
+		result.Error = "unable to determine the exit status of the command"
+	}
+	return result
+}
+
+// CaptureCommandAll runs the command in each task, at most concurrency at a time, and returns
+// the results in the order of tasks
+func (c *Client) CaptureCommandAll(ctx context.Context, input *ExecInput, tasks []string, concurrency int) []*ExecResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]*ExecResult, len(tasks))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, task := range tasks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, task string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			taskInput := *input
+			taskInput.Task = task
+			results[i] = c.CaptureCommand(ctx, &taskInput)
+		}(i, task)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// wrapCommand runs command through a shell and prints its exit status after it
+func wrapCommand(command string) string {
+	script := "(" + command + "); status=$?; echo; echo " + exitCodeMarker + "$status"
+	return "/bin/sh -c '" + strings.Replace(script, "'", `'"'"'`, -1) + "'"
+}
+
+// parseExitCode separates the output of a wrapped command from its exit status.
+// -1 is returned when no exit status was printed.
+func parseExitCode(output string) (string, int) {
+	// sessions are attached to a pseudo terminal, which translates newlines
+	output = strings.Replace(output, "\r\n", "\n", -1)
+
+	i := strings.LastIndex(output, exitCodeMarker)
+	if i < 0 {
+		return output, -1
+	}
+
+	code, err := strconv.Atoi(strings.TrimSpace(output[i+len(exitCodeMarker):]))
+	if err != nil {
+		return output, -1
+	}
+
+	// drop the newline echoed ahead of the marker
+	output = output[:i]
+	if strings.HasSuffix(output, "\n") {
+		output = output[:len(output)-1]
+	}
+	return output, code
+}
+
 func parseClusterName(arn string) string {
 	re := regexp.MustCompile("cluster/(.*?)$")
 	return re.FindStringSubmatch(arn)[1]
@@ -0,0 +1,45 @@
+package ecs
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestWrapCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	tests := []struct {
+		command  string
+		output   string
+		exitCode int
+	}{
+		{`echo 'hello world'`, "hello world\n", 0},
+		{`printf "no newline"; exit 3`, "no newline", 3},
+		{`false`, "", 1},
+	}
+
+	for _, test := range tests {
+		b, err := exec.Command("sh", "-c", wrapCommand(test.command)).Output()
+		if err != nil {
+			t.Fatalf("%s: %v", test.command, err)
+		}
+
+		output, exitCode := parseExitCode(string(b))
+		if output != test.output || exitCode != test.exitCode {
+			t.Errorf("%s: got %q (exit code %d), expected %q (exit code %d)", test.command, output, exitCode, test.output, test.exitCode)
+		}
+	}
+}
+
+func TestParseExitCodeFromTerminal(t *testing.T) {
+	output, exitCode := parseExitCode("line\r\n\r\n" + exitCodeMarker + "2\r\n")
+	if output != "line\n" || exitCode != 2 {
+		t.Errorf("got %q (exit code %d)", output, exitCode)
+	}
+
+	if _, exitCode := parseExitCode("session ended early"); exitCode != -1 {
+		t.Errorf("expected a missing exit status to be -1, got %d", exitCode)
+	}
+}
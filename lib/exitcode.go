@@ -0,0 +1,169 @@
+package ecs
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// Exit policies decide the exit code of several tasks from the exit code of each
+const (
+	// ExitPolicyAnyFailure fails with the first task that failed, the default
+	ExitPolicyAnyFailure = "any-failure"
+	// ExitPolicyAllFailure only fails when every task failed, with the first failure
+	ExitPolicyAllFailure = "all-failure"
+	// ExitPolicyFirst uses the task that stopped first
+	ExitPolicyFirst = "first"
+	// ExitPolicyMax uses the highest exit code
+	ExitPolicyMax = "max"
+)
+
+// TaskResult is the exit code of a container of a stopped task
+type TaskResult struct {
+	TaskID    string `json:"taskId" yaml:"taskId"`
+	Container string `json:"container" yaml:"container"`
+	// nil when the container never ran, eg the image couldn't be pulled
+	ExitCode *int64 `json:"exitCode,omitempty" yaml:"exitCode,omitempty"`
+	Reason   string `json:"reason,omitempty" yaml:"reason,omitempty"`
+	// the task's exit code is taken from this container
+	Counted bool `json:"counted" yaml:"counted"`
+}
+
+// checkExitOptions rejects an unknown exit policy, or an ExitCodeFrom container that isn't one of
+// defs, before any task is run
+func (t *Task) checkExitOptions(defs []*ecs.ContainerDefinition) error {
+	switch t.ExitPolicy {
+	case "", ExitPolicyAnyFailure, ExitPolicyAllFailure, ExitPolicyFirst, ExitPolicyMax:
+	default:
+		return fmt.Errorf("unsupported exit policy %s, expected any-failure, all-failure, first or max", t.ExitPolicy)
+	}
+
+	if t.ExitCodeFrom == "" {
+		return nil
+	}
+	for _, def := range defs {
+		if aws.StringValue(def.Name) == t.ExitCodeFrom {
+			return nil
+		}
+	}
+	return fmt.Errorf("container %s not found in task definition %s", t.ExitCodeFrom, t.Family)
+}
+
+// exitCodeContainer is the container whose exit code is the task's
+func (t *Task) exitCodeContainer() string {
+	if t.ExitCodeFrom != "" {
+		return t.ExitCodeFrom
+	}
+	return t.mainContainerName()
+}
+
+// taskTracker records the tasks as they stop, each exactly once
+type taskTracker struct {
+	container string
+	pending   map[string]bool
+	// exit code of each stopped task in the order they stopped, 1 when unknown
+	exitCodes []int64
+	results   []TaskResult
+}
+
+func newTaskTracker(t *Task) *taskTracker {
+	tracker := &taskTracker{container: t.exitCodeContainer(), pending: map[string]bool{}}
+	for _, task := range t.Tasks {
+		tracker.pending[aws.StringValue(task.TaskArn)] = true
+	}
+	return tracker
+}
+
+// stopped records a stopped task and returns the exit code of its counted container, false when
+// it was already recorded
+func (tr *taskTracker) stopped(task *ecs.Task) (*int64, bool) {
+	arn := aws.StringValue(task.TaskArn)
+	if !tr.pending[arn] {
+		return nil, false
+	}
+	delete(tr.pending, arn)
+
+	var exitCode *int64
+	for _, container := range task.Containers {
+		name := aws.StringValue(container.Name)
+		counted := name == tr.container || tr.container == ""
+		if counted && exitCode == nil {
+			exitCode = container.ExitCode
+		}
+
+		reason := aws.StringValue(container.Reason)
+		if reason == "" {
+			reason = aws.StringValue(task.StoppedReason)
+		}
+		tr.results = append(tr.results, TaskResult{
+			TaskID:    parseTaskId(arn),
+			Container: name,
+			ExitCode:  container.ExitCode,
+			Reason:    reason,
+			Counted:   counted,
+		})
+	}
+
+	// a container that never ran failed
+	code := int64(1)
+	if exitCode != nil {
+		code = *exitCode
+	}
+	tr.exitCodes = append(tr.exitCodes, code)
+	return exitCode, true
+}
+
+// missing records a task ECS no longer knows about as failed, false when it was already recorded
+func (tr *taskTracker) missing(failure *ecs.Failure) bool {
+	arn := aws.StringValue(failure.Arn)
+	if !tr.pending[arn] {
+		return false
+	}
+	delete(tr.pending, arn)
+	tr.exitCodes = append(tr.exitCodes, 1)
+	tr.results = append(tr.results, TaskResult{
+		TaskID:    parseTaskId(arn),
+		Container: tr.container,
+		Reason:    aws.StringValue(failure.Reason),
+		Counted:   true,
+	})
+	return true
+}
+
+func (tr *taskTracker) done() bool {
+	return len(tr.pending) == 0
+}
+
+// exitCode applies the policy to the exit codes of the stopped tasks
+func (tr *taskTracker) exitCode(policy string) int64 {
+	var first, failures, max int64
+	for _, code := range tr.exitCodes {
+		if code != 0 {
+			if failures == 0 {
+				first = code
+			}
+			failures++
+		}
+		if code > max {
+			max = code
+		}
+	}
+
+	switch policy {
+	case ExitPolicyFirst:
+		if len(tr.exitCodes) > 0 {
+			return tr.exitCodes[0]
+		}
+		return 0
+	case ExitPolicyMax:
+		return max
+	case ExitPolicyAllFailure:
+		if failures > 0 && failures == int64(len(tr.exitCodes)) {
+			return first
+		}
+		return 0
+	default:
+		return first
+	}
+}
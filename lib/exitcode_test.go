@@ -0,0 +1,119 @@
+package ecs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/justmiles/ecs-cli/lib/fake"
+)
+
+func TestExitPolicy(t *testing.T) {
+	tests := []struct {
+		exitCodes []int64
+		policy    string
+		exitCode  int64
+	}{
+		{[]int64{0, 1, 2}, "", 1},
+		{[]int64{0, 1, 2}, ExitPolicyAnyFailure, 1},
+		{[]int64{0, 1, 2}, ExitPolicyAllFailure, 0},
+		{[]int64{3, 1, 2}, ExitPolicyAllFailure, 3},
+		{[]int64{0, 1, 2}, ExitPolicyFirst, 0},
+		{[]int64{2, 0, 0}, ExitPolicyFirst, 2},
+		{[]int64{0, 1, 2}, ExitPolicyMax, 2},
+		{[]int64{0, 0}, ExitPolicyAnyFailure, 0},
+	}
+
+	for _, test := range tests {
+		tracker := &taskTracker{exitCodes: test.exitCodes}
+		if exitCode := tracker.exitCode(test.policy); exitCode != test.exitCode {
+			t.Errorf("%v %s: expected %d, got %d", test.exitCodes, test.policy, test.exitCode, exitCode)
+		}
+	}
+}
+
+func TestWaitMultipleTasks(t *testing.T) {
+	for _, test := range []struct {
+		policy   string
+		exitCode int64
+	}{
+		{ExitPolicyAnyFailure, 1},
+		{ExitPolicyAllFailure, 0},
+		{ExitPolicyFirst, 0},
+		{ExitPolicyMax, 2},
+	} {
+		c, b, recorder := newFakeClient()
+		runs := []fake.ContainerRun{
+			{RunTime: 5 * time.Second, ExitCode: 0},
+			{RunTime: 10 * time.Second, ExitCode: 1},
+			{RunTime: 20 * time.Second, ExitCode: 2},
+		}
+		started := 0
+		b.ECS.Run = func(task *ecs.Task, container *ecs.ContainerDefinition) fake.ContainerRun {
+			started++
+			return runs[started-1]
+		}
+
+		task := newFakeTask()
+		task.Count = 3
+		task.ExitPolicy = test.policy
+		if err := c.Run(context.Background(), task); err != nil {
+			t.Fatal(err)
+		}
+
+		exitCode, err := follow(c, task)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exitCode != test.exitCode {
+			t.Errorf("%s: expected %d, got %d", test.policy, test.exitCode, exitCode)
+		}
+		if stopped := eventsOfType(recorder.events, EventTaskStopped); len(stopped) != 3 {
+			t.Errorf("%s: expected each task to be reported once, got %d", test.policy, len(stopped))
+		}
+		if len(task.Results) != 3 || aws.Int64Value(task.Results[2].ExitCode) != 2 {
+			t.Errorf("%s: unexpected results %v", test.policy, task.Results)
+		}
+	}
+}
+
+func TestExitCodeFrom(t *testing.T) {
+	c, b, _ := newFakeClient()
+	b.ECS.Run = func(task *ecs.Task, container *ecs.ContainerDefinition) fake.ContainerRun {
+		if aws.StringValue(container.Name) == "migrate" {
+			return fake.ContainerRun{RunTime: time.Second, ExitCode: 7}
+		}
+		return fake.ContainerRun{RunTime: 5 * time.Second}
+	}
+
+	task := newFakeTask()
+	task.Containers = []Container{{Name: "migrate", Image: "migrate", MemoryReservation: 128}}
+	task.ExitCodeFrom = "migrate"
+	if err := c.Run(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+
+	exitCode, err := follow(c, task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 7 {
+		t.Errorf("expected the exit code of the sidecar, got %d", exitCode)
+	}
+	for _, result := range task.Results {
+		if result.Counted != (result.Container == "migrate") {
+			t.Errorf("expected only the sidecar to be counted, got %v", result)
+		}
+	}
+
+	task = newFakeTask()
+	task.ExitCodeFrom = "unknown"
+	if err := c.Run(context.Background(), task); err == nil {
+		t.Errorf("expected an unknown container to be rejected")
+	}
+	if len(b.ECS.Tasks()) != 1 {
+		t.Errorf("expected no task to be run")
+	}
+}
@@ -0,0 +1,197 @@
+package fake
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+)
+
+// EC2 is an in-memory EC2 holding the instances, subnets and security groups tasks run on.
+// Calls it doesn't implement panic.
+type EC2 struct {
+	ec2iface.EC2API
+
+	mu             sync.Mutex
+	faults         faults
+	instances      []*ec2.Instance
+	subnets        []*ec2.Subnet
+	securityGroups []*ec2.SecurityGroup
+}
+
+// NewEC2 returns an EC2 without any resources
+func NewEC2() *EC2 {
+	return &EC2{}
+}
+
+// Fail makes the next call of operation, eg DescribeSubnets, return err
+func (e *EC2) Fail(operation string, err error) {
+	e.faults.add(operation, err)
+}
+
+// AddInstance adds a running instance, publicIP may be empty
+func (e *EC2) AddInstance(id, privateIP, publicIP string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	instance := &ec2.Instance{
+		InstanceId:       aws.String(id),
+		PrivateIpAddress: aws.String(privateIP),
+		State:            &ec2.InstanceState{Name: aws.String(ec2.InstanceStateNameRunning)},
+	}
+	if publicIP != "" {
+		instance.PublicIpAddress = aws.String(publicIP)
+	}
+	e.instances = append(e.instances, instance)
+}
+
+// AddSubnet adds a subnet, tags are KEY=VALUE pairs
+func (e *EC2) AddSubnet(id, vpcID, availabilityZone string, tags ...string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	subnet := &ec2.Subnet{
+		SubnetId:         aws.String(id),
+		VpcId:            aws.String(vpcID),
+		AvailabilityZone: aws.String(availabilityZone),
+	}
+	for _, tag := range tags {
+		kv := strings.SplitN(tag, "=", 2)
+		subnet.Tags = append(subnet.Tags, &ec2.Tag{Key: aws.String(kv[0]), Value: aws.String(kv[len(kv)-1])})
+	}
+	e.subnets = append(e.subnets, subnet)
+}
+
+// AddSecurityGroup adds a security group
+func (e *EC2) AddSecurityGroup(id, name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.securityGroups = append(e.securityGroups, &ec2.SecurityGroup{
+		GroupId:   aws.String(id),
+		GroupName: aws.String(name),
+	})
+}
+
+// DescribeInstancesWithContext describes instances by ID
+func (e *EC2) DescribeInstancesWithContext(ctx aws.Context, input *ec2.DescribeInstancesInput, opts ...request.Option) (*ec2.DescribeInstancesOutput, error) {
+	if err := e.faults.next("DescribeInstances"); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	reservation := &ec2.Reservation{}
+	for _, id := range input.InstanceIds {
+		found := false
+		for _, instance := range e.instances {
+			if aws.StringValue(instance.InstanceId) == aws.StringValue(id) {
+				var copied ec2.Instance
+				clone(&copied, instance)
+				reservation.Instances = append(reservation.Instances, &copied)
+				found = true
+			}
+		}
+		if !found {
+			return nil, awserr.New("InvalidInstanceID.NotFound", fmt.Sprintf("The instance ID '%s' does not exist", aws.StringValue(id)), nil)
+		}
+	}
+
+	output := &ec2.DescribeInstancesOutput{}
+	if len(reservation.Instances) > 0 {
+		output.Reservations = []*ec2.Reservation{reservation}
+	}
+	return output, nil
+}
+
+// DescribeSubnetsWithContext supports the subnet-id, vpc-id, availability-zone and tag:KEY
+// filters, values may contain wildcards
+func (e *EC2) DescribeSubnetsWithContext(ctx aws.Context, input *ec2.DescribeSubnetsInput, opts ...request.Option) (*ec2.DescribeSubnetsOutput, error) {
+	if err := e.faults.next("DescribeSubnets"); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	output := &ec2.DescribeSubnetsOutput{}
+	for _, subnet := range e.subnets {
+		matches := true
+		for _, filter := range input.Filters {
+			var value *string
+			name := aws.StringValue(filter.Name)
+			switch {
+			case name == "subnet-id":
+				value = subnet.SubnetId
+			case name == "vpc-id":
+				value = subnet.VpcId
+			case name == "availability-zone":
+				value = subnet.AvailabilityZone
+			case strings.HasPrefix(name, "tag:"):
+				value = tagValue(subnet.Tags, strings.TrimPrefix(name, "tag:"))
+			default:
+				return nil, awserr.New("InvalidParameterValue", fmt.Sprintf("The filter '%s' is invalid", name), nil)
+			}
+			matches = matches && value != nil && matchesAny(*value, filter.Values)
+		}
+		if matches {
+			var copied ec2.Subnet
+			clone(&copied, subnet)
+			output.Subnets = append(output.Subnets, &copied)
+		}
+	}
+	return output, nil
+}
+
+// DescribeSecurityGroupsWithContext supports the group-name and group-id filters
+func (e *EC2) DescribeSecurityGroupsWithContext(ctx aws.Context, input *ec2.DescribeSecurityGroupsInput, opts ...request.Option) (*ec2.DescribeSecurityGroupsOutput, error) {
+	if err := e.faults.next("DescribeSecurityGroups"); err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	output := &ec2.DescribeSecurityGroupsOutput{}
+	for _, group := range e.securityGroups {
+		matches := true
+		for _, filter := range input.Filters {
+			switch name := aws.StringValue(filter.Name); name {
+			case "group-name":
+				matches = matches && matchesAny(*group.GroupName, filter.Values)
+			case "group-id":
+				matches = matches && matchesAny(*group.GroupId, filter.Values)
+			default:
+				return nil, awserr.New("InvalidParameterValue", fmt.Sprintf("The filter '%s' is invalid", name), nil)
+			}
+		}
+		if matches {
+			var copied ec2.SecurityGroup
+			clone(&copied, group)
+			output.SecurityGroups = append(output.SecurityGroups, &copied)
+		}
+	}
+	return output, nil
+}
+
+func tagValue(tags []*ec2.Tag, key string) *string {
+	for _, tag := range tags {
+		if aws.StringValue(tag.Key) == key {
+			return tag.Value
+		}
+	}
+	return nil
+}
+
+func matchesAny(value string, patterns []*string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(aws.StringValue(pattern), value); ok {
+			return true
+		}
+	}
+	return false
+}
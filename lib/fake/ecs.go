@@ -0,0 +1,795 @@
+package fake
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+)
+
+// ContainerRun is how a container of a started task behaves
+type ContainerRun struct {
+	// Output is written to the awslogs stream of the container when it starts
+	Output []string
+	// RunTime from the task reaching RUNNING until the container exits
+	RunTime  time.Duration
+	ExitCode int64
+	// Reason the container stopped, eg OutOfMemoryError
+	Reason string
+}
+
+// ECS is an in-memory ECS. Task definitions keep their revisions and tasks move through
+// PROVISIONING, PENDING, RUNNING and STOPPED as the clock advances. Calls it doesn't implement
+// panic.
+type ECS struct {
+	ecsiface.ECSAPI
+
+	Clock *Clock
+	// Logs receives the output of containers using the awslogs driver, nil discards it
+	Logs *Logs
+
+	Region    string
+	AccountID string
+
+	// time a task spends in PROVISIONING and then in PENDING
+	ProvisioningTime time.Duration
+	PendingTime      time.Duration
+
+	// Run decides how each container of a started task behaves. By default containers exit 0
+	// after a second.
+	Run func(task *ecs.Task, container *ecs.ContainerDefinition) ContainerRun
+
+	mu       sync.Mutex
+	faults   faults
+	families map[string][]*registration
+	clusters map[string]*cluster
+	tasks    []*task
+	nextID   int
+}
+
+type registration struct {
+	definition *ecs.TaskDefinition
+	tags       []*ecs.Tag
+}
+
+type cluster struct {
+	cluster   *ecs.Cluster
+	instances []*ecs.ContainerInstance
+	services  []*ecs.Service
+}
+
+type task struct {
+	task       *ecs.Task
+	definition *ecs.TaskDefinition
+	runs       []ContainerRun
+	logged     []bool
+
+	stopRequested *time.Time
+	stopReason    string
+}
+
+// NewECS returns an ECS in us-east-1 with an empty default cluster
+func NewECS(clock *Clock, logs *Logs) *ECS {
+	f := &ECS{
+		Clock:            clock,
+		Logs:             logs,
+		Region:           "us-east-1",
+		AccountID:        "000000000000",
+		ProvisioningTime: 2 * time.Second,
+		PendingTime:      2 * time.Second,
+		families:         map[string][]*registration{},
+		clusters:         map[string]*cluster{},
+	}
+	f.AddCluster("default")
+	return f
+}
+
+// Fail makes the next call of operation, eg RunTask, return err
+func (f *ECS) Fail(operation string, err error) {
+	f.faults.add(operation, err)
+}
+
+// AddCluster creates an ACTIVE cluster associated with the capacity providers
+func (f *ECS) AddCluster(name string, capacityProviders ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.clusters[name] = &cluster{cluster: &ecs.Cluster{
+		ClusterArn:        aws.String(f.arn("cluster/" + name)),
+		ClusterName:       aws.String(name),
+		Status:            aws.String("ACTIVE"),
+		CapacityProviders: aws.StringSlice(capacityProviders),
+	}}
+}
+
+// AddContainerInstance registers an EC2 instance with a cluster and returns its ARN. Tasks
+// launched on EC2 are placed on the first instance.
+func (f *ECS) AddContainerInstance(clusterName, ec2InstanceID string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.clusters[clusterName]
+	f.nextID++
+	arn := f.arn(fmt.Sprintf("container-instance/%s/%032x", clusterName, f.nextID))
+	c.instances = append(c.instances, &ecs.ContainerInstance{
+		ContainerInstanceArn: aws.String(arn),
+		Ec2InstanceId:        aws.String(ec2InstanceID),
+		Status:               aws.String("ACTIVE"),
+		AgentConnected:       aws.Bool(true),
+	})
+	return arn
+}
+
+// AddService adds a service to a cluster
+func (f *ECS) AddService(clusterName string, service *ecs.Service) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := f.clusters[clusterName]
+	var copied ecs.Service
+	clone(&copied, service)
+	copied.ClusterArn = c.cluster.ClusterArn
+	if copied.ServiceArn == nil {
+		copied.ServiceArn = aws.String(f.arn("service/" + clusterName + "/" + aws.StringValue(service.ServiceName)))
+	}
+	if copied.Status == nil {
+		copied.Status = aws.String("ACTIVE")
+	}
+	c.services = append(c.services, &copied)
+}
+
+// TaskDefinition returns a revision by ARN, family:revision or the latest active revision of a
+// family, nil when there is none
+func (f *ECS) TaskDefinition(ref string) *ecs.TaskDefinition {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r := f.registration(ref)
+	if r == nil {
+		return nil
+	}
+	var copied ecs.TaskDefinition
+	clone(&copied, r.definition)
+	return &copied
+}
+
+// Tasks returns every task started so far
+func (f *ECS) Tasks() []*ecs.Task {
+	now := f.Clock.Now()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var tasks []*ecs.Task
+	for _, t := range f.tasks {
+		tasks = append(tasks, f.describe(t, now))
+	}
+	return tasks
+}
+
+// RegisterTaskDefinitionWithContext registers the next revision of a family
+func (f *ECS) RegisterTaskDefinitionWithContext(ctx aws.Context, input *ecs.RegisterTaskDefinitionInput, opts ...request.Option) (*ecs.RegisterTaskDefinitionOutput, error) {
+	if err := f.faults.next("RegisterTaskDefinition"); err != nil {
+		return nil, err
+	}
+	if aws.StringValue(input.Family) == "" || len(input.ContainerDefinitions) == 0 {
+		return nil, awserr.New(ecs.ErrCodeClientException, "Family and container definitions are required.", nil)
+	}
+	now := f.Clock.Now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// the input and the definition share their field names
+	definition := &ecs.TaskDefinition{}
+	clone(definition, input)
+
+	family := aws.StringValue(input.Family)
+	revision := len(f.families[family]) + 1
+	definition.Revision = aws.Int64(int64(revision))
+	definition.TaskDefinitionArn = aws.String(f.arn(fmt.Sprintf("task-definition/%s:%d", family, revision)))
+	definition.Status = aws.String(ecs.TaskDefinitionStatusActive)
+	definition.RegisteredAt = aws.Time(now)
+	definition.Compatibilities = aws.StringSlice([]string{ecs.CompatibilityEc2})
+	for _, c := range input.RequiresCompatibilities {
+		if aws.StringValue(c) == ecs.CompatibilityFargate {
+			definition.Compatibilities = append(definition.Compatibilities, c)
+		}
+	}
+
+	r := &registration{definition: definition}
+	clone(&r.tags, input.Tags)
+	f.families[family] = append(f.families[family], r)
+
+	output := &ecs.RegisterTaskDefinitionOutput{}
+	clone(output, registrationOutput{definition, r.tags})
+	return output, nil
+}
+
+type registrationOutput struct {
+	TaskDefinition *ecs.TaskDefinition
+	Tags           []*ecs.Tag
+}
+
+// DescribeTaskDefinitionWithContext describes a revision, a family resolves to its latest
+// active revision
+func (f *ECS) DescribeTaskDefinitionWithContext(ctx aws.Context, input *ecs.DescribeTaskDefinitionInput, opts ...request.Option) (*ecs.DescribeTaskDefinitionOutput, error) {
+	if err := f.faults.next("DescribeTaskDefinition"); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	r := f.registration(aws.StringValue(input.TaskDefinition))
+	if r == nil {
+		return nil, awserr.New(ecs.ErrCodeClientException, "Unable to describe task definition.", nil)
+	}
+
+	output := registrationOutput{TaskDefinition: r.definition}
+	for _, include := range input.Include {
+		if aws.StringValue(include) == "TAGS" {
+			output.Tags = r.tags
+		}
+	}
+	described := &ecs.DescribeTaskDefinitionOutput{}
+	clone(described, output)
+	return described, nil
+}
+
+// DeregisterTaskDefinitionWithContext marks a revision INACTIVE
+func (f *ECS) DeregisterTaskDefinitionWithContext(ctx aws.Context, input *ecs.DeregisterTaskDefinitionInput, opts ...request.Option) (*ecs.DeregisterTaskDefinitionOutput, error) {
+	if err := f.faults.next("DeregisterTaskDefinition"); err != nil {
+		return nil, err
+	}
+	now := f.Clock.Now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ref := aws.StringValue(input.TaskDefinition)
+	r := f.registration(ref)
+	if r == nil || !strings.Contains(ref, ":") {
+		return nil, awserr.New(ecs.ErrCodeClientException, "The specified task definition does not exist.", nil)
+	}
+	r.definition.Status = aws.String(ecs.TaskDefinitionStatusInactive)
+	r.definition.DeregisteredAt = aws.Time(now)
+
+	output := &ecs.DeregisterTaskDefinitionOutput{}
+	clone(&output.TaskDefinition, r.definition)
+	return output, nil
+}
+
+// DeleteTaskDefinitionsWithContext deletes INACTIVE revisions
+func (f *ECS) DeleteTaskDefinitionsWithContext(ctx aws.Context, input *ecs.DeleteTaskDefinitionsInput, opts ...request.Option) (*ecs.DeleteTaskDefinitionsOutput, error) {
+	if err := f.faults.next("DeleteTaskDefinitions"); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	output := &ecs.DeleteTaskDefinitionsOutput{}
+	for _, ref := range input.TaskDefinitions {
+		r := f.registration(aws.StringValue(ref))
+		switch {
+		case r == nil:
+			output.Failures = append(output.Failures, &ecs.Failure{Arn: ref, Reason: aws.String("The specified task definition does not exist.")})
+		case aws.StringValue(r.definition.Status) == ecs.TaskDefinitionStatusActive:
+			output.Failures = append(output.Failures, &ecs.Failure{Arn: ref, Reason: aws.String("The specified task definition is still in ACTIVE status. Please deregister the target and try again.")})
+		default:
+			r.definition.Status = aws.String(ecs.TaskDefinitionStatusDeleteInProgress)
+			var copied ecs.TaskDefinition
+			clone(&copied, r.definition)
+			output.TaskDefinitions = append(output.TaskDefinitions, &copied)
+		}
+	}
+	return output, nil
+}
+
+// RunTaskWithContext starts tasks from an active revision. Tasks launched on EC2 need a
+// container instance in the cluster.
+func (f *ECS) RunTaskWithContext(ctx aws.Context, input *ecs.RunTaskInput, opts ...request.Option) (*ecs.RunTaskOutput, error) {
+	if err := f.faults.next("RunTask"); err != nil {
+		return nil, err
+	}
+	now := f.Clock.Now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c := f.cluster(aws.StringValue(input.Cluster))
+	if c == nil {
+		return nil, awserr.New(ecs.ErrCodeClusterNotFoundException, "Cluster not found.", nil)
+	}
+
+	r := f.registration(aws.StringValue(input.TaskDefinition))
+	if r == nil {
+		return nil, awserr.New(ecs.ErrCodeInvalidParameterException, "TaskDefinition not found.", nil)
+	}
+	if aws.StringValue(r.definition.Status) != ecs.TaskDefinitionStatusActive {
+		return nil, awserr.New(ecs.ErrCodeInvalidParameterException, "TaskDefinition is inactive", nil)
+	}
+
+	count := aws.Int64Value(input.Count)
+	if input.Count == nil {
+		count = 1
+	}
+	if count < 1 || count > 10 {
+		return nil, awserr.New(ecs.ErrCodeInvalidParameterException, "Count must be between 1 and 10.", nil)
+	}
+
+	launchType := aws.StringValue(input.LaunchType)
+	strategy := input.CapacityProviderStrategy
+	if launchType == "" && len(strategy) == 0 {
+		strategy = c.cluster.DefaultCapacityProviderStrategy
+		if len(strategy) == 0 {
+			launchType = ecs.LaunchTypeEc2
+		}
+	}
+	if launchType == ecs.LaunchTypeEc2 && len(c.instances) == 0 {
+		return nil, awserr.New(ecs.ErrCodeInvalidParameterException, "No Container Instances were found in your cluster.", nil)
+	}
+
+	if aws.StringValue(r.definition.NetworkMode) == ecs.NetworkModeAwsvpc {
+		if input.NetworkConfiguration == nil || input.NetworkConfiguration.AwsvpcConfiguration == nil || len(input.NetworkConfiguration.AwsvpcConfiguration.Subnets) == 0 {
+			return nil, awserr.New(ecs.ErrCodeInvalidParameterException, "Network Configuration must be provided when networkMode 'awsvpc' is specified.", nil)
+		}
+	}
+
+	group := aws.StringValue(input.Group)
+	if group == "" {
+		group = "family:" + aws.StringValue(r.definition.Family)
+	}
+
+	output := &ecs.RunTaskOutput{}
+	for i := int64(0); i < count; i++ {
+		f.nextID++
+		id := fmt.Sprintf("%032x", f.nextID)
+		taskArn := f.arn("task/" + aws.StringValue(c.cluster.ClusterName) + "/" + id)
+
+		t := &task{definition: r.definition, task: &ecs.Task{
+			TaskArn:              aws.String(taskArn),
+			ClusterArn:           c.cluster.ClusterArn,
+			TaskDefinitionArn:    r.definition.TaskDefinitionArn,
+			CreatedAt:            aws.Time(now),
+			DesiredStatus:        aws.String(ecs.DesiredStatusRunning),
+			LastStatus:           aws.String("PROVISIONING"),
+			Group:                aws.String(group),
+			StartedBy:            input.StartedBy,
+			Overrides:            input.Overrides,
+			Tags:                 input.Tags,
+			Cpu:                  r.definition.Cpu,
+			Memory:               r.definition.Memory,
+			EnableExecuteCommand: input.EnableExecuteCommand,
+			PlatformVersion:      input.PlatformVersion,
+		}}
+		if launchType != "" {
+			t.task.LaunchType = aws.String(launchType)
+		} else {
+			t.task.CapacityProviderName = strategy[0].CapacityProvider
+		}
+		if launchType == ecs.LaunchTypeEc2 {
+			// spread the tasks over the instances
+			t.task.ContainerInstanceArn = c.instances[i%int64(len(c.instances))].ContainerInstanceArn
+		}
+
+		for n, def := range r.definition.ContainerDefinitions {
+			t.task.Containers = append(t.task.Containers, &ecs.Container{
+				Name:         def.Name,
+				Image:        def.Image,
+				ContainerArn: aws.String(f.arn(fmt.Sprintf("container/%s/%s/%d", aws.StringValue(c.cluster.ClusterName), id, n))),
+				TaskArn:      aws.String(taskArn),
+				RuntimeId:    aws.String(fmt.Sprintf("%s-%d", id, n)),
+			})
+
+			run := ContainerRun{RunTime: time.Second}
+			if f.Run != nil {
+				run = f.Run(t.task, def)
+			}
+			t.runs = append(t.runs, run)
+			t.logged = append(t.logged, false)
+		}
+
+		f.tasks = append(f.tasks, t)
+		output.Tasks = append(output.Tasks, f.describe(t, now))
+	}
+	return output, nil
+}
+
+// DescribeTasksWithContext describes tasks by ARN or ID, unknown tasks are reported as MISSING.
+// Tags are only included when asked for.
+func (f *ECS) DescribeTasksWithContext(ctx aws.Context, input *ecs.DescribeTasksInput, opts ...request.Option) (*ecs.DescribeTasksOutput, error) {
+	if err := f.faults.next("DescribeTasks"); err != nil {
+		return nil, err
+	}
+	now := f.Clock.Now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c := f.cluster(aws.StringValue(input.Cluster))
+	if c == nil {
+		return nil, awserr.New(ecs.ErrCodeClusterNotFoundException, "Cluster not found.", nil)
+	}
+
+	tags := false
+	for _, include := range input.Include {
+		tags = tags || aws.StringValue(include) == ecs.TaskFieldTags
+	}
+
+	output := &ecs.DescribeTasksOutput{}
+	for _, ref := range input.Tasks {
+		if t := f.task(c, aws.StringValue(ref)); t != nil {
+			described := f.describe(t, now)
+			if !tags {
+				described.Tags = nil
+			}
+			output.Tasks = append(output.Tasks, described)
+		} else {
+			output.Failures = append(output.Failures, &ecs.Failure{Arn: ref, Reason: aws.String("MISSING")})
+		}
+	}
+	return output, nil
+}
+
+// StopTaskWithContext stops a task, its running containers exit with 143
+func (f *ECS) StopTaskWithContext(ctx aws.Context, input *ecs.StopTaskInput, opts ...request.Option) (*ecs.StopTaskOutput, error) {
+	if err := f.faults.next("StopTask"); err != nil {
+		return nil, err
+	}
+	now := f.Clock.Now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c := f.cluster(aws.StringValue(input.Cluster))
+	if c == nil {
+		return nil, awserr.New(ecs.ErrCodeClusterNotFoundException, "Cluster not found.", nil)
+	}
+	t := f.task(c, aws.StringValue(input.Task))
+	if t == nil {
+		return nil, awserr.New(ecs.ErrCodeInvalidParameterException, "The referenced task was not found.", nil)
+	}
+
+	if t.stopRequested == nil {
+		t.stopRequested = aws.Time(now)
+		t.stopReason = aws.StringValue(input.Reason)
+		if t.stopReason == "" {
+			t.stopReason = "Task stopped by user"
+		}
+	}
+	return &ecs.StopTaskOutput{Task: f.describe(t, now)}, nil
+}
+
+// DescribeContainerInstancesWithContext describes container instances by ARN
+func (f *ECS) DescribeContainerInstancesWithContext(ctx aws.Context, input *ecs.DescribeContainerInstancesInput, opts ...request.Option) (*ecs.DescribeContainerInstancesOutput, error) {
+	if err := f.faults.next("DescribeContainerInstances"); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c := f.cluster(aws.StringValue(input.Cluster))
+	if c == nil {
+		return nil, awserr.New(ecs.ErrCodeClusterNotFoundException, "Cluster not found.", nil)
+	}
+
+	output := &ecs.DescribeContainerInstancesOutput{}
+	for _, ref := range input.ContainerInstances {
+		found := false
+		for _, instance := range c.instances {
+			if aws.StringValue(instance.ContainerInstanceArn) == aws.StringValue(ref) {
+				var copied ecs.ContainerInstance
+				clone(&copied, instance)
+				output.ContainerInstances = append(output.ContainerInstances, &copied)
+				found = true
+			}
+		}
+		if !found {
+			output.Failures = append(output.Failures, &ecs.Failure{Arn: ref, Reason: aws.String("MISSING")})
+		}
+	}
+	return output, nil
+}
+
+// DescribeClustersWithContext describes clusters by name or ARN, the default cluster when none
+// is given
+func (f *ECS) DescribeClustersWithContext(ctx aws.Context, input *ecs.DescribeClustersInput, opts ...request.Option) (*ecs.DescribeClustersOutput, error) {
+	if err := f.faults.next("DescribeClusters"); err != nil {
+		return nil, err
+	}
+	now := f.Clock.Now()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	refs := input.Clusters
+	if len(refs) == 0 {
+		refs = aws.StringSlice([]string{"default"})
+	}
+
+	output := &ecs.DescribeClustersOutput{}
+	for _, ref := range refs {
+		c := f.cluster(aws.StringValue(ref))
+		if c == nil {
+			output.Failures = append(output.Failures, &ecs.Failure{Arn: ref, Reason: aws.String("MISSING")})
+			continue
+		}
+
+		var described ecs.Cluster
+		clone(&described, c.cluster)
+		var running, pending int64
+		for _, t := range f.tasks {
+			if aws.StringValue(t.task.ClusterArn) != aws.StringValue(c.cluster.ClusterArn) {
+				continue
+			}
+			switch aws.StringValue(f.describe(t, now).LastStatus) {
+			case ecs.DesiredStatusRunning:
+				running++
+			case ecs.DesiredStatusStopped:
+			default:
+				pending++
+			}
+		}
+		described.RunningTasksCount = aws.Int64(running)
+		described.PendingTasksCount = aws.Int64(pending)
+		described.ActiveServicesCount = aws.Int64(int64(len(c.services)))
+		described.RegisteredContainerInstancesCount = aws.Int64(int64(len(c.instances)))
+		output.Clusters = append(output.Clusters, &described)
+	}
+	return output, nil
+}
+
+// DescribeServicesWithContext describes services by name or ARN
+func (f *ECS) DescribeServicesWithContext(ctx aws.Context, input *ecs.DescribeServicesInput, opts ...request.Option) (*ecs.DescribeServicesOutput, error) {
+	if err := f.faults.next("DescribeServices"); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c := f.cluster(aws.StringValue(input.Cluster))
+	if c == nil {
+		return nil, awserr.New(ecs.ErrCodeClusterNotFoundException, "Cluster not found.", nil)
+	}
+
+	output := &ecs.DescribeServicesOutput{}
+	for _, ref := range input.Services {
+		found := false
+		for _, service := range c.services {
+			if aws.StringValue(service.ServiceName) == aws.StringValue(ref) || aws.StringValue(service.ServiceArn) == aws.StringValue(ref) {
+				var copied ecs.Service
+				clone(&copied, service)
+				output.Services = append(output.Services, &copied)
+				found = true
+			}
+		}
+		if !found {
+			output.Failures = append(output.Failures, &ecs.Failure{Arn: ref, Reason: aws.String("MISSING")})
+		}
+	}
+	return output, nil
+}
+
+// ListClustersPagesWithContext lists every cluster in a single page
+func (f *ECS) ListClustersPagesWithContext(ctx aws.Context, input *ecs.ListClustersInput, fn func(*ecs.ListClustersOutput, bool) bool, opts ...request.Option) error {
+	if err := f.faults.next("ListClusters"); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	output := &ecs.ListClustersOutput{}
+	for _, c := range f.clusters {
+		output.ClusterArns = append(output.ClusterArns, aws.String(aws.StringValue(c.cluster.ClusterArn)))
+	}
+	f.mu.Unlock()
+
+	sort.Slice(output.ClusterArns, func(i, j int) bool {
+		return *output.ClusterArns[i] < *output.ClusterArns[j]
+	})
+	fn(output, true)
+	return nil
+}
+
+// ListTasksPagesWithContext lists the tasks of a cluster in a single page, filtered by family,
+// service, started by and desired status (RUNNING unless given). Like ECS, started by can't be
+// combined with another filter.
+func (f *ECS) ListTasksPagesWithContext(ctx aws.Context, input *ecs.ListTasksInput, fn func(*ecs.ListTasksOutput, bool) bool, opts ...request.Option) error {
+	if err := f.faults.next("ListTasks"); err != nil {
+		return err
+	}
+	now := f.Clock.Now()
+
+	f.mu.Lock()
+	c := f.cluster(aws.StringValue(input.Cluster))
+	if c == nil {
+		f.mu.Unlock()
+		return awserr.New(ecs.ErrCodeClusterNotFoundException, "Cluster not found.", nil)
+	}
+
+	if input.StartedBy != nil && (input.DesiredStatus != nil || input.Family != nil || input.ServiceName != nil || input.ContainerInstance != nil || input.LaunchType != nil) {
+		f.mu.Unlock()
+		return awserr.New(ecs.ErrCodeInvalidParameterException, "startedBy must be the only filter.", nil)
+	}
+
+	desiredStatus := aws.StringValue(input.DesiredStatus)
+	if desiredStatus == "" {
+		desiredStatus = ecs.DesiredStatusRunning
+	}
+
+	output := &ecs.ListTasksOutput{}
+	for _, t := range f.tasks {
+		described := f.describe(t, now)
+		switch {
+		case aws.StringValue(described.ClusterArn) != aws.StringValue(c.cluster.ClusterArn),
+			aws.StringValue(described.DesiredStatus) != desiredStatus,
+			input.Family != nil && aws.StringValue(t.definition.Family) != *input.Family,
+			input.ServiceName != nil && aws.StringValue(described.Group) != "service:"+*input.ServiceName,
+			input.StartedBy != nil && aws.StringValue(described.StartedBy) != *input.StartedBy:
+			continue
+		}
+		output.TaskArns = append(output.TaskArns, described.TaskArn)
+	}
+	f.mu.Unlock()
+
+	fn(output, true)
+	return nil
+}
+
+// describe returns a copy of the task as it is at now
+func (f *ECS) describe(t *task, now time.Time) *ecs.Task {
+	started := aws.TimeValue(t.task.CreatedAt).Add(f.ProvisioningTime + f.PendingTime)
+
+	// the task stops when it is stopped or its first essential container exits
+	var stoppedAt time.Time
+	var stopCode, stoppedReason string
+	if t.stopRequested != nil {
+		stoppedAt, stopCode, stoppedReason = *t.stopRequested, ecs.TaskStopCodeUserInitiated, t.stopReason
+		t.task.DesiredStatus = aws.String(ecs.DesiredStatusStopped)
+	}
+	for i, def := range t.definition.ContainerDefinitions {
+		exited := started.Add(t.runs[i].RunTime)
+		if (def.Essential == nil || *def.Essential) && (stoppedAt.IsZero() || exited.Before(stoppedAt)) {
+			stoppedAt, stopCode, stoppedReason = exited, ecs.TaskStopCodeEssentialContainerExited, "Essential container in task exited"
+		}
+	}
+	stopped := !stoppedAt.IsZero() && !now.Before(stoppedAt)
+
+	switch {
+	case stopped:
+		t.task.LastStatus = aws.String(ecs.DesiredStatusStopped)
+		t.task.DesiredStatus = aws.String(ecs.DesiredStatusStopped)
+		t.task.StoppedAt = aws.Time(stoppedAt)
+		t.task.StopCode = aws.String(stopCode)
+		t.task.StoppedReason = aws.String(stoppedReason)
+	case now.Before(aws.TimeValue(t.task.CreatedAt).Add(f.ProvisioningTime)):
+		t.task.LastStatus = aws.String("PROVISIONING")
+	case now.Before(started):
+		t.task.LastStatus = aws.String(ecs.DesiredStatusPending)
+	default:
+		t.task.LastStatus = aws.String(ecs.DesiredStatusRunning)
+	}
+
+	// whether the containers have been started
+	running := !now.Before(started) && (stoppedAt.IsZero() || started.Before(stoppedAt))
+	if running {
+		t.task.StartedAt = aws.Time(started)
+	}
+
+	for i, container := range t.task.Containers {
+		def := t.definition.ContainerDefinitions[i]
+		exited := started.Add(t.runs[i].RunTime)
+
+		switch {
+		case !running:
+			container.LastStatus = t.task.LastStatus
+			if aws.StringValue(t.task.LastStatus) == "PROVISIONING" {
+				container.LastStatus = aws.String(ecs.DesiredStatusPending)
+			}
+		case !now.Before(exited) && (stoppedAt.IsZero() || !exited.After(stoppedAt)):
+			container.LastStatus = aws.String(ecs.DesiredStatusStopped)
+			container.ExitCode = aws.Int64(t.runs[i].ExitCode)
+			if t.runs[i].Reason != "" {
+				container.Reason = aws.String(t.runs[i].Reason)
+			}
+		case stopped:
+			// stopped with SIGTERM
+			container.LastStatus = aws.String(ecs.DesiredStatusStopped)
+			container.ExitCode = aws.Int64(143)
+		default:
+			container.LastStatus = aws.String(ecs.DesiredStatusRunning)
+		}
+
+		if running && container.NetworkBindings == nil && t.task.ContainerInstanceArn != nil && aws.StringValue(t.definition.NetworkMode) != ecs.NetworkModeAwsvpc {
+			for n, port := range def.PortMappings {
+				hostPort := aws.Int64Value(port.HostPort)
+				if hostPort == 0 {
+					hostPort = int64(49153 + n)
+				}
+				container.NetworkBindings = append(container.NetworkBindings, &ecs.NetworkBinding{
+					BindIP:        aws.String("0.0.0.0"),
+					ContainerPort: port.ContainerPort,
+					HostPort:      aws.Int64(hostPort),
+					Protocol:      port.Protocol,
+				})
+			}
+		}
+
+		if running && !t.logged[i] {
+			t.logged[i] = true
+			f.writeOutput(t, i, started)
+		}
+	}
+
+	var copied ecs.Task
+	clone(&copied, t.task)
+	return &copied
+}
+
+// writeOutput writes a container's output to its awslogs stream, prefix/container/task-id
+func (f *ECS) writeOutput(t *task, i int, started time.Time) {
+	def, run := t.definition.ContainerDefinitions[i], t.runs[i]
+	if f.Logs == nil || len(run.Output) == 0 || def.LogConfiguration == nil || aws.StringValue(def.LogConfiguration.LogDriver) != ecs.LogDriverAwslogs {
+		return
+	}
+	options := aws.StringValueMap(def.LogConfiguration.Options)
+	id := aws.StringValue(t.task.TaskArn)
+	id = id[strings.LastIndex(id, "/")+1:]
+	f.Logs.AppendAt(started, options["awslogs-group"], options["awslogs-stream-prefix"]+"/"+aws.StringValue(def.Name)+"/"+id, run.Output...)
+}
+
+// registration resolves an ARN, family:revision, or a family to its latest active revision
+func (f *ECS) registration(ref string) *registration {
+	if i := strings.Index(ref, "task-definition/"); i >= 0 {
+		ref = ref[i+len("task-definition/"):]
+	}
+
+	family := ref
+	if i := strings.LastIndex(ref, ":"); i >= 0 {
+		family = ref[:i]
+		revision, err := strconv.Atoi(ref[i+1:])
+		if err != nil || revision < 1 || revision > len(f.families[family]) {
+			return nil
+		}
+		return f.families[family][revision-1]
+	}
+
+	revisions := f.families[family]
+	for i := len(revisions) - 1; i >= 0; i-- {
+		if aws.StringValue(revisions[i].definition.Status) == ecs.TaskDefinitionStatusActive {
+			return revisions[i]
+		}
+	}
+	return nil
+}
+
+// cluster resolves a cluster name or ARN, the default cluster when empty
+func (f *ECS) cluster(ref string) *cluster {
+	if ref == "" {
+		ref = "default"
+	}
+	if i := strings.Index(ref, "cluster/"); i >= 0 {
+		ref = ref[i+len("cluster/"):]
+	}
+	return f.clusters[ref]
+}
+
+// task finds a task of the cluster by ARN or ID
+func (f *ECS) task(c *cluster, ref string) *task {
+	for _, t := range f.tasks {
+		arn := aws.StringValue(t.task.TaskArn)
+		if aws.StringValue(t.task.ClusterArn) == aws.StringValue(c.cluster.ClusterArn) && (arn == ref || strings.HasSuffix(arn, "/"+ref)) {
+			return t
+		}
+	}
+	return nil
+}
+
+func (f *ECS) arn(resource string) string {
+	return fmt.Sprintf("arn:aws:ecs:%s:%s:%s", f.Region, f.AccountID, resource)
+}
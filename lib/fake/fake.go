@@ -0,0 +1,102 @@
+// Package fake is an in-memory ECS, CloudWatch Logs and EC2 backend for tests. It keeps
+// task definitions, tasks and log streams, moves tasks through their lifecycle on a clock
+// the test controls and can be told to fail calls.
+package fake
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Backend wires the fakes together: tasks write the output of their containers to Logs and
+// all of them share Clock
+type Backend struct {
+	Clock *Clock
+	ECS   *ECS
+	Logs  *Logs
+	EC2   *EC2
+}
+
+// New returns an empty backend whose clock starts at 2024-01-01 and advances a second on every
+// call, so polling code makes progress without the test driving the clock
+func New() *Backend {
+	clock := NewClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	clock.Step = time.Second
+
+	logs := NewLogs(clock)
+	return &Backend{
+		Clock: clock,
+		ECS:   NewECS(clock, logs),
+		Logs:  logs,
+		EC2:   NewEC2(),
+	}
+}
+
+// Clock is the time seen by the fakes
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+
+	// Step is added after every read of the clock
+	Step time.Duration
+}
+
+// NewClock returns a clock stopped at start
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+// Now returns the current time and then advances the clock by Step
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now
+	c.now = c.now.Add(c.Step)
+	return now
+}
+
+// Advance moves the clock forward
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// faults holds the errors queued per operation, eg RunTask
+type faults struct {
+	mu     sync.Mutex
+	queued map[string][]error
+}
+
+func (f *faults) add(operation string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.queued == nil {
+		f.queued = map[string][]error{}
+	}
+	f.queued[operation] = append(f.queued[operation], err)
+}
+
+// next pops the error queued for the operation, if any
+func (f *faults) next(operation string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	errs := f.queued[operation]
+	if len(errs) == 0 {
+		return nil
+	}
+	f.queued[operation] = errs[1:]
+	return errs[0]
+}
+
+// clone deep copies src into dst so callers never share state with the fakes
+func clone(dst, src interface{}) {
+	b, err := json.Marshal(src)
+	if err != nil {
+		panic(err)
+	}
+	if err := json.Unmarshal(b, dst); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,149 @@
+package fake
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+var ctx = context.Background()
+
+func register(t *testing.T, f *ECS, family string) *ecs.TaskDefinition {
+	output, err := f.RegisterTaskDefinitionWithContext(ctx, &ecs.RegisterTaskDefinitionInput{
+		Family: aws.String(family),
+		ContainerDefinitions: []*ecs.ContainerDefinition{{
+			Name:  aws.String("app"),
+			Image: aws.String("alpine"),
+			LogConfiguration: &ecs.LogConfiguration{
+				LogDriver: aws.String("awslogs"),
+				Options:   aws.StringMap(map[string]string{"awslogs-group": "/app", "awslogs-stream-prefix": "run"}),
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return output.TaskDefinition
+}
+
+func TestTaskDefinitionRevisions(t *testing.T) {
+	f := New().ECS
+
+	first := register(t, f, "app")
+	second := register(t, f, "app")
+	if aws.Int64Value(second.Revision) != 2 || aws.StringValue(second.TaskDefinitionArn) != "arn:aws:ecs:us-east-1:000000000000:task-definition/app:2" {
+		t.Errorf("unexpected revision %v", second)
+	}
+
+	if _, err := f.DeregisterTaskDefinitionWithContext(ctx, &ecs.DeregisterTaskDefinitionInput{TaskDefinition: second.TaskDefinitionArn}); err != nil {
+		t.Fatal(err)
+	}
+	described, err := f.DescribeTaskDefinitionWithContext(ctx, &ecs.DescribeTaskDefinitionInput{TaskDefinition: aws.String("app")})
+	if err != nil || aws.StringValue(described.TaskDefinition.TaskDefinitionArn) != aws.StringValue(first.TaskDefinitionArn) {
+		t.Errorf("expected the family to resolve to the latest active revision, got %v %v", described, err)
+	}
+
+	deleted, _ := f.DeleteTaskDefinitionsWithContext(ctx, &ecs.DeleteTaskDefinitionsInput{TaskDefinitions: []*string{first.TaskDefinitionArn, second.TaskDefinitionArn}})
+	if len(deleted.Failures) != 1 || len(deleted.TaskDefinitions) != 1 {
+		t.Errorf("expected only the inactive revision to be deleted, got %v", deleted)
+	}
+
+	_, err = f.DescribeTaskDefinitionWithContext(ctx, &ecs.DescribeTaskDefinitionInput{TaskDefinition: aws.String("unknown")})
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != ecs.ErrCodeClientException {
+		t.Errorf("expected a ClientException, got %v", err)
+	}
+}
+
+func TestTaskLifecycle(t *testing.T) {
+	b := New()
+	b.Clock.Step = 0
+	b.ECS.AddContainerInstance("default", "i-1")
+	b.ECS.Run = func(task *ecs.Task, container *ecs.ContainerDefinition) ContainerRun {
+		return ContainerRun{Output: []string{"hello"}, RunTime: time.Minute, ExitCode: 2}
+	}
+	register(t, b.ECS, "app")
+
+	output, err := b.ECS.RunTaskWithContext(ctx, &ecs.RunTaskInput{TaskDefinition: aws.String("app")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	arn := output.Tasks[0].TaskArn
+	stream := "run/app/" + aws.StringValue(arn)[len(aws.StringValue(arn))-32:]
+
+	for _, step := range []struct {
+		advance time.Duration
+		status  string
+	}{
+		{0, "PROVISIONING"},
+		{2 * time.Second, "PENDING"},
+		{2 * time.Second, "RUNNING"},
+		{time.Minute, "STOPPED"},
+	} {
+		b.Clock.Advance(step.advance)
+		described, err := b.ECS.DescribeTasksWithContext(ctx, &ecs.DescribeTasksInput{Tasks: []*string{arn}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		task := described.Tasks[0]
+		if aws.StringValue(task.LastStatus) != step.status {
+			t.Fatalf("expected %s, got %s", step.status, aws.StringValue(task.LastStatus))
+		}
+		if step.status == "STOPPED" && aws.Int64Value(task.Containers[0].ExitCode) != 2 {
+			t.Errorf("expected the exit code of the container, got %v", task.Containers[0])
+		}
+	}
+
+	if lines := b.Logs.Events("/app", stream); len(lines) != 1 || lines[0] != "hello" {
+		t.Errorf("expected the output of the container, got %v", lines)
+	}
+
+	described, _ := b.ECS.DescribeTasksWithContext(ctx, &ecs.DescribeTasksInput{Tasks: aws.StringSlice([]string{"unknown"})})
+	if len(described.Failures) != 1 || aws.StringValue(described.Failures[0].Reason) != "MISSING" {
+		t.Errorf("expected an unknown task to be missing, got %v", described)
+	}
+}
+
+func TestGetLogEvents(t *testing.T) {
+	b := New()
+	b.Logs.Fail("GetLogEvents", awserr.New("ThrottlingException", "Rate exceeded", nil))
+
+	input := &cloudwatchlogs.GetLogEventsInput{LogGroupName: aws.String("/app"), LogStreamName: aws.String("run/app/1")}
+	if _, err := b.Logs.GetLogEventsWithContext(ctx, input); err == nil {
+		t.Errorf("expected the injected fault")
+	}
+	if _, err := b.Logs.GetLogEventsWithContext(ctx, input); err.(awserr.Error).Code() != cloudwatchlogs.ErrCodeResourceNotFoundException {
+		t.Errorf("expected a missing stream, got %v", err)
+	}
+
+	b.Logs.Append("/app", "run/app/1", "first", "second")
+	output, err := b.Logs.GetLogEventsWithContext(ctx, input)
+	if err != nil || len(output.Events) != 2 {
+		t.Fatalf("expected both events, got %v %v", output, err)
+	}
+
+	b.Logs.Append("/app", "run/app/1", "third")
+	input.NextToken = output.NextForwardToken
+	output, _ = b.Logs.GetLogEventsWithContext(ctx, input)
+	if len(output.Events) != 1 || aws.StringValue(output.Events[0].Message) != "third" {
+		t.Errorf("expected only the new event, got %v", output.Events)
+	}
+}
+
+func TestDescribeSubnets(t *testing.T) {
+	f := NewEC2()
+	f.AddSubnet("subnet-1", "vpc-1", "us-east-1a", "Name=private-a")
+	f.AddSubnet("subnet-2", "vpc-1", "us-east-1b", "Name=public-b")
+
+	output, err := f.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{
+		Filters: []*ec2.Filter{{Name: aws.String("tag:Name"), Values: aws.StringSlice([]string{"private-*"})}},
+	})
+	if err != nil || len(output.Subnets) != 1 || aws.StringValue(output.Subnets[0].SubnetId) != "subnet-1" {
+		t.Errorf("expected the private subnet, got %v %v", output, err)
+	}
+}
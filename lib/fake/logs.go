@@ -0,0 +1,251 @@
+package fake
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+)
+
+// Logs is an in-memory CloudWatch Logs. Calls it doesn't implement panic.
+type Logs struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+
+	Clock *Clock
+
+	mu     sync.Mutex
+	faults faults
+	groups map[string]*logGroup
+	nextID int
+}
+
+type logGroup struct {
+	created time.Time
+	streams map[string][]*cloudwatchlogs.OutputLogEvent
+	ids     map[*cloudwatchlogs.OutputLogEvent]string
+}
+
+// NewLogs returns CloudWatch Logs without any log group
+func NewLogs(clock *Clock) *Logs {
+	return &Logs{Clock: clock, groups: map[string]*logGroup{}}
+}
+
+// Fail makes the next call of operation, eg GetLogEvents, return err
+func (l *Logs) Fail(operation string, err error) {
+	l.faults.add(operation, err)
+}
+
+// Append writes messages to a log stream at the current time, creating the group and stream
+// when needed
+func (l *Logs) Append(group, stream string, messages ...string) {
+	l.AppendAt(l.Clock.Now(), group, stream, messages...)
+}
+
+// AppendAt writes messages to a log stream a millisecond apart starting at t
+func (l *Logs) AppendAt(t time.Time, group, stream string, messages ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	g := l.group(group, t)
+	for i, message := range messages {
+		event := &cloudwatchlogs.OutputLogEvent{
+			Timestamp:     aws.Int64(toMillis(t) + int64(i)),
+			IngestionTime: aws.Int64(toMillis(t) + int64(i)),
+			Message:       aws.String(message),
+		}
+		l.nextID++
+		g.ids[event] = strconv.Itoa(l.nextID)
+		g.streams[stream] = append(g.streams[stream], event)
+	}
+}
+
+// Events returns the messages of a log stream
+func (l *Logs) Events(group, stream string) (messages []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if g, ok := l.groups[group]; ok {
+		for _, e := range g.streams[stream] {
+			messages = append(messages, aws.StringValue(e.Message))
+		}
+	}
+	return messages
+}
+
+func (l *Logs) group(name string, now time.Time) *logGroup {
+	g, ok := l.groups[name]
+	if !ok {
+		g = &logGroup{
+			created: now,
+			streams: map[string][]*cloudwatchlogs.OutputLogEvent{},
+			ids:     map[*cloudwatchlogs.OutputLogEvent]string{},
+		}
+		l.groups[name] = g
+	}
+	return g
+}
+
+// CreateLogGroupWithContext creates an empty log group
+func (l *Logs) CreateLogGroupWithContext(ctx aws.Context, input *cloudwatchlogs.CreateLogGroupInput, opts ...request.Option) (*cloudwatchlogs.CreateLogGroupOutput, error) {
+	if err := l.faults.next("CreateLogGroup"); err != nil {
+		return nil, err
+	}
+	now := l.Clock.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	name := aws.StringValue(input.LogGroupName)
+	if _, ok := l.groups[name]; ok {
+		return nil, awserr.New(cloudwatchlogs.ErrCodeResourceAlreadyExistsException, "The specified log group already exists", nil)
+	}
+	l.group(name, now)
+	return &cloudwatchlogs.CreateLogGroupOutput{}, nil
+}
+
+// DescribeLogGroupsWithContext lists the log groups matching a prefix in a single page
+func (l *Logs) DescribeLogGroupsWithContext(ctx aws.Context, input *cloudwatchlogs.DescribeLogGroupsInput, opts ...request.Option) (*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	if err := l.faults.next("DescribeLogGroups"); err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var names []string
+	for name := range l.groups {
+		if strings.HasPrefix(name, aws.StringValue(input.LogGroupNamePrefix)) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	output := &cloudwatchlogs.DescribeLogGroupsOutput{}
+	for _, name := range names {
+		output.LogGroups = append(output.LogGroups, &cloudwatchlogs.LogGroup{
+			LogGroupName: aws.String(name),
+			CreationTime: aws.Int64(toMillis(l.groups[name].created)),
+		})
+	}
+	return output, nil
+}
+
+// GetLogEventsWithContext reads a stream forwards. Tokens are offsets into the stream, so the
+// same token is returned until new events arrive.
+func (l *Logs) GetLogEventsWithContext(ctx aws.Context, input *cloudwatchlogs.GetLogEventsInput, opts ...request.Option) (*cloudwatchlogs.GetLogEventsOutput, error) {
+	if err := l.faults.next("GetLogEvents"); err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	g, ok := l.groups[aws.StringValue(input.LogGroupName)]
+	if !ok {
+		return nil, awserr.New(cloudwatchlogs.ErrCodeResourceNotFoundException, "The specified log group does not exist.", nil)
+	}
+	events, ok := g.streams[aws.StringValue(input.LogStreamName)]
+	if !ok {
+		return nil, awserr.New(cloudwatchlogs.ErrCodeResourceNotFoundException, "The specified log stream does not exist.", nil)
+	}
+
+	start := 0
+	if input.NextToken != nil {
+		var err error
+		if start, err = strconv.Atoi(strings.TrimPrefix(aws.StringValue(input.NextToken), "f/")); err != nil || start > len(events) {
+			return nil, awserr.New(cloudwatchlogs.ErrCodeInvalidParameterException, "The specified nextToken is invalid.", nil)
+		}
+	}
+
+	end := len(events)
+	if input.Limit != nil && start+int(*input.Limit) < end {
+		end = start + int(*input.Limit)
+	}
+
+	output := &cloudwatchlogs.GetLogEventsOutput{
+		NextForwardToken:  aws.String(fmt.Sprintf("f/%d", end)),
+		NextBackwardToken: aws.String(fmt.Sprintf("b/%d", start)),
+	}
+	for _, e := range events[start:end] {
+		var event cloudwatchlogs.OutputLogEvent
+		clone(&event, e)
+		output.Events = append(output.Events, &event)
+	}
+	return output, nil
+}
+
+// FilterLogEventsPagesWithContext returns the matching events of a group in a single page.
+// A filter pattern matches events containing each of its terms.
+func (l *Logs) FilterLogEventsPagesWithContext(ctx aws.Context, input *cloudwatchlogs.FilterLogEventsInput, fn func(*cloudwatchlogs.FilterLogEventsOutput, bool) bool, opts ...request.Option) error {
+	if err := l.faults.next("FilterLogEvents"); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	g, ok := l.groups[aws.StringValue(input.LogGroupName)]
+	if !ok {
+		l.mu.Unlock()
+		return awserr.New(cloudwatchlogs.ErrCodeResourceNotFoundException, "The specified log group does not exist.", nil)
+	}
+
+	output := &cloudwatchlogs.FilterLogEventsOutput{}
+	for name, events := range g.streams {
+		if !matchesStream(name, input) {
+			continue
+		}
+		for _, e := range events {
+			ts := aws.Int64Value(e.Timestamp)
+			if input.StartTime != nil && ts < *input.StartTime || input.EndTime != nil && ts > *input.EndTime {
+				continue
+			}
+			if !matchesPattern(aws.StringValue(e.Message), aws.StringValue(input.FilterPattern)) {
+				continue
+			}
+			output.Events = append(output.Events, &cloudwatchlogs.FilteredLogEvent{
+				EventId:       aws.String(g.ids[e]),
+				IngestionTime: e.IngestionTime,
+				LogStreamName: aws.String(name),
+				Message:       e.Message,
+				Timestamp:     e.Timestamp,
+			})
+		}
+	}
+	l.mu.Unlock()
+
+	sort.SliceStable(output.Events, func(i, j int) bool {
+		return *output.Events[i].Timestamp < *output.Events[j].Timestamp
+	})
+	fn(output, true)
+	return nil
+}
+
+func matchesStream(name string, input *cloudwatchlogs.FilterLogEventsInput) bool {
+	if len(input.LogStreamNames) > 0 {
+		for _, n := range input.LogStreamNames {
+			if aws.StringValue(n) == name {
+				return true
+			}
+		}
+		return false
+	}
+	return strings.HasPrefix(name, aws.StringValue(input.LogStreamNamePrefix))
+}
+
+func matchesPattern(message, pattern string) bool {
+	for _, term := range strings.Fields(pattern) {
+		if !strings.Contains(message, strings.Trim(term, `"`)) {
+			return false
+		}
+	}
+	return true
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,209 @@
+package ecs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+var ecrImagePattern = regexp.MustCompile(`^(\d{12})\.dkr\.ecr(?:-fips)?\.([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?/([^:@]+)(?::([^@]+))?(?:@(.+))?$`)
+
+var manifestMediaTypes = aws.StringSlice([]string{
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+})
+
+// ecrImage locates an image in an ECR repository
+type ecrImage struct {
+	registryID string
+	region     string
+	repository string
+	imageID    *ecr.ImageIdentifier
+}
+
+type imageManifest struct {
+	Config struct {
+		Digest string `json:"digest"`
+	} `json:"config"`
+	Manifests []struct {
+		Digest   string `json:"digest"`
+		Platform struct {
+			Architecture string `json:"architecture"`
+			OS           string `json:"os"`
+		} `json:"platform"`
+	} `json:"manifests"`
+}
+
+type imageConfig struct {
+	Config struct {
+		ExposedPorts map[string]struct{} `json:"ExposedPorts"`
+	} `json:"config"`
+}
+
+func parseECRImage(image string) (*ecrImage, error) {
+	m := ecrImagePattern.FindStringSubmatch(image)
+	if m == nil {
+		return nil, fmt.Errorf("%s is not an ECR image", image)
+	}
+
+	imageID := &ecr.ImageIdentifier{}
+	switch {
+	case m[5] != "":
+		imageID.ImageDigest = aws.String(m[5])
+	case m[4] != "":
+		imageID.ImageTag = aws.String(m[4])
+	default:
+		imageID.ImageTag = aws.String("latest")
+	}
+
+	return &ecrImage{registryID: m[1], region: m[2], repository: m[3], imageID: imageID}, nil
+}
+
+// exposedPorts reads the ports EXPOSEd by an ECR image from its config. Multi-platform images
+// are resolved for linux and the given architecture, eg amd64 or arm64.
+func (c *Client) exposedPorts(ctx context.Context, image, architecture string) ([]*ecs.PortMapping, error) {
+	ref, err := parseECRImage(image)
+	if err != nil {
+		return nil, fmt.Errorf("publishing all ports reads the image config from ECR: %s", err)
+	}
+
+	client := c.ECR
+	if ref.region != c.Region && c.regionalECR != nil {
+		client = c.regionalECR(ref.region)
+	}
+	if client == nil {
+		return nil, fmt.Errorf("publishing all ports requires an ECR client")
+	}
+
+	manifest, err := getImageManifest(ctx, client, ref, ref.imageID)
+	if err != nil {
+		return nil, err
+	}
+
+	// pick the image for this platform out of an index
+	if len(manifest.Manifests) > 0 {
+		var digest string
+		for _, m := range manifest.Manifests {
+			if m.Platform.OS == "linux" && m.Platform.Architecture == architecture {
+				digest = m.Digest
+				break
+			}
+		}
+		if digest == "" {
+			return nil, fmt.Errorf("%s has no linux/%s image", image, architecture)
+		}
+		if manifest, err = getImageManifest(ctx, client, ref, &ecr.ImageIdentifier{ImageDigest: aws.String(digest)}); err != nil {
+			return nil, err
+		}
+	}
+
+	download, err := client.GetDownloadUrlForLayerWithContext(ctx, &ecr.GetDownloadUrlForLayerInput{
+		RegistryId:     aws.String(ref.registryID),
+		RepositoryName: aws.String(ref.repository),
+		LayerDigest:    aws.String(manifest.Config.Digest),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, aws.StringValue(download.DownloadUrl), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to download the config of %s: %s", image, res.Status)
+	}
+
+	var config imageConfig
+	if err := json.NewDecoder(res.Body).Decode(&config); err != nil {
+		return nil, fmt.Errorf("unable to read the config of %s: %s", image, err)
+	}
+	return c.exposedPortMappings(config.Config.ExposedPorts)
+}
+
+func getImageManifest(ctx context.Context, client ecriface.ECRAPI, ref *ecrImage, imageID *ecr.ImageIdentifier) (*imageManifest, error) {
+	res, err := client.BatchGetImageWithContext(ctx, &ecr.BatchGetImageInput{
+		RegistryId:         aws.String(ref.registryID),
+		RepositoryName:     aws.String(ref.repository),
+		ImageIds:           []*ecr.ImageIdentifier{imageID},
+		AcceptedMediaTypes: manifestMediaTypes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Images) == 0 {
+		if len(res.Failures) > 0 {
+			return nil, fmt.Errorf("%s: %s", ref.repository, aws.StringValue(res.Failures[0].FailureReason))
+		}
+		return nil, fmt.Errorf("image not found in %s", ref.repository)
+	}
+
+	var manifest imageManifest
+	if err := json.Unmarshal([]byte(aws.StringValue(res.Images[0].ImageManifest)), &manifest); err != nil {
+		return nil, fmt.Errorf("unable to read the manifest of %s: %s", ref.repository, err)
+	}
+	return &manifest, nil
+}
+
+// exposedPortMappings turns docker's ExposedPorts, eg {"80/tcp": {}}, into port mappings
+func (c *Client) exposedPortMappings(exposed map[string]struct{}) ([]*ecs.PortMapping, error) {
+	var ports []string
+	for port := range exposed {
+		ports = append(ports, port)
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		a, _ := strconv.Atoi(strings.SplitN(ports[i], "/", 2)[0])
+		b, _ := strconv.Atoi(strings.SplitN(ports[j], "/", 2)[0])
+		if a != b {
+			return a < b
+		}
+		return ports[i] < ports[j]
+	})
+
+	var mappings []*ecs.PortMapping
+	for _, port := range ports {
+		pair := strings.SplitN(port, "/", 2)
+		containerPort, err := parsePort(pair[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid exposed port %s: %s", port, err)
+		}
+		protocol := ecs.TransportProtocolTcp
+		if len(pair) == 2 {
+			protocol = strings.ToLower(pair[1])
+		}
+		if !isTransportProtocol(protocol) {
+			c.logWarning(fmt.Sprintf("ECS doesn't support publishing %s, skipping it", port))
+			continue
+		}
+		mappings = append(mappings, &ecs.PortMapping{
+			ContainerPort: aws.Int64(containerPort),
+			Protocol:      aws.String(protocol),
+		})
+	}
+	return mappings, nil
+}
+
+// imageArchitecture maps a runtime platform onto the architecture names used in image indexes
+func imageArchitecture(platform *ecs.RuntimePlatform) string {
+	if platform != nil && aws.StringValue(platform.CpuArchitecture) == ecs.CPUArchitectureArm64 {
+		return "arm64"
+	}
+	return "amd64"
+}
@@ -0,0 +1,100 @@
+package ecs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// describeService returns a single service of a cluster
+func (c *Client) describeService(ctx context.Context, cluster, name string) (*ecs.Service, error) {
+	output, err := c.ECS.DescribeServicesWithContext(ctx, &ecs.DescribeServicesInput{
+		Cluster:  aws.String(cluster),
+		Services: aws.StringSlice([]string{name}),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, service := range output.Services {
+		if aws.StringValue(service.Status) != "INACTIVE" {
+			return service, nil
+		}
+	}
+	return nil, fmt.Errorf("unable to find service %s in cluster %s", name, cluster)
+}
+
+// inheritServiceConfiguration copies the launch and network configuration of a service into
+// input, so a one-off task runs where the service's tasks do
+func inheritServiceConfiguration(input *ecs.RunTaskInput, service *ecs.Service) {
+	if len(service.CapacityProviderStrategy) > 0 {
+		input.CapacityProviderStrategy = service.CapacityProviderStrategy
+		input.LaunchType = nil
+	} else {
+		input.LaunchType = service.LaunchType
+	}
+
+	input.PlatformVersion = service.PlatformVersion
+	input.PlacementConstraints = service.PlacementConstraints
+
+	if service.NetworkConfiguration != nil && service.NetworkConfiguration.AwsvpcConfiguration != nil {
+		vpc := service.NetworkConfiguration.AwsvpcConfiguration
+		input.NetworkConfiguration = &ecs.NetworkConfiguration{
+			AwsvpcConfiguration: &ecs.AwsVpcConfiguration{
+				AssignPublicIp: vpc.AssignPublicIp,
+				SecurityGroups: vpc.SecurityGroups,
+				Subnets:        vpc.Subnets,
+			},
+		}
+	}
+}
+
+// applyNetworkFlags applies explicitly passed network settings on top of inherited ones
+func (c *Client) applyNetworkFlags(ctx context.Context, t *Task, input *ecs.RunTaskInput) error {
+	if t.PlatformVersion != "" {
+		input.PlatformVersion = aws.String(t.PlatformVersion)
+	}
+
+	if input.NetworkConfiguration == nil {
+		if len(t.SubnetFilters) == 0 && len(t.SecurityGroups) == 0 {
+			return nil
+		}
+		input.NetworkConfiguration = &ecs.NetworkConfiguration{
+			AwsvpcConfiguration: &ecs.AwsVpcConfiguration{
+				AssignPublicIp: aws.String("DISABLED"),
+			},
+		}
+	}
+	vpc := input.NetworkConfiguration.AwsvpcConfiguration
+
+	if t.LikeService == "" || t.PublicSet {
+		if t.Public {
+			vpc.AssignPublicIp = aws.String("ENABLED")
+		} else {
+			vpc.AssignPublicIp = aws.String("DISABLED")
+		}
+	}
+
+	if len(t.SubnetFilters) > 0 {
+		subnets, err := c.getSubnetsByFilter(ctx, t.SubnetFilters)
+		if err != nil {
+			return err
+		}
+		vpc.Subnets = subnets
+	}
+
+	if len(t.SecurityGroups) > 0 {
+		vpc.SecurityGroups = nil
+		for _, groupName := range t.SecurityGroups {
+			id, err := c.getSecurityGroupByName(ctx, groupName)
+			if err != nil {
+				return err
+			}
+			vpc.SecurityGroups = append(vpc.SecurityGroups, id)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package ecs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+func TestInheritServiceConfiguration(t *testing.T) {
+	service := &ecs.Service{
+		CapacityProviderStrategy: []*ecs.CapacityProviderStrategyItem{
+			{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: aws.Int64(1)},
+		},
+		PlatformVersion: aws.String("1.4.0"),
+		PlacementConstraints: []*ecs.PlacementConstraint{
+			{Type: aws.String("distinctInstance")},
+		},
+		NetworkConfiguration: &ecs.NetworkConfiguration{
+			AwsvpcConfiguration: &ecs.AwsVpcConfiguration{
+				AssignPublicIp: aws.String("ENABLED"),
+				SecurityGroups: aws.StringSlice([]string{"sg-1"}),
+				Subnets:        aws.StringSlice([]string{"subnet-1", "subnet-2"}),
+			},
+		},
+	}
+
+	input := &ecs.RunTaskInput{LaunchType: aws.String("FARGATE")}
+	inheritServiceConfiguration(input, service)
+
+	if input.LaunchType != nil || len(input.CapacityProviderStrategy) != 1 {
+		t.Errorf("expected the capacity provider strategy instead of a launch type, got %v", input)
+	}
+	if aws.StringValue(input.PlatformVersion) != "1.4.0" || len(input.PlacementConstraints) != 1 {
+		t.Errorf("unexpected placement %v", input)
+	}
+	if len(input.NetworkConfiguration.AwsvpcConfiguration.Subnets) != 2 {
+		t.Errorf("expected the service's subnets, got %v", input.NetworkConfiguration)
+	}
+
+	// flags that weren't passed keep the inherited values
+	task := &Task{LikeService: "api"}
+	if err := (&Client{}).applyNetworkFlags(context.Background(), task, input); err != nil {
+		t.Fatal(err)
+	}
+	if aws.StringValue(input.NetworkConfiguration.AwsvpcConfiguration.AssignPublicIp) != "ENABLED" {
+		t.Errorf("expected the inherited public IP setting, got %v", input.NetworkConfiguration)
+	}
+
+	task = &Task{LikeService: "api", PublicSet: true, PlatformVersion: "LATEST"}
+	if err := (&Client{}).applyNetworkFlags(context.Background(), task, input); err != nil {
+		t.Fatal(err)
+	}
+	if aws.StringValue(input.NetworkConfiguration.AwsvpcConfiguration.AssignPublicIp) != "DISABLED" || aws.StringValue(input.PlatformVersion) != "LATEST" {
+		t.Errorf("expected explicit flags to override inherited values, got %v", input)
+	}
+
+	ec2 := &ecs.RunTaskInput{}
+	inheritServiceConfiguration(ec2, &ecs.Service{LaunchType: aws.String("EC2")})
+	if aws.StringValue(ec2.LaunchType) != "EC2" || ec2.NetworkConfiguration != nil {
+		t.Errorf("expected a bridge mode EC2 service to be copied as is, got %v", ec2)
+	}
+}
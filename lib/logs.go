@@ -0,0 +1,369 @@
+package ecs
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// LogsInput selects the log streams and events shown by Logs
+type LogsInput struct {
+	Cluster string
+
+	// Exactly one of Task, Service or Family selects the task definition
+	Task    string
+	Service string
+	Family  string
+
+	// Only show logs of this container
+	Container     string
+	FilterPattern string
+	Since         time.Time
+	Until         time.Time
+	// Only show the last n events found, 0 shows all
+	Tail   int
+	Follow bool
+}
+
+// logSource is a set of log streams written by a single container definition
+type logSource struct {
+	container    string
+	group        string
+	streamNames  []string
+	streamPrefix string
+}
+
+// Logs prints the CloudWatch Logs of an existing task, service or task definition family
+func (c *Client) Logs(ctx context.Context, input *LogsInput) error {
+	sources, err := c.resolveLogSources(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	if len(sources) == 0 {
+		return fmt.Errorf("no containers with an awslogs log configuration found")
+	}
+
+	var start, end int64
+	if !input.Since.IsZero() {
+		start = toMillis(input.Since)
+	}
+	if !input.Until.IsZero() {
+		end = toMillis(input.Until)
+	}
+
+	readAt := toMillis(time.Now())
+	var events []*cloudwatchlogs.FilteredLogEvent
+	if input.Tail > 0 {
+		events, err = c.tailLogEvents(ctx, sources, input, start, end)
+	} else {
+		events, err = c.filterLogEvents(ctx, sources, input, start, end)
+	}
+	if err != nil {
+		return err
+	}
+
+	labels := len(sources) > 1 || input.Task == ""
+	c.printFilteredEvents(events, labels)
+
+	if !input.Follow {
+		return nil
+	}
+
+	// remember what was printed at the most recent timestamp so it isn't printed again
+	seen := map[string]bool{}
+	if len(events) > 0 {
+		start = *events[len(events)-1].Timestamp
+		for _, e := range events {
+			if *e.Timestamp == start {
+				seen[*e.EventId] = true
+			}
+		}
+	} else if start < readAt {
+		// nothing to resume from, don't scan the whole history again on every poll
+		start = readAt
+	}
+
+	for {
+		if !input.Until.IsZero() && time.Now().After(input.Until) {
+			return nil
+		}
+
+		if err := c.sleep(ctx); err != nil {
+			return err
+		}
+
+		events, err := c.filterLogEvents(ctx, sources, input, start, end)
+		if err != nil {
+			return err
+		}
+
+		var unseen []*cloudwatchlogs.FilteredLogEvent
+		for _, e := range events {
+			if !seen[*e.EventId] {
+				unseen = append(unseen, e)
+			}
+		}
+		c.printFilteredEvents(unseen, labels)
+
+		if len(unseen) > 0 {
+			last := *unseen[len(unseen)-1].Timestamp
+			if last != start {
+				seen = map[string]bool{}
+				start = last
+			}
+			for _, e := range unseen {
+				if *e.Timestamp == start {
+					seen[*e.EventId] = true
+				}
+			}
+		}
+	}
+}
+
+// ParseTimeArg parses a docker style time argument: either a duration relative to now
+// (eg 10m, 1h30m) or an RFC3339 timestamp
+func ParseTimeArg(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unable to parse time %q, expected a duration (eg 1h) or a timestamp (eg 2006-01-02T15:04:05Z)", s)
+}
+
+// resolveLogSources finds the log streams for the selected task, service or family
+func (c *Client) resolveLogSources(ctx context.Context, input *LogsInput) ([]*logSource, error) {
+	var taskDefinition string
+	var taskIds []string
+
+	switch {
+	case input.Task != "":
+		output, err := c.ECS.DescribeTasksWithContext(ctx, &ecs.DescribeTasksInput{
+			Cluster: aws.String(input.Cluster),
+			Tasks:   aws.StringSlice([]string{input.Task}),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(output.Tasks) == 0 {
+			return nil, fmt.Errorf("unable to find task %s in cluster %s", input.Task, input.Cluster)
+		}
+		taskDefinition = *output.Tasks[0].TaskDefinitionArn
+		taskIds = append(taskIds, regexp.MustCompile("[^/]*$").FindString(*output.Tasks[0].TaskArn))
+
+	case input.Service != "":
+		output, err := c.ECS.DescribeServicesWithContext(ctx, &ecs.DescribeServicesInput{
+			Cluster:  aws.String(input.Cluster),
+			Services: aws.StringSlice([]string{input.Service}),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(output.Services) == 0 {
+			return nil, fmt.Errorf("unable to find service %s in cluster %s", input.Service, input.Cluster)
+		}
+		taskDefinition = *output.Services[0].TaskDefinition
+
+	case input.Family != "":
+		taskDefinition = input.Family
+
+	default:
+		return nil, fmt.Errorf("one of a task, service or family is required")
+	}
+
+	output, err := c.ECS.DescribeTaskDefinitionWithContext(ctx, &ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: aws.String(taskDefinition),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error describing task def: %s", err)
+	}
+
+	t := Task{TaskDefinition: *output.TaskDefinition}
+
+	var sources []*logSource
+	for _, def := range t.streamableContainers() {
+		if input.Container != "" && *def.Name != input.Container {
+			continue
+		}
+
+		options := def.LogConfiguration.Options
+		source := &logSource{
+			container: *def.Name,
+			group:     *options["awslogs-group"],
+		}
+
+		// streams are named prefix/container/task-id
+		if len(taskIds) > 0 {
+			for _, id := range taskIds {
+				source.streamNames = append(source.streamNames, *options["awslogs-stream-prefix"]+"/"+*def.Name+"/"+id)
+			}
+		} else {
+			source.streamPrefix = *options["awslogs-stream-prefix"] + "/" + *def.Name + "/"
+		}
+		sources = append(sources, source)
+	}
+
+	if input.Container != "" && len(sources) == 0 {
+		return nil, fmt.Errorf("container %s not found or does not use the awslogs driver", input.Container)
+	}
+
+	return sources, nil
+}
+
+// backfillLimit is how far before the creation of a log group events may be timestamped
+const backfillLimit = 14 * 24 * time.Hour
+
+// tailLogEvents reads the last input.Tail events between start and end (in ms, 0 for
+// unbounded) without reading the whole history: windows of a minute before end are read, each
+// twice as long as the previous one, until enough events are found or start is reached
+func (c *Client) tailLogEvents(ctx context.Context, sources []*logSource, input *LogsInput, start, end int64) ([]*cloudwatchlogs.FilteredLogEvent, error) {
+	if end == 0 {
+		end = toMillis(time.Now())
+	}
+
+	// without a start, nothing is older than the oldest log group allows
+	floor := start
+	if floor == 0 {
+		var err error
+		if floor, err = c.oldestLogEvent(ctx, sources); err != nil {
+			return nil, err
+		}
+	}
+
+	var events []*cloudwatchlogs.FilteredLogEvent
+	window := int64(time.Minute / time.Millisecond)
+	for upper := end; upper >= floor; window *= 2 {
+		lower := upper - window + 1
+		if lower < floor {
+			lower = floor
+		}
+
+		older, err := c.filterLogEvents(ctx, sources, input, lower, upper)
+		if err != nil {
+			return nil, err
+		}
+		events = append(older, events...)
+
+		if len(events) >= input.Tail || lower == floor {
+			break
+		}
+		upper = lower - 1
+	}
+
+	if len(events) > input.Tail {
+		events = events[len(events)-input.Tail:]
+	}
+	return events, nil
+}
+
+// oldestLogEvent returns the earliest timestamp (in ms) an event of the sources can have
+func (c *Client) oldestLogEvent(ctx context.Context, sources []*logSource) (int64, error) {
+	oldest := toMillis(time.Now())
+	for _, source := range sources {
+		output, err := c.CloudWatchLogs.DescribeLogGroupsWithContext(ctx, &cloudwatchlogs.DescribeLogGroupsInput{
+			LogGroupNamePrefix: aws.String(source.group),
+		})
+		if err != nil {
+			return 0, err
+		}
+		for _, group := range output.LogGroups {
+			if aws.StringValue(group.LogGroupName) == source.group && aws.Int64Value(group.CreationTime) < oldest {
+				oldest = aws.Int64Value(group.CreationTime)
+			}
+		}
+	}
+	return oldest - int64(backfillLimit/time.Millisecond), nil
+}
+
+// filterLogEvents reads all events of the sources between start and end (in ms, 0 for
+// unbounded) and merges them in timestamp order
+func (c *Client) filterLogEvents(ctx context.Context, sources []*logSource, input *LogsInput, start, end int64) ([]*cloudwatchlogs.FilteredLogEvent, error) {
+	var events []*cloudwatchlogs.FilteredLogEvent
+
+	for _, source := range sources {
+		filterInput := &cloudwatchlogs.FilterLogEventsInput{
+			LogGroupName: aws.String(source.group),
+		}
+
+		if len(source.streamNames) > 0 {
+			filterInput.LogStreamNames = aws.StringSlice(source.streamNames)
+		} else {
+			filterInput.LogStreamNamePrefix = aws.String(source.streamPrefix)
+		}
+
+		if start > 0 {
+			filterInput.StartTime = aws.Int64(start)
+		}
+
+		if end > 0 {
+			filterInput.EndTime = aws.Int64(end)
+		}
+
+		if input.FilterPattern != "" {
+			filterInput.FilterPattern = aws.String(input.FilterPattern)
+		}
+
+		err := c.CloudWatchLogs.FilterLogEventsPagesWithContext(ctx, filterInput,
+			func(page *cloudwatchlogs.FilterLogEventsOutput, lastPage bool) bool {
+				events = append(events, page.Events...)
+				return true
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return *events[i].Timestamp < *events[j].Timestamp
+	})
+
+	return events, nil
+}
+
+// printFilteredEvents emits events labelled with the container and task they came from
+func (c *Client) printFilteredEvents(events []*cloudwatchlogs.FilteredLogEvent, labels bool) {
+	for _, e := range events {
+		event := Event{
+			Type:      EventLogLine,
+			Time:      millisToTime(e.Timestamp),
+			LogStream: aws.StringValue(e.LogStreamName),
+			Message:   aws.StringValue(e.Message),
+		}
+
+		// prefix/container/task-id
+		s := strings.Split(*e.LogStreamName, "/")
+		if len(s) >= 3 {
+			event.ContainerName = s[len(s)-2]
+		}
+		if labels {
+			if len(s) >= 3 {
+				event.prefix = s[len(s)-2] + " " + shortTaskID(s[len(s)-1])
+			} else {
+				event.prefix = *e.LogStreamName
+			}
+		}
+
+		c.emit(event)
+	}
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
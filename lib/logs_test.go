@@ -0,0 +1,161 @@
+package ecs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/justmiles/ecs-cli/lib/fake"
+)
+
+func TestParseTimeArg(t *testing.T) {
+	now := time.Date(2020, 1, 2, 15, 0, 0, 0, time.UTC)
+
+	since, err := ParseTimeArg("1h30m", now)
+	if err != nil || !since.Equal(now.Add(-90*time.Minute)) {
+		t.Errorf("got: %v %v", since, err)
+	}
+
+	since, err = ParseTimeArg("2020-01-02T10:00:00Z", now)
+	if err != nil || !since.Equal(time.Date(2020, 1, 2, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("got: %v %v", since, err)
+	}
+
+	if since, err = ParseTimeArg("", now); err != nil || !since.IsZero() {
+		t.Errorf("expected an empty argument to be the zero time, got: %v %v", since, err)
+	}
+
+	if _, err = ParseTimeArg("yesterday", now); err == nil {
+		t.Errorf("expected an invalid argument to fail")
+	}
+}
+
+func TestResolveLogSources(t *testing.T) {
+	c, b, _ := newFakeClient()
+	task := newFakeTask()
+	task.Detach = true
+	task.NoCleanup = true
+	if err := c.Run(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+	taskID := parseTaskId(aws.StringValue(b.ECS.Tasks()[0].TaskArn))
+
+	sources, err := c.resolveLogSources(context.Background(), &LogsInput{Cluster: "qa", Task: taskID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := "test/" + task.Family + "/" + taskID
+	if len(sources) != 1 || sources[0].group != "/qa/ecs/test" || len(sources[0].streamNames) != 1 || sources[0].streamNames[0] != stream {
+		t.Errorf("expected the stream of the task, got %+v", sources[0])
+	}
+
+	sources, err = c.resolveLogSources(context.Background(), &LogsInput{Cluster: "qa", Family: task.Family})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 1 || sources[0].streamNames != nil || sources[0].streamPrefix != "test/"+task.Family+"/" {
+		t.Errorf("expected the streams of the family, got %+v", sources[0])
+	}
+
+	if _, err = c.resolveLogSources(context.Background(), &LogsInput{Cluster: "qa", Family: task.Family, Container: "sidecar"}); err == nil {
+		t.Errorf("expected an unknown container to fail")
+	}
+	if _, err = c.resolveLogSources(context.Background(), &LogsInput{Cluster: "qa"}); err == nil {
+		t.Errorf("expected a task, service or family to be required")
+	}
+}
+
+// countingLogs counts the events FilterLogEvents returns and records the start of each call
+type countingLogs struct {
+	*fake.Logs
+	events int
+	starts []int64
+}
+
+func (l *countingLogs) FilterLogEventsPagesWithContext(ctx aws.Context, input *cloudwatchlogs.FilterLogEventsInput, fn func(*cloudwatchlogs.FilterLogEventsOutput, bool) bool, opts ...request.Option) error {
+	l.starts = append(l.starts, aws.Int64Value(input.StartTime))
+	return l.Logs.FilterLogEventsPagesWithContext(ctx, input, func(page *cloudwatchlogs.FilterLogEventsOutput, lastPage bool) bool {
+		l.events += len(page.Events)
+		return fn(page, lastPage)
+	}, opts...)
+}
+
+func TestLogsTail(t *testing.T) {
+	c, b, recorder := newFakeClient()
+	task := newFakeTask()
+	task.Detach = true
+	task.NoCleanup = true
+	if err := c.Run(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+	stream := "test/" + task.Family + "/" + parseTaskId(aws.StringValue(b.ECS.Tasks()[0].TaskArn))
+
+	var old, recent []string
+	for i := 0; i < 1000; i++ {
+		old = append(old, fmt.Sprintf("old %d", i))
+	}
+	for i := 0; i < 10; i++ {
+		recent = append(recent, fmt.Sprintf("recent %d", i))
+	}
+	b.Logs.AppendAt(time.Now().Add(-72*time.Hour), "/qa/ecs/test", stream, old...)
+	b.Logs.AppendAt(time.Now().Add(-time.Hour), "/qa/ecs/test", stream, recent...)
+
+	logs := &countingLogs{Logs: b.Logs}
+	c.CloudWatchLogs = logs
+	if err := c.Logs(context.Background(), &LogsInput{Cluster: "qa", Family: task.Family, Tail: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := eventsOfType(recorder.events, EventLogLine)
+	if len(lines) != 3 || lines[0].Message != "recent 7" || lines[2].Message != "recent 9" {
+		t.Errorf("expected the last 3 lines, got %v", lines)
+	}
+	if logs.events != 10 {
+		t.Errorf("expected only the recent events to be read, got %d", logs.events)
+	}
+
+	recorder.events = nil
+	logs.events = 0
+	if err := c.Logs(context.Background(), &LogsInput{Cluster: "qa", Family: task.Family, Tail: 20}); err != nil {
+		t.Fatal(err)
+	}
+	lines = eventsOfType(recorder.events, EventLogLine)
+	if len(lines) != 20 || lines[0].Message != "old 990" || lines[19].Message != "recent 9" {
+		t.Errorf("expected the last 20 lines across both batches, got %d", len(lines))
+	}
+}
+
+func TestLogsFollowWithoutEvents(t *testing.T) {
+	c, b, _ := newFakeClient()
+	task := newFakeTask()
+	task.Detach = true
+	task.NoCleanup = true
+	if err := c.Run(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+	stream := "test/" + task.Family + "/" + parseTaskId(aws.StringValue(b.ECS.Tasks()[0].TaskArn))
+	b.Logs.AppendAt(time.Now().Add(-72*time.Hour), "/qa/ecs/test", stream, "old")
+
+	logs := &countingLogs{Logs: b.Logs}
+	c.CloudWatchLogs = logs
+	started := toMillis(time.Now())
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	err := c.Logs(ctx, &LogsInput{Cluster: "qa", Family: task.Family, FilterPattern: "unmatched", Follow: true})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected following to stop with its context, got %v", err)
+	}
+
+	if len(logs.starts) < 2 || logs.starts[0] != 0 {
+		t.Fatalf("expected the history to be read once and then polled, got %v", logs.starts)
+	}
+	for _, start := range logs.starts[1:] {
+		if start < started {
+			t.Errorf("expected polls to start from now, got %d before %d", start, started)
+		}
+	}
+}
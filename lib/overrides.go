@@ -0,0 +1,117 @@
+package ecs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// buildTaskOverride builds the overrides RunTaskDef passes to RunTask so an existing task
+// definition can be reused as is. Returns nil when nothing is overridden.
+func (t *Task) buildTaskOverride(region string) (*ecs.TaskOverride, error) {
+	containerName := t.overrideContainerName()
+	if containerName == "" {
+		return nil, fmt.Errorf("task definition %s has no containers", t.Family)
+	}
+
+	found := false
+	for _, def := range t.TaskDefinition.ContainerDefinitions {
+		if aws.StringValue(def.Name) == containerName {
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("container %s not found in task definition %s", containerName, t.Family)
+	}
+
+	containerOverride := &ecs.ContainerOverride{
+		Name: aws.String(containerName),
+	}
+	overridden := false
+
+	if len(t.Command) > 0 {
+		containerOverride.Command = aws.StringSlice(t.Command)
+		overridden = true
+	}
+
+	environment, files, err := t.environment(region)
+	if err != nil {
+		return nil, err
+	}
+	if len(environment) > 0 {
+		containerOverride.Environment = environment
+		overridden = true
+	}
+	if len(files) > 0 {
+		containerOverride.EnvironmentFiles = files
+		overridden = true
+	}
+
+	if t.CPUReservation > 0 {
+		containerOverride.Cpu = aws.Int64(t.CPUReservation)
+		overridden = true
+	}
+
+	if t.Memory > 0 {
+		containerOverride.Memory = aws.Int64(t.Memory)
+		overridden = true
+	}
+
+	if t.MemoryReservation > 0 {
+		containerOverride.MemoryReservation = aws.Int64(t.MemoryReservation)
+		overridden = true
+	}
+
+	taskOverride := &ecs.TaskOverride{}
+	if overridden {
+		taskOverride.ContainerOverrides = []*ecs.ContainerOverride{containerOverride}
+	}
+
+	if t.TaskCPU != "" {
+		taskOverride.Cpu = aws.String(t.TaskCPU)
+		overridden = true
+	}
+
+	if t.TaskMemory != "" {
+		taskOverride.Memory = aws.String(t.TaskMemory)
+		overridden = true
+	}
+
+	if t.TaskRoleArn != "" {
+		taskOverride.TaskRoleArn = aws.String(t.TaskRoleArn)
+		overridden = true
+	}
+
+	if t.ExecutionRoleArn != "" {
+		taskOverride.ExecutionRoleArn = aws.String(t.ExecutionRoleArn)
+		overridden = true
+	}
+
+	if !overridden {
+		return nil, nil
+	}
+	return taskOverride, nil
+}
+
+// overrideContainerName is the container RunTaskDef overrides, the main container by default
+func (t *Task) overrideContainerName() string {
+	if t.Container != "" {
+		return t.Container
+	}
+	return t.mainContainerName()
+}
+
+// replaceImageTag swaps the tag of an image reference, keeping any registry port intact
+func replaceImageTag(image, tag string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+
+	name := image
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		name = image[:i]
+	}
+	return name + ":" + tag
+}
@@ -0,0 +1,83 @@
+package ecs
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+func TestBuildTaskOverride(t *testing.T) {
+	taskDefinition := ecs.TaskDefinition{
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{Name: aws.String("proxy"), Essential: aws.Bool(false)},
+			{Name: aws.String("app"), Essential: aws.Bool(true)},
+		},
+	}
+
+	task := &Task{TaskDefinition: taskDefinition}
+	if overrides, err := task.buildTaskOverride("us-east-1"); err != nil || overrides != nil {
+		t.Errorf("expected no overrides, got: %v %v", overrides, err)
+	}
+
+	task = &Task{
+		TaskDefinition:   taskDefinition,
+		Command:          []string{"bundle", "exec", "rake", "db:migrate"},
+		Environment:      []string{"RAILS_ENV=production"},
+		EnvironmentFiles: []string{"arn:aws:s3:::bucket/app.env"},
+		Memory:           1024,
+		TaskCPU:          "1 vCPU",
+		TaskRoleArn:      "arn:aws:iam::123456789012:role/migrations",
+	}
+	overrides, err := task.buildTaskOverride("us-east-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(overrides.ContainerOverrides) != 1 {
+		t.Fatalf("expected a single container override, got %v", overrides.ContainerOverrides)
+	}
+	container := overrides.ContainerOverrides[0]
+	if aws.StringValue(container.Name) != "app" {
+		t.Errorf("expected the essential container to be overridden, got %s", aws.StringValue(container.Name))
+	}
+	if len(container.Command) != 4 || aws.StringValue(container.Environment[0].Name) != "RAILS_ENV" || aws.Int64Value(container.Memory) != 1024 {
+		t.Errorf("unexpected container override %v", container)
+	}
+	if aws.StringValue(container.EnvironmentFiles[0].Type) != "s3" || container.Cpu != nil {
+		t.Errorf("unexpected container override %v", container)
+	}
+	if aws.StringValue(overrides.Cpu) != "1 vCPU" || overrides.Memory != nil || aws.StringValue(overrides.TaskRoleArn) == "" {
+		t.Errorf("unexpected task override %v", overrides)
+	}
+
+	// task level overrides alone don't touch any container
+	task = &Task{TaskDefinition: taskDefinition, TaskMemory: "4096"}
+	if overrides, err = task.buildTaskOverride("us-east-1"); err != nil || len(overrides.ContainerOverrides) != 0 {
+		t.Errorf("expected only a task override, got: %v %v", overrides, err)
+	}
+
+	task = &Task{TaskDefinition: taskDefinition, Container: "missing", Command: []string{"true"}}
+	if _, err = task.buildTaskOverride("us-east-1"); err == nil {
+		t.Errorf("expected an unknown container to fail")
+	}
+
+	task = &Task{TaskDefinition: taskDefinition, EnvironmentFiles: []string{"does-not-exist.env"}}
+	if _, err = task.buildTaskOverride("us-east-1"); err == nil {
+		t.Errorf("expected a missing environment file to fail")
+	}
+}
+
+func TestReplaceImageTag(t *testing.T) {
+	for image, expected := range map[string]string{
+		"nginx":                            "nginx:v2",
+		"nginx:1.25":                       "nginx:v2",
+		"registry.example.com:5000/app":    "registry.example.com:5000/app:v2",
+		"registry.example.com:5000/app:v1": "registry.example.com:5000/app:v2",
+		"123456789012.dkr.ecr.us-east-1.amazonaws.com/app@sha256:abcdef": "123456789012.dkr.ecr.us-east-1.amazonaws.com/app:v2",
+	} {
+		if got := replaceImageTag(image, "v2"); got != expected {
+			t.Errorf("%s: expected %s, got %s", image, expected, got)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package ecs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/justmiles/ecs-cli/lib/session"
+	"github.com/xtaci/smux"
+)
+
+// PortForwardInput selects the container and ports to forward
+type PortForwardInput struct {
+	Cluster   string
+	Task      string
+	Container string
+	// Optional host reachable from the task, eg an RDS endpoint. Defaults to the container itself.
+	Host         string
+	LocalAddress string
+	LocalPort    int
+	RemotePort   int
+}
+
+// ParsePortMapping parses a LOCAL:REMOTE port pair. A single port is used for both.
+func ParsePortMapping(s string) (local int, remote int, err error) {
+	ports := strings.Split(s, ":")
+	if len(ports) > 2 {
+		return 0, 0, fmt.Errorf("invalid port mapping %q, expected LOCAL:REMOTE", s)
+	}
+
+	for i, p := range ports {
+		port, err := strconv.Atoi(p)
+		if err != nil || port < 0 || port > 65535 || (i == len(ports)-1 && port == 0) {
+			return 0, 0, fmt.Errorf("invalid port %q in %q", p, s)
+		}
+		if i == 0 {
+			local = port
+		}
+		remote = port
+	}
+	return local, remote, nil
+}
+
+// PortForward listens on a local port and forwards every connection to a port of the container,
+// or of a host reachable from the task, through an SSM session. It returns when ctx is done.
+func (c *Client) PortForward(ctx context.Context, input *PortForwardInput) error {
+	if c.SSM == nil {
+		return fmt.Errorf("port forwarding requires an SSM client")
+	}
+
+	target, err := c.sessionTarget(ctx, input.Cluster, input.Task, input.Container)
+	if err != nil {
+		return err
+	}
+
+	startSessionInput := &ssm.StartSessionInput{
+		Target:       aws.String(target),
+		DocumentName: aws.String("AWS-StartPortForwardingSession"),
+		Parameters: map[string][]*string{
+			"portNumber":      aws.StringSlice([]string{strconv.Itoa(input.RemotePort)}),
+			"localPortNumber": aws.StringSlice([]string{strconv.Itoa(input.LocalPort)}),
+		},
+	}
+
+	if input.Host != "" {
+		startSessionInput.DocumentName = aws.String("AWS-StartPortForwardingSessionToRemoteHost")
+		startSessionInput.Parameters["host"] = aws.StringSlice([]string{input.Host})
+	}
+
+	output, err := c.SSM.StartSessionWithContext(ctx, startSessionInput)
+	if err != nil {
+		return err
+	}
+
+	sess, err := session.Open(*output.StreamUrl, *output.TokenValue)
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	if err := sess.WaitForHandshake(); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(input.LocalAddress, strconv.Itoa(input.LocalPort)))
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	remote := input.Container
+	if input.Host != "" {
+		remote = input.Host
+	}
+	c.logInfo(fmt.Sprintf("Forwarding %s -> %s:%d", listener.Addr(), remote, input.RemotePort))
+
+	return forward(ctx, listener, sess)
+}
+
+// portSession is the end of an SSM session connections are forwarded through
+type portSession interface {
+	io.ReadWriteCloser
+	Multiplexed() bool
+	DisconnectPort() error
+	Terminate() error
+	Done() <-chan struct{}
+	Err() error
+}
+
+// forward accepts connections until ctx is done, which is not an error, or the session ends
+func forward(ctx context.Context, listener net.Listener, sess portSession) error {
+	// stop listening when cancelled or when the agent closes the session
+	go func() {
+		select {
+		case <-ctx.Done():
+			sess.Terminate()
+		case <-sess.Done():
+		}
+		listener.Close()
+	}()
+
+	var err error
+	if sess.Multiplexed() {
+		err = forwardMultiplexed(listener, sess)
+	} else {
+		err = forwardSerial(listener, sess)
+	}
+
+	// Terminate only asks the agent to close the session, the listener is closed before it has
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	select {
+	case <-sess.Done():
+		return sess.Err()
+	default:
+		return err
+	}
+}
+
+// forwardMultiplexed carries every connection as a stream of a single smux session, the way
+// newer agents expect
+func forwardMultiplexed(listener net.Listener, sess portSession) error {
+	mux, err := smux.Client(sess, nil)
+	if err != nil {
+		return err
+	}
+	defer mux.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		stream, err := mux.OpenStream()
+		if err != nil {
+			conn.Close()
+			return err
+		}
+
+		go pipe(conn, stream)
+	}
+}
+
+// forwardSerial handles one connection at a time for agents that don't multiplex
+func forwardSerial(listener net.Listener, sess portSession) error {
+	var mu sync.Mutex
+	var current net.Conn
+
+	// output for a connection that has already closed is dropped
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := sess.Read(buf)
+			if n > 0 {
+				mu.Lock()
+				if current != nil {
+					current.Write(buf[:n])
+				}
+				mu.Unlock()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		current = conn
+		mu.Unlock()
+
+		io.Copy(sess, conn)
+
+		mu.Lock()
+		current = nil
+		mu.Unlock()
+		conn.Close()
+
+		if err := sess.DisconnectPort(); err != nil {
+			return err
+		}
+	}
+}
+
+func pipe(a, b io.ReadWriteCloser) {
+	defer a.Close()
+	defer b.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(a, b)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(b, a)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// sessionTarget builds the SSM target of a container: ecs:<cluster>_<task-id>_<runtime-id>
+func (c *Client) sessionTarget(ctx context.Context, cluster, task, container string) (string, error) {
+	output, err := c.ECS.DescribeTasksWithContext(ctx, &ecs.DescribeTasksInput{
+		Cluster: aws.String(cluster),
+		Tasks:   aws.StringSlice([]string{task}),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(output.Tasks) == 0 {
+		return "", fmt.Errorf("unable to find task %s in cluster %s", task, cluster)
+	}
+
+	for _, def := range output.Tasks[0].Containers {
+		if aws.StringValue(def.Name) != container {
+			continue
+		}
+		if def.RuntimeId == nil {
+			return "", fmt.Errorf("container %s has no runtime ID yet, is it running?", container)
+		}
+
+		taskID := regexp.MustCompile("[^/]*$").FindString(*output.Tasks[0].TaskArn)
+		return fmt.Sprintf("ecs:%s_%s_%s", parseClusterName(*output.Tasks[0].ClusterArn), taskID, *def.RuntimeId), nil
+	}
+
+	return "", fmt.Errorf("container %s not found in task %s", container, task)
+}
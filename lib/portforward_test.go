@@ -0,0 +1,115 @@
+package ecs
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParsePortMapping(t *testing.T) {
+	local, remote, err := ParsePortMapping("8080:80")
+	if err != nil || local != 8080 || remote != 80 {
+		t.Errorf("got %d:%d %v", local, remote, err)
+	}
+
+	local, remote, err = ParsePortMapping("5432")
+	if err != nil || local != 5432 || remote != 5432 {
+		t.Errorf("got %d:%d %v", local, remote, err)
+	}
+
+	for _, invalid := range []string{"http", "1:2:3", "80:0", "70000"} {
+		if _, _, err := ParsePortMapping(invalid); err == nil {
+			t.Errorf("expected %q to be invalid", invalid)
+		}
+	}
+}
+
+// echoSession is a serial port session that echoes what is written to it. Like the agent,
+// Terminate only asks for the session to end.
+type echoSession struct {
+	data       chan []byte
+	done       chan struct{}
+	closeOnce  sync.Once
+	mu         sync.Mutex
+	terminated bool
+}
+
+func newEchoSession() *echoSession {
+	return &echoSession{data: make(chan []byte, 16), done: make(chan struct{})}
+}
+
+func (s *echoSession) Read(p []byte) (int, error) {
+	select {
+	case b := <-s.data:
+		return copy(p, b), nil
+	case <-s.done:
+		return 0, io.EOF
+	}
+}
+
+func (s *echoSession) Write(p []byte) (int, error) {
+	s.data <- append([]byte{}, p...)
+	return len(p), nil
+}
+
+func (s *echoSession) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+	return nil
+}
+
+func (s *echoSession) Terminate() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.terminated = true
+	return nil
+}
+
+func (s *echoSession) Multiplexed() bool     { return false }
+func (s *echoSession) DisconnectPort() error { return nil }
+func (s *echoSession) Done() <-chan struct{} { return s.done }
+func (s *echoSession) Err() error            { return nil }
+
+func TestForward(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := newEchoSession()
+	defer sess.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	forwarded := make(chan error, 1)
+	go func() { forwarded <- forward(ctx, listener, sess) }()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	conn.Write([]byte("ping"))
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(conn, b); err != nil || string(b) != "ping" {
+		t.Fatalf("expected the connection to be forwarded, got %q %v", b, err)
+	}
+	conn.Close()
+
+	// an interrupt ends forwarding without an error
+	cancel()
+	select {
+	case err := <-forwarded:
+		if err != nil {
+			t.Errorf("expected cancelling to return nil, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected forwarding to stop when cancelled")
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if !sess.terminated {
+		t.Errorf("expected the session to be terminated")
+	}
+}
@@ -0,0 +1,195 @@
+package ecs
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// buildPortMapping parses docker's -p grammar, [[IP:][HOST_PORT]:]CONTAINER_PORT[/PROTOCOL], where
+// either port may be a range such as 8000-8010. Service Connect metadata follows as comma
+// separated options, eg 8080:80,name=http,appProtocol=http2. The older HOST:CONTAINER:PROTOCOL
+// form is still accepted.
+func (c *Client) buildPortMapping(publish []string) (k []*ecs.PortMapping, err error) {
+	if len(publish) < 1 {
+		return []*ecs.PortMapping{}, nil
+	}
+	for _, p := range publish {
+		portMap, err := c.parsePublish(p)
+		if err != nil {
+			return nil, err
+		}
+		k = append(k, portMap)
+	}
+	return k, nil
+}
+
+func (c *Client) parsePublish(s string) (*ecs.PortMapping, error) {
+	var spec string
+	portMap := &ecs.PortMapping{}
+
+	for _, field := range strings.Split(s, ",") {
+		if !strings.Contains(field, "=") {
+			if spec != "" {
+				return nil, fmt.Errorf("invalid port %q, expected a single [[IP:][HOST_PORT]:]CONTAINER_PORT[/PROTOCOL]", s)
+			}
+			spec = field
+			continue
+		}
+
+		kv := strings.SplitN(field, "=", 2)
+		switch strings.ToLower(kv[0]) {
+		case "name":
+			portMap.Name = aws.String(kv[1])
+		case "appprotocol", "app-protocol":
+			protocol := strings.ToLower(kv[1])
+			if protocol != ecs.ApplicationProtocolHttp && protocol != ecs.ApplicationProtocolHttp2 && protocol != ecs.ApplicationProtocolGrpc {
+				return nil, fmt.Errorf("invalid port %q, appProtocol must be http, http2 or grpc", s)
+			}
+			portMap.AppProtocol = aws.String(protocol)
+		default:
+			return nil, fmt.Errorf("invalid port %q, unknown option %s", s, kv[0])
+		}
+	}
+	if spec == "" {
+		return nil, fmt.Errorf("invalid port %q, a container port is required", s)
+	}
+
+	protocol := ecs.TransportProtocolTcp
+	if i := strings.LastIndex(spec, "/"); i >= 0 {
+		protocol = strings.ToLower(spec[i+1:])
+		spec = spec[:i]
+	}
+
+	// an IPv6 address is bracketed, eg [::1]:80:80
+	var ip string
+	if strings.HasPrefix(spec, "[") {
+		end := strings.Index(spec, "]:")
+		if end < 0 {
+			return nil, fmt.Errorf("invalid port %q, unterminated IPv6 address", s)
+		}
+		ip, spec = spec[1:end], spec[end+2:]
+	}
+
+	var host, container string
+	parts := strings.Split(spec, ":")
+	switch {
+	case len(parts) == 1:
+		host, container = parts[0], parts[0]
+	case len(parts) == 2:
+		host, container = parts[0], parts[1]
+	case len(parts) == 3 && ip == "" && isTransportProtocol(parts[2]):
+		host, container, protocol = parts[0], parts[1], strings.ToLower(parts[2])
+	case len(parts) == 3 && ip == "":
+		ip, host, container = parts[0], parts[1], parts[2]
+	default:
+		return nil, fmt.Errorf("invalid port %q, expected [[IP:][HOST_PORT]:]CONTAINER_PORT[/PROTOCOL]", s)
+	}
+
+	if !isTransportProtocol(protocol) {
+		return nil, fmt.Errorf("invalid port %q, protocol must be tcp or udp", s)
+	}
+	portMap.Protocol = aws.String(protocol)
+
+	if ip != "" {
+		if net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("invalid port %q, %s is not an IP address", s, ip)
+		}
+		c.logWarning(fmt.Sprintf("ECS publishes ports on every interface, ignoring %s in %s", ip, s))
+	}
+
+	containerStart, containerEnd, err := parsePortRange(container)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q: %s", s, err)
+	}
+
+	// an empty host port, eg 127.0.0.1::80, lets ECS pick one
+	var hostStart, hostEnd int64
+	if host != "" {
+		if hostStart, hostEnd, err = parsePortRange(host); err != nil {
+			return nil, fmt.Errorf("invalid port %q: %s", s, err)
+		}
+	}
+
+	if containerStart == containerEnd {
+		if hostStart != hostEnd {
+			return nil, fmt.Errorf("invalid port %q, a host port range needs a container port range", s)
+		}
+		portMap.ContainerPort = aws.Int64(containerStart)
+		if hostStart > 0 {
+			portMap.HostPort = aws.Int64(hostStart)
+		}
+		return portMap, nil
+	}
+
+	// ECS assigns the host ports of a container port range itself
+	if hostStart > 0 && (hostStart != containerStart || hostEnd != containerEnd) {
+		c.logWarning(fmt.Sprintf("ECS assigns host ports for container port ranges, ignoring %s in %s", host, s))
+	}
+	portMap.ContainerPortRange = aws.String(fmt.Sprintf("%d-%d", containerStart, containerEnd))
+	return portMap, nil
+}
+
+// parsePortRange parses a port, eg 80, or an inclusive range, eg 8000-8010
+func parsePortRange(s string) (start, end int64, err error) {
+	bounds := strings.SplitN(s, "-", 2)
+	if start, err = parsePort(bounds[0]); err != nil {
+		return 0, 0, err
+	}
+	end = start
+	if len(bounds) == 2 {
+		if end, err = parsePort(bounds[1]); err != nil {
+			return 0, 0, err
+		}
+		if end < start {
+			return 0, 0, fmt.Errorf("port range %s ends before it starts", s)
+		}
+	}
+	return start, end, nil
+}
+
+func parsePort(s string) (int64, error) {
+	port, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("%q is not a port between 1 and 65535", s)
+	}
+	return port, nil
+}
+
+func isTransportProtocol(s string) bool {
+	s = strings.ToLower(s)
+	return s == ecs.TransportProtocolTcp || s == ecs.TransportProtocolUdp
+}
+
+// warnAwsvpcPorts points out the host ports that differ from their container port, which
+// Validate rejects. Tasks in the awsvpc network mode have their own network interface, so a
+// different host port can't be honoured.
+func (c *Client) warnAwsvpcPorts(defs []*ecs.ContainerDefinition) {
+	for _, def := range defs {
+		for _, portMap := range def.PortMappings {
+			if portMap.HostPort == nil || aws.Int64Value(portMap.HostPort) == aws.Int64Value(portMap.ContainerPort) {
+				continue
+			}
+			c.logWarning(fmt.Sprintf("%s: can't publish port %d on %d, the awsvpc network mode requires the host port to match the container port. Publish %d instead",
+				aws.StringValue(def.Name), aws.Int64Value(portMap.ContainerPort), aws.Int64Value(portMap.HostPort), aws.Int64Value(portMap.ContainerPort)))
+		}
+	}
+}
+
+// mergePortMappings adds the exposed ports that aren't already published
+func mergePortMappings(published, exposed []*ecs.PortMapping) []*ecs.PortMapping {
+	seen := map[string]bool{}
+	for _, portMap := range published {
+		seen[fmt.Sprintf("%d/%s", aws.Int64Value(portMap.ContainerPort), aws.StringValue(portMap.Protocol))] = true
+	}
+	for _, portMap := range exposed {
+		if !seen[fmt.Sprintf("%d/%s", aws.Int64Value(portMap.ContainerPort), aws.StringValue(portMap.Protocol))] {
+			published = append(published, portMap)
+		}
+	}
+	return published
+}
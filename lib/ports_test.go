@@ -0,0 +1,81 @@
+package ecs
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+func TestBuildPortMapping(t *testing.T) {
+	for publish, expected := range map[string]ecs.PortMapping{
+		"80":                                  {ContainerPort: aws.Int64(80), HostPort: aws.Int64(80), Protocol: aws.String("tcp")},
+		"8080:80":                             {ContainerPort: aws.Int64(80), HostPort: aws.Int64(8080), Protocol: aws.String("tcp")},
+		"53:53:udp":                           {ContainerPort: aws.Int64(53), HostPort: aws.Int64(53), Protocol: aws.String("udp")},
+		"53/udp":                              {ContainerPort: aws.Int64(53), HostPort: aws.Int64(53), Protocol: aws.String("udp")},
+		"127.0.0.1:8080:80":                   {ContainerPort: aws.Int64(80), HostPort: aws.Int64(8080), Protocol: aws.String("tcp")},
+		"127.0.0.1::80":                       {ContainerPort: aws.Int64(80), Protocol: aws.String("tcp")},
+		"[::1]:8080:80/udp":                   {ContainerPort: aws.Int64(80), HostPort: aws.Int64(8080), Protocol: aws.String("udp")},
+		"8000-8010:8000-8010":                 {ContainerPortRange: aws.String("8000-8010"), Protocol: aws.String("tcp")},
+		"8000-8010/udp":                       {ContainerPortRange: aws.String("8000-8010"), Protocol: aws.String("udp")},
+		"8080:80,name=http,appProtocol=http2": {ContainerPort: aws.Int64(80), HostPort: aws.Int64(8080), Protocol: aws.String("tcp"), Name: aws.String("http"), AppProtocol: aws.String("http2")},
+	} {
+		mappings, err := (&Client{}).buildPortMapping([]string{publish})
+		if err != nil {
+			t.Errorf("%s: %v", publish, err)
+			continue
+		}
+		if got := mappings[0]; got.String() != expected.String() {
+			t.Errorf("%s: expected %v, got %v", publish, expected, got)
+		}
+	}
+
+	for _, invalid := range []string{"", "http", "80/sctp", "70000", "8010-8000", "8000-8010:80", "localhost:8080:80", "80,appProtocol=ftp", "80,weight=1", "80,81"} {
+		if _, err := (&Client{}).buildPortMapping([]string{invalid}); err == nil {
+			t.Errorf("expected %q to fail", invalid)
+		}
+	}
+}
+
+func TestAwsvpcHostPorts(t *testing.T) {
+	c, b, _ := newFakeClient()
+	task := newFakeTask()
+	task.Fargate = true
+	task.Publish = []string{"8080:80"}
+
+	err := c.Run(context.Background(), task)
+	if err == nil || !strings.Contains(err.Error(), "portMappings[0].hostPort: must equal the container port 80") {
+		t.Fatalf("expected the host port to be rejected rather than rewritten, got %v", err)
+	}
+	if b.ECS.TaskDefinition(task.Family) != nil {
+		t.Errorf("expected no task definition to be registered")
+	}
+}
+
+func TestExposedPorts(t *testing.T) {
+	mappings, err := (&Client{}).exposedPortMappings(map[string]struct{}{"8080/tcp": {}, "53/udp": {}, "443": {}, "9000/sctp": {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mappings) != 3 || aws.Int64Value(mappings[0].ContainerPort) != 53 || aws.StringValue(mappings[1].Protocol) != "tcp" {
+		t.Errorf("unexpected port mappings %v", mappings)
+	}
+
+	merged := mergePortMappings([]*ecs.PortMapping{{ContainerPort: aws.Int64(8080), HostPort: aws.Int64(80), Protocol: aws.String("tcp")}}, mappings)
+	if len(merged) != 3 || aws.Int64Value(merged[0].HostPort) != 80 {
+		t.Errorf("expected explicitly published ports to win, got %v", merged)
+	}
+
+	image, err := parseECRImage("123456789012.dkr.ecr.eu-west-1.amazonaws.com/team/app:v1")
+	if err != nil || image.region != "eu-west-1" || image.repository != "team/app" || aws.StringValue(image.imageID.ImageTag) != "v1" {
+		t.Errorf("unexpected image %+v %v", image, err)
+	}
+	if image, _ = parseECRImage("123456789012.dkr.ecr.us-east-1.amazonaws.com/app@sha256:abc"); aws.StringValue(image.imageID.ImageDigest) != "sha256:abc" {
+		t.Errorf("expected a digest, got %v", image.imageID)
+	}
+	if _, err = parseECRImage("nginx:latest"); err == nil {
+		t.Errorf("expected a docker hub image to fail")
+	}
+}
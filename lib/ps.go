@@ -0,0 +1,341 @@
+package ecs
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// ListTasksInput filters the tasks returned by ListTasks
+type ListTasksInput struct {
+	// All clusters are listed when empty
+	Cluster   string
+	Service   string
+	Family    string
+	Status    string
+	StartedBy string
+}
+
+// TaskSummary describes a task for listings
+type TaskSummary struct {
+	TaskID           string     `json:"taskId" yaml:"taskId"`
+	Cluster          string     `json:"cluster" yaml:"cluster"`
+	TaskDefinition   string     `json:"taskDefinition" yaml:"taskDefinition"`
+	LastStatus       string     `json:"lastStatus" yaml:"lastStatus"`
+	DesiredStatus    string     `json:"desiredStatus" yaml:"desiredStatus"`
+	LaunchType       string     `json:"launchType,omitempty" yaml:"launchType,omitempty"`
+	CapacityProvider string     `json:"capacityProvider,omitempty" yaml:"capacityProvider,omitempty"`
+	StartedAt        *time.Time `json:"startedAt,omitempty" yaml:"startedAt,omitempty"`
+	StoppedAt        *time.Time `json:"stoppedAt,omitempty" yaml:"stoppedAt,omitempty"`
+	PrivateIPs       []string   `json:"privateIps,omitempty" yaml:"privateIps,omitempty"`
+	PublicIP         string     `json:"publicIp,omitempty" yaml:"publicIp,omitempty"`
+	AvailabilityZone string     `json:"availabilityZone,omitempty" yaml:"availabilityZone,omitempty"`
+	HealthStatus     string     `json:"healthStatus,omitempty" yaml:"healthStatus,omitempty"`
+	StartedBy        string     `json:"startedBy,omitempty" yaml:"startedBy,omitempty"`
+	Group            string     `json:"group,omitempty" yaml:"group,omitempty"`
+	StoppedReason    string     `json:"stoppedReason,omitempty" yaml:"stoppedReason,omitempty"`
+
+	// network interface of awsvpc tasks, used to look up the public address
+	networkInterface string
+}
+
+// Uptime is how long the task has been running, or ran for when stopped
+func (s *TaskSummary) Uptime(now time.Time) time.Duration {
+	if s.StartedAt == nil {
+		return 0
+	}
+	if s.StoppedAt != nil {
+		now = *s.StoppedAt
+	}
+	return now.Sub(*s.StartedAt).Truncate(time.Second)
+}
+
+// ClusterSummary describes a cluster for listings
+type ClusterSummary struct {
+	Name              string   `json:"name" yaml:"name"`
+	Status            string   `json:"status" yaml:"status"`
+	ActiveServices    int64    `json:"activeServices" yaml:"activeServices"`
+	RunningTasks      int64    `json:"runningTasks" yaml:"runningTasks"`
+	PendingTasks      int64    `json:"pendingTasks" yaml:"pendingTasks"`
+	CapacityProviders []string `json:"capacityProviders,omitempty" yaml:"capacityProviders,omitempty"`
+}
+
+// ServiceSummary describes a service for listings
+type ServiceSummary struct {
+	Name             string `json:"name" yaml:"name"`
+	Cluster          string `json:"cluster" yaml:"cluster"`
+	Status           string `json:"status" yaml:"status"`
+	DesiredCount     int64  `json:"desiredCount" yaml:"desiredCount"`
+	RunningCount     int64  `json:"runningCount" yaml:"runningCount"`
+	PendingCount     int64  `json:"pendingCount" yaml:"pendingCount"`
+	LaunchType       string `json:"launchType,omitempty" yaml:"launchType,omitempty"`
+	CapacityProvider string `json:"capacityProvider,omitempty" yaml:"capacityProvider,omitempty"`
+	TaskDefinition   string `json:"taskDefinition" yaml:"taskDefinition"`
+}
+
+// ListTasks lists tasks matching the input, across all clusters when none is given
+func (c *Client) ListTasks(ctx context.Context, input *ListTasksInput) ([]*TaskSummary, error) {
+	clusters := []string{input.Cluster}
+	if input.Cluster == "" {
+		var err error
+		if clusters, err = c.GetClusters(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	summaries := []*TaskSummary{}
+	for _, cluster := range clusters {
+		listInput := &ecs.ListTasksInput{
+			Cluster: aws.String(cluster),
+		}
+		// startedBy must be the only filter, the others are applied to the described tasks
+		if input.StartedBy != "" {
+			listInput.StartedBy = aws.String(input.StartedBy)
+		} else {
+			if input.Service != "" {
+				listInput.ServiceName = aws.String(input.Service)
+			}
+			if input.Family != "" {
+				listInput.Family = aws.String(input.Family)
+			}
+			if input.Status != "" {
+				listInput.DesiredStatus = aws.String(strings.ToUpper(input.Status))
+			}
+		}
+
+		var arns []*string
+		err := c.ECS.ListTasksPagesWithContext(ctx, listInput, func(page *ecs.ListTasksOutput, lastPage bool) bool {
+			arns = append(arns, page.TaskArns...)
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// DescribeTasks accepts at most 100 tasks
+		for i := 0; i < len(arns); i += 100 {
+			end := i + 100
+			if end > len(arns) {
+				end = len(arns)
+			}
+
+			output, err := c.ECS.DescribeTasksWithContext(ctx, &ecs.DescribeTasksInput{
+				Cluster: aws.String(cluster),
+				Tasks:   arns[i:end],
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			for _, task := range output.Tasks {
+				if input.StartedBy != "" && !input.matches(task) {
+					continue
+				}
+				summaries = append(summaries, summarizeTask(task))
+			}
+		}
+	}
+
+	if err := c.resolvePublicIPs(ctx, summaries); err != nil {
+		return nil, err
+	}
+
+	return summaries, nil
+}
+
+// matches applies the service, family and status filters to a described task
+func (input *ListTasksInput) matches(task *ecs.Task) bool {
+	if input.Service != "" && aws.StringValue(task.Group) != "service:"+input.Service {
+		return false
+	}
+	if input.Family != "" && strings.SplitN(shortTaskDefinition(aws.StringValue(task.TaskDefinitionArn)), ":", 2)[0] != input.Family {
+		return false
+	}
+	if input.Status != "" && aws.StringValue(task.DesiredStatus) != strings.ToUpper(input.Status) {
+		return false
+	}
+	return true
+}
+
+// ListClusters describes every cluster
+func (c *Client) ListClusters(ctx context.Context) ([]*ClusterSummary, error) {
+	names, err := c.GetClusters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := []*ClusterSummary{}
+	// DescribeClusters accepts at most 100 clusters
+	for i := 0; i < len(names); i += 100 {
+		end := i + 100
+		if end > len(names) {
+			end = len(names)
+		}
+
+		output, err := c.ECS.DescribeClustersWithContext(ctx, &ecs.DescribeClustersInput{
+			Clusters: aws.StringSlice(names[i:end]),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range output.Clusters {
+			summaries = append(summaries, &ClusterSummary{
+				Name:              aws.StringValue(c.ClusterName),
+				Status:            aws.StringValue(c.Status),
+				ActiveServices:    aws.Int64Value(c.ActiveServicesCount),
+				RunningTasks:      aws.Int64Value(c.RunningTasksCount),
+				PendingTasks:      aws.Int64Value(c.PendingTasksCount),
+				CapacityProviders: aws.StringValueSlice(c.CapacityProviders),
+			})
+		}
+	}
+	return summaries, nil
+}
+
+// ListServices describes every service of a cluster
+func (c *Client) ListServices(ctx context.Context, cluster string) ([]*ServiceSummary, error) {
+	names, err := c.GetServices(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := []*ServiceSummary{}
+	// DescribeServices accepts at most 10 services
+	for i := 0; i < len(names); i += 10 {
+		end := i + 10
+		if end > len(names) {
+			end = len(names)
+		}
+
+		output, err := c.ECS.DescribeServicesWithContext(ctx, &ecs.DescribeServicesInput{
+			Cluster:  aws.String(cluster),
+			Services: aws.StringSlice(names[i:end]),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, s := range output.Services {
+			summary := &ServiceSummary{
+				Name:           aws.StringValue(s.ServiceName),
+				Cluster:        cluster,
+				Status:         aws.StringValue(s.Status),
+				DesiredCount:   aws.Int64Value(s.DesiredCount),
+				RunningCount:   aws.Int64Value(s.RunningCount),
+				PendingCount:   aws.Int64Value(s.PendingCount),
+				LaunchType:     aws.StringValue(s.LaunchType),
+				TaskDefinition: shortTaskDefinition(aws.StringValue(s.TaskDefinition)),
+			}
+
+			var providers []string
+			for _, strategy := range s.CapacityProviderStrategy {
+				providers = append(providers, aws.StringValue(strategy.CapacityProvider))
+			}
+			summary.CapacityProvider = strings.Join(providers, ",")
+
+			summaries = append(summaries, summary)
+		}
+	}
+	return summaries, nil
+}
+
+func summarizeTask(task *ecs.Task) *TaskSummary {
+	summary := &TaskSummary{
+		TaskID:           parseTaskId(aws.StringValue(task.TaskArn)),
+		Cluster:          parseClusterName(aws.StringValue(task.ClusterArn)),
+		TaskDefinition:   shortTaskDefinition(aws.StringValue(task.TaskDefinitionArn)),
+		LastStatus:       aws.StringValue(task.LastStatus),
+		DesiredStatus:    aws.StringValue(task.DesiredStatus),
+		LaunchType:       aws.StringValue(task.LaunchType),
+		CapacityProvider: aws.StringValue(task.CapacityProviderName),
+		StartedAt:        task.StartedAt,
+		StoppedAt:        task.StoppedAt,
+		AvailabilityZone: aws.StringValue(task.AvailabilityZone),
+		HealthStatus:     aws.StringValue(task.HealthStatus),
+		StartedBy:        aws.StringValue(task.StartedBy),
+		Group:            aws.StringValue(task.Group),
+		StoppedReason:    aws.StringValue(task.StoppedReason),
+	}
+
+	// awsvpc tasks report their addresses on the ENI attachment
+	for _, attachment := range task.Attachments {
+		for _, detail := range attachment.Details {
+			switch aws.StringValue(detail.Name) {
+			case "privateIPv4Address":
+				summary.PrivateIPs = append(summary.PrivateIPs, aws.StringValue(detail.Value))
+			case "networkInterfaceId":
+				summary.networkInterface = aws.StringValue(detail.Value)
+			}
+		}
+	}
+
+	if len(summary.PrivateIPs) == 0 {
+		for _, container := range task.Containers {
+			for _, ni := range container.NetworkInterfaces {
+				if ni.PrivateIpv4Address != nil {
+					summary.PrivateIPs = append(summary.PrivateIPs, *ni.PrivateIpv4Address)
+				}
+			}
+		}
+	}
+
+	return summary
+}
+
+// resolvePublicIPs fills in the public address of tasks with a network interface
+func (c *Client) resolvePublicIPs(ctx context.Context, summaries []*TaskSummary) error {
+	byENI := map[string][]*TaskSummary{}
+	for _, s := range summaries {
+		if s.networkInterface != "" {
+			byENI[s.networkInterface] = append(byENI[s.networkInterface], s)
+		}
+	}
+
+	if len(byENI) == 0 {
+		return nil
+	}
+
+	var ids []string
+	for eni := range byENI {
+		ids = append(ids, eni)
+	}
+
+	// a filter rather than IDs, interfaces of stopped tasks are already gone
+	for i := 0; i < len(ids); i += 200 {
+		end := i + 200
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		output, err := c.EC2.DescribeNetworkInterfacesWithContext(ctx, &ec2.DescribeNetworkInterfacesInput{
+			Filters: []*ec2.Filter{{
+				Name:   aws.String("network-interface-id"),
+				Values: aws.StringSlice(ids[i:end]),
+			}},
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, ni := range output.NetworkInterfaces {
+			if ni.Association == nil || ni.Association.PublicIp == nil {
+				continue
+			}
+			for _, s := range byENI[aws.StringValue(ni.NetworkInterfaceId)] {
+				s.PublicIP = *ni.Association.PublicIp
+			}
+		}
+	}
+	return nil
+}
+
+// shortTaskDefinition turns a task definition ARN into family:revision
+func shortTaskDefinition(arn string) string {
+	return regexp.MustCompile("[^/]*$").FindString(arn)
+}
@@ -0,0 +1,94 @@
+package ecs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/justmiles/ecs-cli/lib/fake"
+)
+
+func TestSummarizeTask(t *testing.T) {
+	summary := summarizeTask(&ecs.Task{
+		TaskArn:           aws.String("arn:aws:ecs:us-east-1:123456789012:task/default/0123456789abcdef0123456789abcdef"),
+		ClusterArn:        aws.String("arn:aws:ecs:us-east-1:123456789012:cluster/default"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:123456789012:task-definition/web:7"),
+		LastStatus:        aws.String("RUNNING"),
+		DesiredStatus:     aws.String("STOPPED"),
+		Attachments: []*ecs.Attachment{{
+			Type: aws.String("ElasticNetworkInterface"),
+			Details: []*ecs.KeyValuePair{
+				{Name: aws.String("networkInterfaceId"), Value: aws.String("eni-0abc")},
+				{Name: aws.String("privateIPv4Address"), Value: aws.String("10.0.1.5")},
+			},
+		}},
+	})
+
+	if summary.TaskID != "0123456789abcdef0123456789abcdef" || summary.Cluster != "default" || summary.TaskDefinition != "web:7" {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	if len(summary.PrivateIPs) != 1 || summary.PrivateIPs[0] != "10.0.1.5" || summary.networkInterface != "eni-0abc" {
+		t.Errorf("expected the addresses of the ENI attachment, got %v %q", summary.PrivateIPs, summary.networkInterface)
+	}
+
+	bridge := summarizeTask(&ecs.Task{
+		TaskArn:    aws.String("arn:aws:ecs:us-east-1:123456789012:task/default/fedcba9876543210fedcba9876543210"),
+		ClusterArn: aws.String("arn:aws:ecs:us-east-1:123456789012:cluster/default"),
+		Containers: []*ecs.Container{{
+			NetworkInterfaces: []*ecs.NetworkInterface{{PrivateIpv4Address: aws.String("172.17.0.2")}},
+		}},
+	})
+	if len(bridge.PrivateIPs) != 1 || bridge.PrivateIPs[0] != "172.17.0.2" {
+		t.Errorf("expected the container addresses without an attachment, got %v", bridge.PrivateIPs)
+	}
+}
+
+func TestUptime(t *testing.T) {
+	now := time.Date(2020, 1, 2, 15, 0, 0, 0, time.UTC)
+	started := now.Add(-90*time.Minute - 500*time.Millisecond)
+
+	summary := &TaskSummary{StartedAt: &started}
+	if got := summary.Uptime(now); got != 90*time.Minute {
+		t.Errorf("got: %s", got)
+	}
+
+	stopped := now.Add(-time.Hour)
+	summary.StoppedAt = &stopped
+	if got := summary.Uptime(now); got != 30*time.Minute {
+		t.Errorf("expected the uptime until the task stopped, got: %s", got)
+	}
+
+	if got := (&TaskSummary{}).Uptime(now); got != 0 {
+		t.Errorf("expected no uptime for a task that never started, got: %s", got)
+	}
+}
+
+func TestListTasksStartedBy(t *testing.T) {
+	c, b, _ := newFakeClient()
+	b.ECS.Run = func(task *ecs.Task, container *ecs.ContainerDefinition) fake.ContainerRun {
+		return fake.ContainerRun{RunTime: time.Hour}
+	}
+
+	for _, name := range []string{"web", "worker"} {
+		task := newFakeTask()
+		task.Name = name
+		task.NoCleanup = true
+		if err := c.Run(context.Background(), task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tasks, err := c.ListTasks(context.Background(), &ListTasksInput{Cluster: "qa", StartedBy: "ecs cli", Status: "running", Family: "worker"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].TaskDefinition != "worker:1" {
+		t.Errorf("expected the worker task, got %v", tasks)
+	}
+
+	if tasks, _ = c.ListTasks(context.Background(), &ListTasksInput{Cluster: "qa", StartedBy: "ecs cli", Status: "STOPPED"}); len(tasks) != 0 {
+		t.Errorf("expected the status to be filtered, got %v", tasks)
+	}
+}
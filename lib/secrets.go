@@ -0,0 +1,148 @@
+package ecs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// buildSecrets turns NAME=VALUE pairs into container secrets. VALUE is the ARN of an SSM parameter
+// or a Secrets Manager secret (optionally with a json-key, version-stage and version-id suffix), or
+// ssm:/path/name for a parameter of the current account and region.
+func (c *Client) buildSecrets(ctx context.Context, secrets []string) ([]*ecs.Secret, error) {
+	var result []*ecs.Secret
+	var account string
+
+	for _, secret := range secrets {
+		pair := strings.SplitN(secret, "=", 2)
+		if len(pair) != 2 || pair[0] == "" || pair[1] == "" {
+			return nil, fmt.Errorf("invalid secret %q, expected NAME=ARN or NAME=ssm:/path", secret)
+		}
+		name, value := pair[0], pair[1]
+
+		if strings.HasPrefix(value, "ssm:") {
+			if account == "" {
+				if c.STS == nil {
+					return nil, fmt.Errorf("resolving %s requires an STS client", value)
+				}
+				identity, err := c.STS.GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+				if err != nil {
+					return nil, fmt.Errorf("unable to resolve %s: %s", value, err)
+				}
+				account = aws.StringValue(identity.Account)
+			}
+			value = parameterArn(c.Region, account, strings.TrimPrefix(value, "ssm:"))
+		}
+
+		if _, err := secretResource(value); err != nil {
+			return nil, fmt.Errorf("secret %s: %s", name, err)
+		}
+
+		result = append(result, &ecs.Secret{
+			Name:      aws.String(name),
+			ValueFrom: aws.String(value),
+		})
+	}
+	return result, nil
+}
+
+// parameterArn builds the ARN of an SSM parameter
+func parameterArn(region, account, name string) string {
+	return fmt.Sprintf("arn:%s:ssm:%s:%s:parameter/%s", partition(region), region, account, strings.TrimPrefix(name, "/"))
+}
+
+// partition returns the AWS partition of a region
+func partition(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	}
+	return "aws"
+}
+
+// secretResource returns the resource a secret is read from, dropping the json-key,
+// version-stage and version-id suffix of Secrets Manager references
+func secretResource(valueFrom string) (string, error) {
+	parts := strings.Split(valueFrom, ":")
+	if len(parts) < 6 || parts[0] != "arn" {
+		return "", fmt.Errorf("%q is not an ARN", valueFrom)
+	}
+
+	switch parts[2] {
+	case "ssm":
+		if !strings.HasPrefix(parts[5], "parameter/") {
+			return "", fmt.Errorf("%q is not an SSM parameter", valueFrom)
+		}
+		return valueFrom, nil
+	case "secretsmanager":
+		if parts[5] != "secret" || len(parts) < 7 {
+			return "", fmt.Errorf("%q is not a Secrets Manager secret", valueFrom)
+		}
+		return strings.Join(parts[:7], ":"), nil
+	default:
+		return "", fmt.Errorf("%q is neither an SSM parameter nor a Secrets Manager secret", valueFrom)
+	}
+}
+
+// secretAction is the IAM action the execution role needs to read a secret
+func secretAction(resource string) string {
+	if strings.Split(resource, ":")[2] == "ssm" {
+		return "ssm:GetParameters"
+	}
+	return "secretsmanager:GetSecretValue"
+}
+
+// checkSecretAccess verifies the execution role is allowed to read every secret. When the
+// policy simulation itself is not permitted the check is skipped with a warning.
+func (c *Client) checkSecretAccess(ctx context.Context, executionRoleArn string, secrets []*ecs.Secret) error {
+	if len(secrets) == 0 {
+		return nil
+	}
+	if executionRoleArn == "" {
+		return errors.New("secrets require an execution role")
+	}
+	if c.IAM == nil {
+		return errors.New("checking access to secrets requires an IAM client")
+	}
+
+	var denied []string
+	for _, secret := range secrets {
+		resource, err := secretResource(aws.StringValue(secret.ValueFrom))
+		if err != nil {
+			return err
+		}
+
+		output, err := c.IAM.SimulatePrincipalPolicyWithContext(ctx, &iam.SimulatePrincipalPolicyInput{
+			PolicySourceArn: aws.String(executionRoleArn),
+			ActionNames:     aws.StringSlice([]string{secretAction(resource)}),
+			ResourceArns:    aws.StringSlice([]string{resource}),
+		})
+		if err != nil {
+			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "AccessDenied" {
+				c.logWarning(fmt.Sprintf("Unable to verify the execution role can read secrets: %s", aerr.Message()))
+				return nil
+			}
+			return err
+		}
+
+		for _, result := range output.EvaluationResults {
+			if aws.StringValue(result.EvalDecision) != iam.PolicyEvaluationDecisionTypeAllowed {
+				denied = append(denied, fmt.Sprintf("%s (%s on %s)", aws.StringValue(secret.Name), aws.StringValue(result.EvalActionName), resource))
+			}
+		}
+	}
+
+	if len(denied) > 0 {
+		return fmt.Errorf("execution role %s cannot read secrets: %s", executionRoleArn, strings.Join(denied, ", "))
+	}
+	return nil
+}
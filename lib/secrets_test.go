@@ -0,0 +1,79 @@
+package ecs
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+func TestBuildSecrets(t *testing.T) {
+	secrets, err := (&Client{}).buildSecrets(context.Background(), []string{
+		"DB_PASSWORD=arn:aws:ssm:us-east-1:123456789012:parameter/prod/db/password",
+		"API_KEY=arn:aws:secretsmanager:us-east-1:123456789012:secret:prod/api-AbCdEf:key::",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 2 || aws.StringValue(secrets[0].Name) != "DB_PASSWORD" || aws.StringValue(secrets[1].ValueFrom) != "arn:aws:secretsmanager:us-east-1:123456789012:secret:prod/api-AbCdEf:key::" {
+		t.Errorf("unexpected secrets %v", secrets)
+	}
+
+	for _, invalid := range []string{
+		"DB_PASSWORD",
+		"=arn:aws:ssm:us-east-1:123456789012:parameter/x",
+		"DB_PASSWORD=hunter2",
+		"DB_PASSWORD=arn:aws:s3:::bucket/key",
+		"DB_PASSWORD=arn:aws:ssm:us-east-1:123456789012:document/x",
+	} {
+		if _, err := (&Client{}).buildSecrets(context.Background(), []string{invalid}); err == nil {
+			t.Errorf("expected %q to fail", invalid)
+		}
+	}
+}
+
+func TestParameterArn(t *testing.T) {
+	if got := parameterArn("us-east-1", "123456789012", "/prod/db/password"); got != "arn:aws:ssm:us-east-1:123456789012:parameter/prod/db/password" {
+		t.Errorf("got: %s", got)
+	}
+	if got := parameterArn("cn-north-1", "123456789012", "password"); got != "arn:aws-cn:ssm:cn-north-1:123456789012:parameter/password" {
+		t.Errorf("got: %s", got)
+	}
+}
+
+func TestSecretResource(t *testing.T) {
+	resource, err := secretResource("arn:aws:secretsmanager:us-east-1:123456789012:secret:prod/api-AbCdEf:key:AWSCURRENT:")
+	if err != nil || resource != "arn:aws:secretsmanager:us-east-1:123456789012:secret:prod/api-AbCdEf" {
+		t.Errorf("expected the json-key and version suffix to be dropped, got: %s %v", resource, err)
+	}
+	if secretAction(resource) != "secretsmanager:GetSecretValue" {
+		t.Errorf("got: %s", secretAction(resource))
+	}
+	if secretAction("arn:aws:ssm:us-east-1:123456789012:parameter/x") != "ssm:GetParameters" {
+		t.Errorf("got: %s", secretAction("arn:aws:ssm:us-east-1:123456789012:parameter/x"))
+	}
+
+	secrets, _ := (&Client{}).buildSecrets(context.Background(), []string{"DB_PASSWORD=arn:aws:ssm:us-east-1:123456789012:parameter/x"})
+	if err := (&Client{}).checkSecretAccess(context.Background(), "", secrets); err == nil {
+		t.Errorf("expected secrets without an execution role to fail")
+	}
+}
+
+func TestSecretsWithoutClients(t *testing.T) {
+	c, _, _ := newFakeClient()
+
+	if _, err := c.buildSecrets(context.Background(), []string{"DB_PASSWORD=ssm:/qa/db"}); err == nil || !strings.Contains(err.Error(), "requires an STS client") {
+		t.Errorf("expected an error without STS, got %v", err)
+	}
+
+	secrets := []*ecs.Secret{{Name: aws.String("DB"), ValueFrom: aws.String("arn:aws:ssm:us-east-1:000000000000:parameter/qa/db")}}
+	if err := c.checkSecretAccess(context.Background(), "arn:aws:iam::000000000000:role/exec", secrets); err == nil || !strings.Contains(err.Error(), "requires an IAM client") {
+		t.Errorf("expected an error without IAM, got %v", err)
+	}
+
+	if err := c.PortForward(context.Background(), &PortForwardInput{Cluster: "qa"}); err == nil || !strings.Contains(err.Error(), "requires an SSM client") {
+		t.Errorf("expected an error without SSM, got %v", err)
+	}
+}
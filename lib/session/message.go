@@ -0,0 +1,259 @@
+package session
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Message types exchanged over the data channel
+const (
+	inputStreamMessage  = "input_stream_data"
+	outputStreamMessage = "output_stream_data"
+	acknowledgeMessage  = "acknowledge"
+	channelClosed       = "channel_closed"
+	startPublication    = "start_publication"
+	pausePublication    = "pause_publication"
+)
+
+// PayloadType identifies the content of a stream data message
+type PayloadType uint32
+
+// Payload types understood by the SSM agent
+const (
+	Output               PayloadType = 1
+	Error                PayloadType = 2
+	Size                 PayloadType = 3
+	Parameter            PayloadType = 4
+	HandshakeRequest     PayloadType = 5
+	HandshakeResponse    PayloadType = 6
+	HandshakeComplete    PayloadType = 7
+	EncChallengeRequest  PayloadType = 8
+	EncChallengeResponse PayloadType = 9
+	Flag                 PayloadType = 10
+	StdErr               PayloadType = 11
+	ExitCode             PayloadType = 12
+)
+
+// Layout of a binary message. All integers are big endian.
+const (
+	messageTypeLength    = 32
+	messageIDLength      = 16
+	payloadDigestLength  = 32
+	headerLengthOffset   = 0
+	messageTypeOffset    = headerLengthOffset + 4
+	schemaVersionOffset  = messageTypeOffset + messageTypeLength
+	createdDateOffset    = schemaVersionOffset + 4
+	sequenceNumberOffset = createdDateOffset + 8
+	flagsOffset          = sequenceNumberOffset + 8
+	messageIDOffset      = flagsOffset + 8
+	payloadDigestOffset  = messageIDOffset + messageIDLength
+	payloadTypeOffset    = payloadDigestOffset + payloadDigestLength
+	payloadLengthOffset  = payloadTypeOffset + 4
+	payloadOffset        = payloadLengthOffset + 4
+)
+
+const (
+	messageSchemaVersion    uint32 = 1
+	acknowledgeMessageFlags uint64 = 3
+	disconnectToPortFlag    uint32 = 1
+	terminateSessionFlag    uint32 = 2
+	connectToPortErrorFlag  uint32 = 3
+	// largest payload sent in a single input message, matching session-manager-plugin
+	streamDataPayloadSize        = 1024
+	clientVersion                = "1.2.0.0"
+	openDataChannelSchemaVersion = "1.0"
+)
+
+// message is a single binary frame of the SSM session protocol
+type message struct {
+	MessageType    string
+	SchemaVersion  uint32
+	CreatedDate    uint64
+	SequenceNumber int64
+	Flags          uint64
+	MessageID      uuid
+	PayloadType    PayloadType
+	Payload        []byte
+}
+
+// marshal encodes the message in the agent's wire format
+func (m *message) marshal() []byte {
+	b := make([]byte, payloadOffset+len(m.Payload))
+
+	binary.BigEndian.PutUint32(b[headerLengthOffset:], uint32(payloadLengthOffset))
+
+	// the message type is padded with spaces
+	copy(b[messageTypeOffset:schemaVersionOffset], bytes.Repeat([]byte(" "), messageTypeLength))
+	copy(b[messageTypeOffset:schemaVersionOffset], m.MessageType)
+
+	binary.BigEndian.PutUint32(b[schemaVersionOffset:], m.SchemaVersion)
+	binary.BigEndian.PutUint64(b[createdDateOffset:], m.CreatedDate)
+	binary.BigEndian.PutUint64(b[sequenceNumberOffset:], uint64(m.SequenceNumber))
+	binary.BigEndian.PutUint64(b[flagsOffset:], m.Flags)
+
+	// the agent stores the least significant half of the UUID first
+	copy(b[messageIDOffset:], m.MessageID[8:])
+	copy(b[messageIDOffset+8:], m.MessageID[:8])
+
+	digest := sha256.Sum256(m.Payload)
+	copy(b[payloadDigestOffset:], digest[:])
+
+	binary.BigEndian.PutUint32(b[payloadTypeOffset:], uint32(m.PayloadType))
+	binary.BigEndian.PutUint32(b[payloadLengthOffset:], uint32(len(m.Payload)))
+	copy(b[payloadOffset:], m.Payload)
+
+	return b
+}
+
+// unmarshalMessage decodes a message in the agent's wire format
+func unmarshalMessage(b []byte) (*message, error) {
+	if len(b) < payloadOffset {
+		return nil, errors.New("session message is too short")
+	}
+
+	headerLength := binary.BigEndian.Uint32(b[headerLengthOffset:])
+	if int(headerLength)+4 > len(b) || headerLength < payloadLengthOffset {
+		return nil, fmt.Errorf("invalid session message header length %d", headerLength)
+	}
+
+	m := &message{
+		MessageType:    strings.TrimRight(string(b[messageTypeOffset:schemaVersionOffset]), " \x00"),
+		SchemaVersion:  binary.BigEndian.Uint32(b[schemaVersionOffset:]),
+		CreatedDate:    binary.BigEndian.Uint64(b[createdDateOffset:]),
+		SequenceNumber: int64(binary.BigEndian.Uint64(b[sequenceNumberOffset:])),
+		Flags:          binary.BigEndian.Uint64(b[flagsOffset:]),
+		PayloadType:    PayloadType(binary.BigEndian.Uint32(b[payloadTypeOffset:])),
+	}
+	copy(m.MessageID[8:], b[messageIDOffset:messageIDOffset+8])
+	copy(m.MessageID[:8], b[messageIDOffset+8:messageIDOffset+16])
+
+	// the payload length follows the header, which may grow in later schema versions
+	start := int(headerLength) + 4
+	length := int(binary.BigEndian.Uint32(b[headerLength:]))
+	if start+length > len(b) {
+		return nil, fmt.Errorf("session message payload is truncated: expected %d bytes, got %d", length, len(b)-start)
+	}
+	m.Payload = b[start : start+length]
+
+	digest := sha256.Sum256(m.Payload)
+	if !bytes.Equal(digest[:], b[payloadDigestOffset:payloadDigestOffset+payloadDigestLength]) {
+		return nil, errors.New("session message payload digest mismatch")
+	}
+
+	return m, nil
+}
+
+// newMessage builds an outgoing message stamped with a new ID and the current time
+func newMessage(messageType string, sequenceNumber int64, flags uint64, payloadType PayloadType, payload []byte) *message {
+	return &message{
+		MessageType:    messageType,
+		SchemaVersion:  messageSchemaVersion,
+		CreatedDate:    uint64(time.Now().UnixNano() / int64(time.Millisecond)),
+		SequenceNumber: sequenceNumber,
+		Flags:          flags,
+		MessageID:      newUUID(),
+		PayloadType:    payloadType,
+		Payload:        payload,
+	}
+}
+
+// uuid is an RFC 4122 version 4 UUID
+type uuid [16]byte
+
+func newUUID() (u uuid) {
+	if _, err := rand.Read(u[:]); err != nil {
+		panic(err)
+	}
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return u
+}
+
+func (u uuid) String() string {
+	h := hex.EncodeToString(u[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// openDataChannelInput is the first, text, frame sent on the websocket
+type openDataChannelInput struct {
+	MessageSchemaVersion string `json:"MessageSchemaVersion"`
+	RequestID            string `json:"RequestId"`
+	TokenValue           string `json:"TokenValue"`
+	ClientID             string `json:"ClientId"`
+	ClientVersion        string `json:"ClientVersion"`
+}
+
+// acknowledgeContent is the payload of an acknowledge message
+type acknowledgeContent struct {
+	MessageType         string `json:"AcknowledgedMessageType"`
+	MessageID           string `json:"AcknowledgedMessageId"`
+	SequenceNumber      int64  `json:"AcknowledgedMessageSequenceNumber"`
+	IsSequentialMessage bool   `json:"IsSequentialMessage"`
+}
+
+// channelClosedContent is the payload of a channel_closed message
+type channelClosedContent struct {
+	MessageID     string `json:"MessageId"`
+	CreatedDate   string `json:"CreatedDate"`
+	DestinationID string `json:"DestinationId"`
+	SessionID     string `json:"SessionId"`
+	MessageType   string `json:"MessageType"`
+	SchemaVersion int    `json:"SchemaVersion"`
+	Output        string `json:"Output"`
+}
+
+// Client actions negotiated during the handshake
+const (
+	actionSessionType   = "SessionType"
+	actionKMSEncryption = "KMSEncryption"
+
+	actionStatusSuccess     = 1
+	actionStatusFailed      = 2
+	actionStatusUnsupported = 3
+)
+
+type handshakeRequestPayload struct {
+	AgentVersion           string                  `json:"AgentVersion"`
+	RequestedClientActions []requestedClientAction `json:"RequestedClientActions"`
+}
+
+type requestedClientAction struct {
+	ActionType       string          `json:"ActionType"`
+	ActionParameters json.RawMessage `json:"ActionParameters"`
+}
+
+type sessionTypeRequest struct {
+	SessionType string          `json:"SessionType"`
+	Properties  json.RawMessage `json:"Properties"`
+}
+
+type handshakeResponsePayload struct {
+	ClientVersion          string                  `json:"ClientVersion"`
+	ProcessedClientActions []processedClientAction `json:"ProcessedClientActions"`
+	Errors                 []string                `json:"Errors"`
+}
+
+type processedClientAction struct {
+	ActionType   string `json:"ActionType"`
+	ActionStatus int    `json:"ActionStatus"`
+	Error        string `json:"Error,omitempty"`
+}
+
+type handshakeCompletePayload struct {
+	HandshakeTimeToComplete time.Duration `json:"HandshakeTimeToComplete"`
+	CustomerMessage         string        `json:"CustomerMessage"`
+}
+
+// TerminalSize is the payload of a Size message
+type TerminalSize struct {
+	Cols uint32 `json:"cols"`
+	Rows uint32 `json:"rows"`
+}
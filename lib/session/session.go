@@ -0,0 +1,554 @@
+// Package session implements a client for the SSM Session Manager data channel, the websocket
+// protocol spoken by session-manager-plugin, so sessions can be opened without the AWS CLI.
+package session
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Unacknowledged input is resent after this long
+var retransmitTimeout = 3 * time.Second
+
+// Interval between websocket pings that keep idle sessions open
+var keepAliveInterval = 5 * time.Minute
+
+// Session is an open data channel to the SSM agent. Reads return the output of the remote
+// process and writes are sent as its input.
+type Session struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+
+	// AgentVersion is the version of the SSM agent, reported during the handshake
+	AgentVersion string
+	// SessionType is the type requested by the agent during the handshake,
+	// eg InteractiveCommands or Port
+	SessionType string
+	// SessionProperties are the properties sent along with the session type
+	SessionProperties json.RawMessage
+	// CustomerMessage is the text the agent sends when the handshake completes, eg a banner. It
+	// is kept out of the output, which may carry a protocol.
+	CustomerMessage string
+
+	handshakeDone chan struct{}
+	handshakeOnce sync.Once
+	done          chan struct{}
+	doneOnce      sync.Once
+
+	mu sync.Mutex
+	// sequence number of the next input message
+	outSequence int64
+	// sequence number of the next expected output message
+	inSequence int64
+	// out of order output waiting for the messages before it
+	pending map[int64]*message
+	// sent input that hasn't been acknowledged yet
+	unacked map[int64]*outgoing
+	paused  bool
+	err     error
+
+	output *io.PipeWriter
+	reader *io.PipeReader
+	// ExitCode is set when the agent reports the exit status of the remote command
+	exitCode *int
+	// text the agent closed the channel with
+	closedMessage string
+	// Stderr, when set, receives StdErr payloads instead of the output stream
+	Stderr io.Writer
+}
+
+type outgoing struct {
+	msg    *message
+	sentAt time.Time
+}
+
+// Open dials the session's stream URL and opens the data channel with its token
+func Open(streamURL, token string) (*Session, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(streamURL, http.Header{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to session: %s", err)
+	}
+
+	reader, writer := io.Pipe()
+	s := &Session{
+		conn:          conn,
+		handshakeDone: make(chan struct{}),
+		done:          make(chan struct{}),
+		pending:       map[int64]*message{},
+		unacked:       map[int64]*outgoing{},
+		output:        writer,
+		reader:        reader,
+	}
+
+	open, _ := json.Marshal(openDataChannelInput{
+		MessageSchemaVersion: openDataChannelSchemaVersion,
+		RequestID:            newUUID().String(),
+		TokenValue:           token,
+		ClientID:             newUUID().String(),
+		ClientVersion:        clientVersion,
+	})
+	if err := s.send(websocket.TextMessage, open); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to open data channel: %s", err)
+	}
+
+	go s.readLoop()
+	go s.retransmitLoop()
+	go s.keepAliveLoop()
+
+	return s, nil
+}
+
+// WaitForHandshake blocks until the agent has completed the handshake or the session ends
+func (s *Session) WaitForHandshake() error {
+	select {
+	case <-s.handshakeDone:
+		return nil
+	case <-s.done:
+		if err := s.Err(); err != nil {
+			return err
+		}
+		return errors.New("session closed before the handshake completed")
+	}
+}
+
+// Read reads output from the remote process. io.EOF is returned once the channel is closed.
+func (s *Session) Read(p []byte) (int, error) {
+	return s.reader.Read(p)
+}
+
+// Write sends input to the remote process once the handshake has completed
+func (s *Session) Write(p []byte) (int, error) {
+	if err := s.WaitForHandshake(); err != nil {
+		return 0, err
+	}
+
+	for sent := 0; sent < len(p); sent += streamDataPayloadSize {
+		end := sent + streamDataPayloadSize
+		if end > len(p) {
+			end = len(p)
+		}
+
+		// copy, the caller may reuse p before the agent acknowledges it
+		payload := append([]byte(nil), p[sent:end]...)
+		if err := s.sendStreamData(Output, payload); err != nil {
+			return sent, err
+		}
+	}
+	return len(p), nil
+}
+
+// SetSize tells the agent the size of the local terminal
+func (s *Session) SetSize(cols, rows int) error {
+	if err := s.WaitForHandshake(); err != nil {
+		return err
+	}
+
+	payload, _ := json.Marshal(TerminalSize{Cols: uint32(cols), Rows: uint32(rows)})
+	return s.sendStreamData(Size, payload)
+}
+
+// Terminate asks the agent to end the session
+func (s *Session) Terminate() error {
+	return s.sendFlag(terminateSessionFlag)
+}
+
+// DisconnectPort tells the agent the local connection of a non-multiplexed port session
+// has closed, so the next connection starts fresh
+func (s *Session) DisconnectPort() error {
+	return s.sendFlag(disconnectToPortFlag)
+}
+
+// Multiplexed reports whether the agent multiplexes connections of port sessions,
+// which it does from version 3.0.196.0
+func (s *Session) Multiplexed() bool {
+	return compareVersions(s.AgentVersion, "3.0.196.0") > 0
+}
+
+func (s *Session) sendFlag(flag uint32) error {
+	payload := make([]byte, 4)
+	binary.BigEndian.PutUint32(payload, flag)
+	return s.sendStreamData(Flag, payload)
+}
+
+// Close closes the websocket without waiting for the agent
+func (s *Session) Close() error {
+	s.finish(nil)
+	return s.conn.Close()
+}
+
+// Done is closed when the session has ended
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the error that ended the session, if any
+func (s *Session) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// ExitCode returns the exit status of the remote command, when the agent reported one
+func (s *Session) ExitCode() (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.exitCode == nil {
+		return 0, false
+	}
+	return *s.exitCode, true
+}
+
+// ClosedMessage returns the text the agent closed the channel with, if any
+func (s *Session) ClosedMessage() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closedMessage
+}
+
+// finish marks the session as ended, unblocking readers and writers
+func (s *Session) finish(err error) {
+	s.doneOnce.Do(func() {
+		s.mu.Lock()
+		s.err = err
+		s.mu.Unlock()
+
+		if err != nil {
+			s.output.CloseWithError(err)
+		} else {
+			s.output.Close()
+		}
+		close(s.done)
+	})
+}
+
+func (s *Session) send(messageType int, b []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.conn.WriteMessage(messageType, b)
+}
+
+// sendStreamData sends an input_stream_data message and tracks it until acknowledged
+func (s *Session) sendStreamData(payloadType PayloadType, payload []byte) error {
+	select {
+	case <-s.done:
+		if err := s.Err(); err != nil {
+			return err
+		}
+		return io.ErrClosedPipe
+	default:
+	}
+
+	// hold the write lock so messages go out in sequence order
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.mu.Lock()
+	msg := newMessage(inputStreamMessage, s.outSequence, 0, payloadType, payload)
+	s.outSequence++
+	s.unacked[msg.SequenceNumber] = &outgoing{msg: msg, sentAt: time.Now()}
+	paused := s.paused
+	s.mu.Unlock()
+
+	// paused messages are sent by the retransmit loop once publication resumes
+	if paused {
+		return nil
+	}
+	return s.conn.WriteMessage(websocket.BinaryMessage, msg.marshal())
+}
+
+func (s *Session) acknowledge(msg *message) error {
+	content, _ := json.Marshal(acknowledgeContent{
+		MessageType:         msg.MessageType,
+		MessageID:           msg.MessageID.String(),
+		SequenceNumber:      msg.SequenceNumber,
+		IsSequentialMessage: true,
+	})
+	ack := newMessage(acknowledgeMessage, 0, acknowledgeMessageFlags, 0, content)
+	return s.send(websocket.BinaryMessage, ack.marshal())
+}
+
+func (s *Session) readLoop() {
+	for {
+		_, b, err := s.conn.ReadMessage()
+		if err != nil {
+			select {
+			case <-s.done:
+				// closed locally
+			default:
+				s.finish(fmt.Errorf("session connection lost: %s", err))
+			}
+			return
+		}
+
+		msg, err := unmarshalMessage(b)
+		if err != nil {
+			s.finish(err)
+			return
+		}
+
+		if err := s.handleMessage(msg); err != nil {
+			s.finish(err)
+			return
+		}
+
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+	}
+}
+
+func (s *Session) handleMessage(msg *message) error {
+	switch msg.MessageType {
+	case acknowledgeMessage:
+		var ack acknowledgeContent
+		if err := json.Unmarshal(msg.Payload, &ack); err != nil {
+			return fmt.Errorf("invalid acknowledge message: %s", err)
+		}
+		s.mu.Lock()
+		delete(s.unacked, ack.SequenceNumber)
+		s.mu.Unlock()
+
+	case outputStreamMessage:
+		// acknowledge everything, including duplicates of messages already processed
+		if err := s.acknowledge(msg); err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		if msg.SequenceNumber < s.inSequence {
+			s.mu.Unlock()
+			return nil
+		}
+		s.pending[msg.SequenceNumber] = msg
+
+		// process output in sequence order
+		var ready []*message
+		for {
+			next, ok := s.pending[s.inSequence]
+			if !ok {
+				break
+			}
+			delete(s.pending, s.inSequence)
+			ready = append(ready, next)
+			s.inSequence++
+		}
+		s.mu.Unlock()
+
+		for _, m := range ready {
+			if err := s.handleOutput(m); err != nil {
+				return err
+			}
+		}
+
+	case channelClosed:
+		var closed channelClosedContent
+		json.Unmarshal(msg.Payload, &closed)
+		s.mu.Lock()
+		s.closedMessage = closed.Output
+		exited := s.exitCode != nil
+		s.mu.Unlock()
+
+		// the agent also explains a normal end, which only failed when the command didn't exit
+		if closed.Output != "" && !exited {
+			s.finish(fmt.Errorf("session closed: %s", closed.Output))
+		} else {
+			s.finish(nil)
+		}
+
+	case pausePublication:
+		s.mu.Lock()
+		s.paused = true
+		s.mu.Unlock()
+
+	case startPublication:
+		s.mu.Lock()
+		s.paused = false
+		s.mu.Unlock()
+	}
+
+	return nil
+}
+
+func (s *Session) handleOutput(msg *message) error {
+	switch msg.PayloadType {
+	case Output:
+		_, err := s.output.Write(msg.Payload)
+		if err == io.ErrClosedPipe {
+			return nil
+		}
+		return err
+
+	case StdErr:
+		if s.Stderr != nil {
+			_, err := s.Stderr.Write(msg.Payload)
+			return err
+		}
+		_, err := s.output.Write(msg.Payload)
+		if err == io.ErrClosedPipe {
+			return nil
+		}
+		return err
+
+	case ExitCode:
+		if len(msg.Payload) >= 4 {
+			code := int(int32(binary.BigEndian.Uint32(msg.Payload)))
+			s.mu.Lock()
+			s.exitCode = &code
+			s.mu.Unlock()
+		}
+
+	case HandshakeRequest:
+		return s.handleHandshakeRequest(msg.Payload)
+
+	case HandshakeComplete:
+		var complete handshakeCompletePayload
+		json.Unmarshal(msg.Payload, &complete)
+		s.handshakeOnce.Do(func() {
+			s.CustomerMessage = complete.CustomerMessage
+			close(s.handshakeDone)
+		})
+	}
+
+	return nil
+}
+
+func (s *Session) handleHandshakeRequest(payload []byte) error {
+	var request handshakeRequestPayload
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return fmt.Errorf("invalid handshake request: %s", err)
+	}
+
+	s.AgentVersion = request.AgentVersion
+
+	response := handshakeResponsePayload{
+		ClientVersion: clientVersion,
+		Errors:        []string{},
+	}
+
+	var handshakeErr error
+	for _, action := range request.RequestedClientActions {
+		processed := processedClientAction{ActionType: action.ActionType}
+
+		switch action.ActionType {
+		case actionSessionType:
+			var sessionType sessionTypeRequest
+			if err := json.Unmarshal(action.ActionParameters, &sessionType); err != nil {
+				processed.ActionStatus = actionStatusFailed
+				processed.Error = err.Error()
+				break
+			}
+			s.SessionType = sessionType.SessionType
+			s.SessionProperties = sessionType.Properties
+			processed.ActionStatus = actionStatusSuccess
+
+		case actionKMSEncryption:
+			processed.ActionStatus = actionStatusFailed
+			processed.Error = "KMS encryption is not supported by this client"
+			handshakeErr = errors.New("the session requires KMS encryption, which is not supported")
+
+		default:
+			processed.ActionStatus = actionStatusUnsupported
+			processed.Error = fmt.Sprintf("unsupported action %s", action.ActionType)
+		}
+
+		if processed.Error != "" {
+			response.Errors = append(response.Errors, processed.Error)
+		}
+		response.ProcessedClientActions = append(response.ProcessedClientActions, processed)
+	}
+
+	b, _ := json.Marshal(response)
+	if err := s.sendStreamData(HandshakeResponse, b); err != nil {
+		return err
+	}
+	return handshakeErr
+}
+
+// retransmitLoop resends input the agent hasn't acknowledged in time
+func (s *Session) retransmitLoop() {
+	ticker := time.NewTicker(retransmitTimeout / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		if s.paused {
+			s.mu.Unlock()
+			continue
+		}
+		var resend []*message
+		for _, o := range s.unacked {
+			if time.Since(o.sentAt) >= retransmitTimeout {
+				o.sentAt = time.Now()
+				resend = append(resend, o.msg)
+			}
+		}
+		s.mu.Unlock()
+
+		for _, msg := range resend {
+			if err := s.send(websocket.BinaryMessage, msg.marshal()); err != nil {
+				s.finish(err)
+				return
+			}
+		}
+	}
+}
+
+func (s *Session) keepAliveLoop() {
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.writeMu.Lock()
+			err := s.conn.WriteControl(websocket.PingMessage, []byte("keepalive"), time.Now().Add(10*time.Second))
+			s.writeMu.Unlock()
+			if err != nil {
+				s.finish(err)
+				return
+			}
+		}
+	}
+}
+
+// compareVersions compares dotted version numbers, returning -1, 0 or 1
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
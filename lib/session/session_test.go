@@ -0,0 +1,222 @@
+package session
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	msg := newMessage(inputStreamMessage, 42, 0, Output, []byte("hello"))
+
+	decoded, err := unmarshalMessage(msg.marshal())
+	if err != nil {
+		t.Fatalf("got: %v", err)
+	}
+
+	if decoded.MessageType != inputStreamMessage || decoded.SequenceNumber != 42 || decoded.MessageID != msg.MessageID {
+		t.Errorf("unexpected message %+v", decoded)
+	}
+	if string(decoded.Payload) != "hello" || decoded.PayloadType != Output {
+		t.Errorf("unexpected payload %q (%d)", decoded.Payload, decoded.PayloadType)
+	}
+
+	corrupt := msg.marshal()
+	corrupt[len(corrupt)-1] = 'X'
+	if _, err := unmarshalMessage(corrupt); err == nil {
+		t.Errorf("expected a digest mismatch")
+	}
+}
+
+// agent is a local stand-in for the SSM agent's end of the data channel
+type agent struct {
+	t        *testing.T
+	conn     *websocket.Conn
+	sequence int64
+}
+
+func (a *agent) send(payloadType PayloadType, payload []byte) {
+	msg := newMessage(outputStreamMessage, a.sequence, 0, payloadType, payload)
+	a.sequence++
+	if err := a.conn.WriteMessage(websocket.BinaryMessage, msg.marshal()); err != nil {
+		a.t.Error(err)
+	}
+}
+
+// receive returns the next input message, acknowledging it and skipping client acks
+func (a *agent) receive() *message {
+	for {
+		_, b, err := a.conn.ReadMessage()
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		msg, err := unmarshalMessage(b)
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		if msg.MessageType == acknowledgeMessage {
+			continue
+		}
+
+		content, _ := json.Marshal(acknowledgeContent{
+			MessageType:    msg.MessageType,
+			MessageID:      msg.MessageID.String(),
+			SequenceNumber: msg.SequenceNumber,
+		})
+		ack := newMessage(acknowledgeMessage, 0, acknowledgeMessageFlags, 0, content)
+		a.conn.WriteMessage(websocket.BinaryMessage, ack.marshal())
+		return msg
+	}
+}
+
+func TestSessionAgainstStandIn(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		a := &agent{t: t, conn: conn}
+
+		_, b, err := conn.ReadMessage()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var open openDataChannelInput
+		json.Unmarshal(b, &open)
+		if open.TokenValue != "token" {
+			t.Errorf("expected the session token, got %q", open.TokenValue)
+		}
+
+		a.send(HandshakeRequest, []byte(`{"AgentVersion":"3.0.0.0","RequestedClientActions":[{"ActionType":"SessionType","ActionParameters":{"SessionType":"InteractiveCommands"}}]}`))
+
+		response := a.receive()
+		var handshake handshakeResponsePayload
+		json.Unmarshal(response.Payload, &handshake)
+		if response.PayloadType != HandshakeResponse || handshake.ProcessedClientActions[0].ActionStatus != actionStatusSuccess {
+			t.Errorf("unexpected handshake response %s", response.Payload)
+		}
+
+		a.send(HandshakeComplete, []byte(`{}`))
+
+		size := a.receive()
+		if size.PayloadType != Size || !strings.Contains(string(size.Payload), `"cols":80`) {
+			t.Errorf("unexpected size message %s", size.Payload)
+		}
+
+		// echo the input back, resending the first output as a duplicate
+		input := a.receive()
+		a.send(Output, input.Payload)
+		a.sequence--
+		a.send(Output, input.Payload)
+
+		closed, _ := json.Marshal(channelClosedContent{MessageType: channelClosed})
+		conn.WriteMessage(websocket.BinaryMessage, newMessage(channelClosed, 0, 0, 0, closed).marshal())
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	sess, err := Open("ws"+strings.TrimPrefix(server.URL, "http"), "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+
+	if err := sess.WaitForHandshake(); err != nil {
+		t.Fatal(err)
+	}
+	if sess.SessionType != "InteractiveCommands" {
+		t.Errorf("expected the session type from the handshake, got %q", sess.SessionType)
+	}
+
+	if err := sess.SetSize(80, 24); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sess.Write([]byte("echo hi\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := io.ReadAll(sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != "echo hi\n" {
+		t.Errorf("expected the echoed input once, got %q", output)
+	}
+}
+
+func TestSessionAgentMessages(t *testing.T) {
+	for _, exited := range []bool{true, false} {
+		upgrader := websocket.Upgrader{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer conn.Close()
+			a := &agent{t: t, conn: conn}
+
+			if _, _, err := conn.ReadMessage(); err != nil {
+				t.Error(err)
+				return
+			}
+			a.send(HandshakeRequest, []byte(`{"AgentVersion":"3.0.0.0","RequestedClientActions":[{"ActionType":"SessionType","ActionParameters":{"SessionType":"InteractiveCommands"}}]}`))
+			a.receive()
+			a.send(HandshakeComplete, []byte(`{"CustomerMessage":"Starting session with SessionId: ecs-execute-command-0"}`))
+
+			if exited {
+				a.send(ExitCode, []byte{0, 0, 0, 0})
+			}
+			closed, _ := json.Marshal(channelClosedContent{MessageType: channelClosed, Output: "Exiting session with sessionId: ecs-execute-command-0."})
+			conn.WriteMessage(websocket.BinaryMessage, newMessage(channelClosed, 0, 0, 0, closed).marshal())
+			time.Sleep(100 * time.Millisecond)
+		}))
+
+		sess, err := Open("ws"+strings.TrimPrefix(server.URL, "http"), "token")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// nothing reads the output until the handshake is over
+		handshake := make(chan error, 1)
+		go func() { handshake <- sess.WaitForHandshake() }()
+		select {
+		case err := <-handshake:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected the handshake to complete")
+		}
+		if sess.CustomerMessage != "Starting session with SessionId: ecs-execute-command-0" {
+			t.Errorf("unexpected customer message %q", sess.CustomerMessage)
+		}
+
+		output, _ := io.ReadAll(sess)
+		if len(output) != 0 {
+			t.Errorf("expected the agent's messages to be kept out of the output, got %q", output)
+		}
+		if exited && sess.Err() != nil {
+			t.Errorf("expected a session whose command exited to end cleanly, got %v", sess.Err())
+		}
+		if !exited && sess.Err() == nil {
+			t.Errorf("expected a session closed before the command exited to fail")
+		}
+		if sess.ClosedMessage() != "Exiting session with sessionId: ecs-execute-command-0." {
+			t.Errorf("unexpected closed message %q", sess.ClosedMessage())
+		}
+
+		sess.Close()
+		server.Close()
+	}
+}
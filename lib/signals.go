@@ -0,0 +1,75 @@
+package ecs
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// SignalHooks are the actions EscalateSignals takes on the tasks and the process
+type SignalHooks struct {
+	// Stop asks the tasks to stop, called once on the first signal
+	Stop func(reason string)
+	// Exit ends the process with the exit code
+	Exit    func(code int)
+	Warning func(message string)
+}
+
+// EscalateSignals handles the signals received while following tasks. The first one stops the
+// tasks, or exits right away when stopOnExit is false, and every later one exits without waiting
+// for them. The exit code is 128 plus the first signal, eg 130 for SIGINT.
+//
+// There is no separate third step that detaches without stopping: the second signal already
+// exits without waiting for the stop request, so the process is gone before a third arrives.
+// Should the Exit hook return, a third signal exits again and never stops the tasks twice.
+//
+// The returned function reports the exit code once the tasks have been asked to stop, false when
+// no signal was received.
+func EscalateSignals(sigs <-chan os.Signal, stopOnExit bool, hooks SignalHooks) func() (int, bool) {
+	var (
+		mu       sync.Mutex
+		exitCode int
+		received int
+		stopped  = make(chan struct{})
+	)
+
+	go func() {
+		for sig := range sigs {
+			mu.Lock()
+			received++
+			if s, ok := sig.(syscall.Signal); ok && received == 1 {
+				exitCode = 128 + int(s)
+			}
+			first, code := received == 1, exitCode
+			mu.Unlock()
+
+			switch {
+			case first && stopOnExit:
+				hooks.Warning(fmt.Sprintf("Received %v, stopping tasks. Send it again to exit without waiting for them", sig))
+				reason := "ecs-cli: received " + sig.String()
+				go func() {
+					hooks.Stop(reason)
+					close(stopped)
+				}()
+			case first:
+				hooks.Warning(fmt.Sprintf("Received %v, leaving tasks running", sig))
+				close(stopped)
+				hooks.Exit(code)
+			default:
+				hooks.Exit(code)
+			}
+		}
+	}()
+
+	return func() (int, bool) {
+		mu.Lock()
+		signalled, code := received > 0, exitCode
+		mu.Unlock()
+		if !signalled {
+			return 0, false
+		}
+		<-stopped
+		return code, true
+	}
+}
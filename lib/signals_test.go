@@ -0,0 +1,107 @@
+package ecs
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// signalRecorder records the hooks EscalateSignals calls
+type signalRecorder struct {
+	stops   chan string
+	exits   chan int
+	release chan struct{}
+}
+
+func newSignalRecorder() *signalRecorder {
+	return &signalRecorder{stops: make(chan string, 3), exits: make(chan int, 3), release: make(chan struct{})}
+}
+
+func (r *signalRecorder) hooks() SignalHooks {
+	return SignalHooks{
+		Stop: func(reason string) {
+			r.stops <- reason
+			<-r.release
+		},
+		Exit:    func(code int) { r.exits <- code },
+		Warning: func(string) {},
+	}
+}
+
+func expectStop(t *testing.T, stops chan string) string {
+	t.Helper()
+	select {
+	case reason := <-stops:
+		return reason
+	case <-time.After(time.Second):
+		t.Fatal("expected the tasks to be stopped")
+	}
+	return ""
+}
+
+func expectExit(t *testing.T, exits chan int, code int) {
+	t.Helper()
+	select {
+	case got := <-exits:
+		if got != code {
+			t.Errorf("expected exit code %d, got %d", code, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the process to exit")
+	}
+}
+
+func TestEscalateSignals(t *testing.T) {
+	r := newSignalRecorder()
+	sigs := make(chan os.Signal, 3)
+	defer close(sigs)
+	interrupted := EscalateSignals(sigs, true, r.hooks())
+
+	if _, ok := interrupted(); ok {
+		t.Errorf("expected no interruption before a signal")
+	}
+
+	// the first signal stops the tasks without exiting
+	sigs <- syscall.SIGINT
+	if reason := expectStop(t, r.stops); reason != "ecs-cli: received interrupt" {
+		t.Errorf("unexpected stop reason %s", reason)
+	}
+	select {
+	case code := <-r.exits:
+		t.Fatalf("expected the first signal not to exit, got %d", code)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// the second one exits right away, with the code of the first, even while stopping
+	sigs <- syscall.SIGTERM
+	expectExit(t, r.exits, 130)
+
+	// a third one, had the process survived the second, exits without stopping the tasks again
+	sigs <- syscall.SIGTERM
+	expectExit(t, r.exits, 130)
+	if len(r.stops) != 0 {
+		t.Errorf("expected the tasks to be stopped only once")
+	}
+
+	close(r.release)
+	if code, ok := interrupted(); !ok || code != 130 {
+		t.Errorf("expected an interruption by SIGINT, got %d %v", code, ok)
+	}
+}
+
+func TestEscalateSignalsWithoutStop(t *testing.T) {
+	r := newSignalRecorder()
+	sigs := make(chan os.Signal, 3)
+	defer close(sigs)
+	interrupted := EscalateSignals(sigs, false, r.hooks())
+
+	sigs <- syscall.SIGTERM
+	expectExit(t, r.exits, 143)
+	if len(r.stops) != 0 {
+		t.Errorf("expected the tasks to be left running")
+	}
+	if code, ok := interrupted(); !ok || code != 143 {
+		t.Errorf("expected an interruption by SIGTERM, got %d %v", code, ok)
+	}
+}
@@ -0,0 +1,87 @@
+package ecs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SpecVersion is the current version of the task spec file format
+const SpecVersion = 1
+
+// TaskSpec is the on-disk representation of a Task
+type TaskSpec struct {
+	Version int `yaml:"version"`
+	Task    `yaml:",inline"`
+}
+
+// Container is an additional container run alongside the main container of a task
+type Container struct {
+	Name              string   `yaml:"name"`
+	Image             string   `yaml:"image"`
+	Command           []string `yaml:"command,omitempty"`
+	Environment       []string `yaml:"environment,omitempty"`
+	Publish           []string `yaml:"publish,omitempty"`
+	CPUReservation    int64    `yaml:"cpuReservation,omitempty"`
+	MemoryReservation int64    `yaml:"memoryReservation,omitempty"`
+	// Essential defaults to false for additional containers
+	Essential bool `yaml:"essential,omitempty"`
+	// Containers this one waits on, as NAME:CONDITION (eg proxy:HEALTHY)
+	DependsOn []string `yaml:"dependsOn,omitempty"`
+}
+
+// LoadTaskSpec reads a YAML or JSON task spec file
+func LoadTaskSpec(path string) (*Task, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec TaskSpec
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("unable to parse task spec %s: %s", path, err)
+	}
+
+	if spec.Version > SpecVersion {
+		return nil, fmt.Errorf("unsupported task spec version %d (latest supported is %d)", spec.Version, SpecVersion)
+	}
+
+	for i, c := range spec.Containers {
+		if c.Name == "" || c.Image == "" {
+			return nil, fmt.Errorf("task spec %s: containers[%d] requires a name and an image", path, i)
+		}
+	}
+
+	return &spec.Task, nil
+}
+
+// Spec renders the task as a YAML task spec
+func (t *Task) Spec() ([]byte, error) {
+	return yaml.Marshal(TaskSpec{
+		Version: SpecVersion,
+		Task:    *t,
+	})
+}
+
+// ApplySpec copies every non-empty value from spec onto the task, except for the fields
+// named in overridden which were explicitly set by the caller (e.g. from CLI flags)
+func (t *Task) ApplySpec(spec *Task, overridden map[string]bool) {
+	dst := reflect.ValueOf(t).Elem()
+	src := reflect.ValueOf(spec).Elem()
+
+	for i := 0; i < dst.NumField(); i++ {
+		field := dst.Type().Field(i)
+		if field.PkgPath != "" || field.Tag.Get("yaml") == "-" || overridden[field.Name] {
+			continue
+		}
+		if src.Field(i).IsZero() {
+			continue
+		}
+		dst.Field(i).Set(src.Field(i))
+	}
+}
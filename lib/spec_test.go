@@ -0,0 +1,48 @@
+package ecs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadAndApplyTaskSpec(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "task.yaml")
+	err := os.WriteFile(path, []byte(`version: 1
+cluster: qa
+image: alpine
+count: 2
+environment:
+  - A=1
+containers:
+  - name: proxy
+    image: envoyproxy/envoy
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec, err := LoadTaskSpec(path)
+	if err != nil {
+		t.Fatalf("got: %v", err)
+	}
+
+	task := Task{Cluster: "prod", Count: 1, Name: "from-flags", Environment: []string{"A=flag"}}
+	task.ApplySpec(spec, map[string]bool{"Cluster": true, "Environment": true})
+
+	if task.Cluster != "prod" {
+		t.Errorf("expected flag to override cluster, got %s", task.Cluster)
+	}
+	if len(task.Environment) != 1 || task.Environment[0] != "A=flag" {
+		t.Errorf("expected flag to override environment, got %v", task.Environment)
+	}
+	if task.Count != 2 || task.Image != "alpine" {
+		t.Errorf("expected count and image from spec, got %d %s", task.Count, task.Image)
+	}
+	if task.Name != "from-flags" {
+		t.Errorf("expected unset spec values to keep flag defaults, got %s", task.Name)
+	}
+	if len(task.Containers) != 1 || task.Containers[0].Name != "proxy" {
+		t.Errorf("expected one additional container, got %v", task.Containers)
+	}
+}
@@ -0,0 +1,211 @@
+package ecs
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/fatih/color"
+)
+
+// number of empty polls after a task stops before its log streams are considered drained.
+// CloudWatch Logs may receive the final lines a few seconds after the task stops.
+const drainPolls = 2
+
+// prefix colors, assigned round-robin per task like docker-compose does per service
+var prefixColors = []color.Attribute{
+	color.FgCyan,
+	color.FgMagenta,
+	color.FgBlue,
+	color.FgGreen,
+	color.FgHiCyan,
+	color.FgHiMagenta,
+	color.FgHiBlue,
+}
+
+// logFollower tails a single CloudWatch Logs stream, one per task and container
+type logFollower struct {
+	prefix    string
+	group     string
+	stream    string
+	taskArn   string
+	container string
+	nextToken string
+
+	emptyPollsAfterStop int
+	done                bool
+}
+
+// followedEvent is a log event annotated with the follower that read it
+type followedEvent struct {
+	follower *logFollower
+	event    *cloudwatchlogs.OutputLogEvent
+}
+
+// logFollowers builds a follower for each container of each task
+func (t *Task) logFollowers() (followers []*logFollower) {
+	var re = regexp.MustCompile("[^/]*$")
+	containers := t.streamableContainers()
+
+	// label lines only when they could come from more than one stream
+	var labels []string
+	for _, task := range t.Tasks {
+		for _, container := range containers {
+			var label []string
+			if len(t.Tasks) > 1 {
+				label = append(label, shortTaskID(re.FindString(*task.TaskArn)))
+			}
+			if len(containers) > 1 {
+				label = append(label, *container.Name)
+			}
+			labels = append(labels, strings.Join(label, " "))
+		}
+	}
+
+	width := 0
+	for _, label := range labels {
+		if len(label) > width {
+			width = len(label)
+		}
+	}
+
+	i := 0
+	for n, task := range t.Tasks {
+		paint := color.New(prefixColors[n%len(prefixColors)]).SprintFunc()
+		for _, container := range containers {
+			options := container.LogConfiguration.Options
+
+			prefix := ""
+			if labels[i] != "" {
+				prefix = paint(fmt.Sprintf("%-*s", width, labels[i]))
+			}
+			i++
+
+			followers = append(followers, &logFollower{
+				prefix:    prefix,
+				group:     *options["awslogs-group"],
+				stream:    *options["awslogs-stream-prefix"] + "/" + *container.Name + "/" + re.FindString(*task.TaskArn),
+				taskArn:   *task.TaskArn,
+				container: *container.Name,
+			})
+		}
+	}
+	return followers
+}
+
+// poll reads the next page of events from the stream
+func (f *logFollower) poll(ctx context.Context, logs cloudwatchlogsiface.CloudWatchLogsAPI) ([]*followedEvent, error) {
+	logEventsInput := cloudwatchlogs.GetLogEventsInput{
+		StartFromHead: aws.Bool(true),
+		LogGroupName:  aws.String(f.group),
+		LogStreamName: aws.String(f.stream),
+	}
+
+	if f.nextToken != "" {
+		logEventsInput.NextToken = aws.String(f.nextToken)
+	}
+
+	logEvents, err := logs.GetLogEventsWithContext(ctx, &logEventsInput)
+	if err != nil {
+		// The stream does not exist until the container starts
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == cloudwatchlogs.ErrCodeResourceNotFoundException {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if logEvents.NextForwardToken != nil {
+		f.nextToken = *logEvents.NextForwardToken
+	}
+
+	events := make([]*followedEvent, 0, len(logEvents.Events))
+	for _, e := range logEvents.Events {
+		events = append(events, &followedEvent{follower: f, event: e})
+	}
+	return events, nil
+}
+
+// observe records the outcome of a poll and marks the follower done once its task has
+// stopped and the stream has been drained
+func (f *logFollower) observe(events int, taskStopped bool) {
+	if !taskStopped {
+		return
+	}
+	if events > 0 {
+		f.emptyPollsAfterStop = 0
+		return
+	}
+	f.emptyPollsAfterStop++
+	if f.emptyPollsAfterStop >= drainPolls {
+		f.done = true
+	}
+}
+
+func allFollowersDone(followers []*logFollower) bool {
+	for _, f := range followers {
+		if !f.done {
+			return false
+		}
+	}
+	return true
+}
+
+// emitFollowedEvents emits events from all streams in timestamp order
+func (c *Client) emitFollowedEvents(events []*followedEvent) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return aws.Int64Value(events[i].event.Timestamp) < aws.Int64Value(events[j].event.Timestamp)
+	})
+	for _, e := range events {
+		c.emit(Event{
+			Type:          EventLogLine,
+			Time:          millisToTime(e.event.Timestamp),
+			TaskArn:       e.follower.taskArn,
+			ContainerName: e.follower.container,
+			LogStream:     e.follower.stream,
+			Message:       aws.StringValue(e.event.Message),
+			prefix:        e.follower.prefix,
+		})
+	}
+}
+
+// stoppedTasks returns the ARNs of the tasks that have reached STOPPED
+func (c *Client) stoppedTasks(ctx context.Context, t *Task) map[string]bool {
+	stopped := map[string]bool{}
+
+	var byCluster = map[string][]*string{}
+	for _, task := range t.Tasks {
+		byCluster[*task.ClusterArn] = append(byCluster[*task.ClusterArn], task.TaskArn)
+	}
+
+	for cluster, tasks := range byCluster {
+		res, err := c.ECS.DescribeTasksWithContext(ctx, &ecs.DescribeTasksInput{
+			Cluster: aws.String(cluster),
+			Tasks:   tasks,
+		})
+		if err != nil {
+			c.logError(err)
+			continue
+		}
+		for _, task := range res.Tasks {
+			if aws.StringValue(task.LastStatus) == ecs.DesiredStatusStopped {
+				stopped[*task.TaskArn] = true
+			}
+		}
+	}
+	return stopped
+}
+
+// shortTaskID abbreviates a task ID the way docker abbreviates container IDs
+func shortTaskID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
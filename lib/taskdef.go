@@ -0,0 +1,145 @@
+package ecs
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// unorderedFields are list fields of a task definition whose ordering carries no meaning.
+// Lists not named here (Command, EntryPoint, EnvironmentFiles, ...) are compared in order.
+var unorderedFields = map[string]bool{
+	"ContainerDefinitions":    true,
+	"DependsOn":               true,
+	"Environment":             true,
+	"ExtraHosts":              true,
+	"MountPoints":             true,
+	"PlacementConstraints":    true,
+	"PortMappings":            true,
+	"RequiresCompatibilities": true,
+	"Secrets":                 true,
+	"SystemControls":          true,
+	"Tags":                    true,
+	"Ulimits":                 true,
+	"Volumes":                 true,
+	"VolumesFrom":             true,
+}
+
+// latestTaskDefinition returns the latest ACTIVE revision of a family along with its tags.
+// A nil task definition is returned when the family has no active revision.
+func (c *Client) latestTaskDefinition(ctx context.Context, family string) (*ecs.TaskDefinition, []*ecs.Tag, error) {
+	output, err := c.ECS.DescribeTaskDefinitionWithContext(ctx, &ecs.DescribeTaskDefinitionInput{
+		Include:        aws.StringSlice([]string{"TAGS"}),
+		TaskDefinition: aws.String(family),
+	})
+	if err != nil {
+		// ECS reports an unknown family as a ClientException
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == ecs.ErrCodeClientException {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	if output.TaskDefinition == nil || aws.StringValue(output.TaskDefinition.Status) != ecs.TaskDefinitionStatusActive {
+		return nil, nil, nil
+	}
+
+	return output.TaskDefinition, output.Tags, nil
+}
+
+// taskDefinitionMatches reports whether an existing revision is semantically equal to the
+// registration input. Server-populated fields, empty values and list ordering are ignored.
+func taskDefinitionMatches(td *ecs.TaskDefinition, tags []*ecs.Tag, input *ecs.RegisterTaskDefinitionInput) bool {
+	if td == nil || input == nil {
+		return false
+	}
+
+	// Round-trip the existing revision through the input type to drop the server-populated
+	// fields (TaskDefinitionArn, Revision, Status, RegisteredAt, ...)
+	var existing ecs.RegisterTaskDefinitionInput
+	b, err := json.Marshal(td)
+	if err != nil {
+		return false
+	}
+	if err := json.Unmarshal(b, &existing); err != nil {
+		return false
+	}
+	existing.Tags = tags
+
+	a, err := normalizeTaskDefinition(&existing)
+	if err != nil {
+		return false
+	}
+	b2, err := normalizeTaskDefinition(input)
+	if err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(a, b2)
+}
+
+// normalizeTaskDefinition converts a registration input into a generic, canonical form
+func normalizeTaskDefinition(input *ecs.RegisterTaskDefinitionInput) (interface{}, error) {
+	b, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+
+	return normalizeValue("", v), nil
+}
+
+func normalizeValue(key string, v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for k, item := range val {
+			if n := normalizeValue(k, item); n != nil {
+				m[k] = n
+			}
+		}
+		if len(m) == 0 {
+			return nil
+		}
+		return m
+	case []interface{}:
+		var l []interface{}
+		for _, item := range val {
+			if n := normalizeValue("", item); n != nil {
+				l = append(l, n)
+			}
+		}
+		if len(l) == 0 {
+			return nil
+		}
+		if unorderedFields[key] {
+			sort.SliceStable(l, func(i, j int) bool {
+				return canonicalString(l[i]) < canonicalString(l[j])
+			})
+		}
+		return l
+	case string:
+		if val == "" {
+			return nil
+		}
+		return val
+	default:
+		return val
+	}
+}
+
+// canonicalString renders a normalized value deterministically for sorting
+func canonicalString(v interface{}) string {
+	// encoding/json sorts map keys, so equal values always render identically
+	b, _ := json.Marshal(v)
+	return string(b)
+}
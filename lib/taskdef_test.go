@@ -0,0 +1,62 @@
+package ecs
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+func TestTaskDefinitionMatches(t *testing.T) {
+	input := &ecs.RegisterTaskDefinitionInput{
+		Family:      aws.String("test"),
+		TaskRoleArn: aws.String(""),
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				Name:    aws.String("test"),
+				Image:   aws.String("alpine"),
+				Command: aws.StringSlice([]string{"echo", "hello"}),
+				Environment: []*ecs.KeyValuePair{
+					{Name: aws.String("A"), Value: aws.String("1")},
+					{Name: aws.String("B"), Value: aws.String("2")},
+				},
+				VolumesFrom: []*ecs.VolumeFrom{},
+			},
+		},
+	}
+
+	// what ECS would hand back for the same registration
+	existing := &ecs.TaskDefinition{
+		Family:            aws.String("test"),
+		Revision:          aws.Int64(3),
+		Status:            aws.String("ACTIVE"),
+		TaskDefinitionArn: aws.String("arn:aws:ecs:us-east-1:000000000000:task-definition/test:3"),
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				Name:    aws.String("test"),
+				Image:   aws.String("alpine"),
+				Command: aws.StringSlice([]string{"echo", "hello"}),
+				Environment: []*ecs.KeyValuePair{
+					{Name: aws.String("B"), Value: aws.String("2")},
+					{Name: aws.String("A"), Value: aws.String("1")},
+				},
+				MountPoints: []*ecs.MountPoint{},
+			},
+		},
+	}
+
+	if !taskDefinitionMatches(existing, nil, input) {
+		t.Errorf("expected reordered environment and server fields to be ignored")
+	}
+
+	existing.ContainerDefinitions[0].Command = aws.StringSlice([]string{"hello", "echo"})
+	if taskDefinitionMatches(existing, nil, input) {
+		t.Errorf("expected command order to matter")
+	}
+
+	existing.ContainerDefinitions[0].Command = aws.StringSlice([]string{"echo", "hello"})
+	input.Tags = []*ecs.Tag{{Key: aws.String("team"), Value: aws.String("ops")}}
+	if taskDefinitionMatches(existing, nil, input) {
+		t.Errorf("expected differing tags to not match")
+	}
+}
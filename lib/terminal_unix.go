@@ -0,0 +1,36 @@
+//go:build !windows
+
+package ecs
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/justmiles/ecs-cli/lib/session"
+	"golang.org/x/term"
+)
+
+// watchTerminalSize sends the terminal size to the session now and whenever it changes
+func watchTerminalSize(sess *session.Session) (stop func()) {
+	sendTerminalSize(sess)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGWINCH)
+	go func() {
+		for range sigs {
+			sendTerminalSize(sess)
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigs)
+		close(sigs)
+	}
+}
+
+func sendTerminalSize(sess *session.Session) {
+	if cols, rows, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+		sess.SetSize(cols, rows)
+	}
+}
@@ -0,0 +1,37 @@
+//go:build windows
+
+package ecs
+
+import (
+	"os"
+	"time"
+
+	"github.com/justmiles/ecs-cli/lib/session"
+	"golang.org/x/term"
+)
+
+// watchTerminalSize sends the terminal size to the session now and whenever it changes.
+// Windows has no SIGWINCH, so the size is polled.
+func watchTerminalSize(sess *session.Session) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		var cols, rows int
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			if c, r, err := term.GetSize(int(os.Stdout.Fd())); err == nil && (c != cols || r != rows) {
+				cols, rows = c, r
+				sess.SetSize(cols, rows)
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
@@ -0,0 +1,133 @@
+package ecs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// DeadlineTag marks a task with the RFC3339 time after which Reap stops it
+const DeadlineTag = "ecs-cli:deadline"
+
+// ExitCodeTimeout is returned by Wait when the tasks were stopped for exceeding their timeout,
+// like coreutils timeout
+const ExitCodeTimeout = 124
+
+// applyTimeout sets the deadline of the task and tags the tasks to run with it, so they can be
+// reaped once the CLI is gone
+func (t *Task) applyTimeout(input *ecs.RunTaskInput, now time.Time) error {
+	if t.Timeout == "" {
+		return nil
+	}
+
+	timeout, err := time.ParseDuration(t.Timeout)
+	if err != nil || timeout <= 0 {
+		return fmt.Errorf("invalid timeout %q, expected a duration such as 30m", t.Timeout)
+	}
+
+	t.deadline = now.Add(timeout)
+	input.Tags = append(input.Tags, &ecs.Tag{
+		Key:   aws.String(DeadlineTag),
+		Value: aws.String(t.deadline.UTC().Format(time.RFC3339)),
+	})
+	return nil
+}
+
+// ReapInput selects the tasks Reap stops
+type ReapInput struct {
+	// All clusters are reaped when empty
+	Cluster string
+	// Only report the overdue tasks
+	DryRun bool
+}
+
+// ReapedTask is a task that was running past its deadline
+type ReapedTask struct {
+	TaskID   string    `json:"taskId" yaml:"taskId"`
+	Cluster  string    `json:"cluster" yaml:"cluster"`
+	Deadline time.Time `json:"deadline" yaml:"deadline"`
+}
+
+// Reap stops the running tasks whose deadline tag has passed, across all clusters when none is
+// given, and returns them
+func (c *Client) Reap(ctx context.Context, input *ReapInput) ([]*ReapedTask, error) {
+	clusters := []string{input.Cluster}
+	if input.Cluster == "" {
+		var err error
+		if clusters, err = c.GetClusters(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	reaped := []*ReapedTask{}
+	for _, cluster := range clusters {
+		var arns []*string
+		err := c.ECS.ListTasksPagesWithContext(ctx, &ecs.ListTasksInput{
+			Cluster:       aws.String(cluster),
+			DesiredStatus: aws.String(ecs.DesiredStatusRunning),
+		}, func(page *ecs.ListTasksOutput, lastPage bool) bool {
+			arns = append(arns, page.TaskArns...)
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// DescribeTasks accepts at most 100 tasks
+		for i := 0; i < len(arns); i += 100 {
+			end := i + 100
+			if end > len(arns) {
+				end = len(arns)
+			}
+
+			output, err := c.ECS.DescribeTasksWithContext(ctx, &ecs.DescribeTasksInput{
+				Cluster: aws.String(cluster),
+				Tasks:   arns[i:end],
+				Include: aws.StringSlice([]string{ecs.TaskFieldTags}),
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			for _, task := range output.Tasks {
+				deadline, ok := taskDeadline(task)
+				if !ok || now.Before(deadline) {
+					continue
+				}
+
+				if !input.DryRun {
+					_, err := c.ECS.StopTaskWithContext(ctx, &ecs.StopTaskInput{
+						Cluster: task.ClusterArn,
+						Task:    task.TaskArn,
+						Reason:  aws.String("ecs-cli: deadline " + deadline.Format(time.RFC3339) + " exceeded"),
+					})
+					if err != nil {
+						return reaped, err
+					}
+				}
+				reaped = append(reaped, &ReapedTask{
+					TaskID:   parseTaskId(aws.StringValue(task.TaskArn)),
+					Cluster:  parseClusterName(aws.StringValue(task.ClusterArn)),
+					Deadline: deadline,
+				})
+			}
+		}
+	}
+	return reaped, nil
+}
+
+// taskDeadline returns the deadline a task is tagged with
+func taskDeadline(task *ecs.Task) (time.Time, bool) {
+	for _, tag := range task.Tags {
+		if aws.StringValue(tag.Key) != DeadlineTag {
+			continue
+		}
+		deadline, err := time.Parse(time.RFC3339, aws.StringValue(tag.Value))
+		return deadline, err == nil
+	}
+	return time.Time{}, false
+}
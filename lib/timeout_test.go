@@ -0,0 +1,87 @@
+package ecs
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/justmiles/ecs-cli/lib/fake"
+)
+
+func TestTimeout(t *testing.T) {
+	c, b, recorder := newFakeClient()
+	b.ECS.Run = func(task *ecs.Task, container *ecs.ContainerDefinition) fake.ContainerRun {
+		return fake.ContainerRun{RunTime: 24 * time.Hour}
+	}
+
+	task := newFakeTask()
+	task.Timeout = "20ms"
+	if err := c.Run(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+
+	exitCode, err := follow(c, task)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != ExitCodeTimeout {
+		t.Errorf("expected the timeout exit code, got %d", exitCode)
+	}
+
+	stopped := b.ECS.Tasks()[0]
+	if aws.StringValue(stopped.StoppedReason) != "ecs-cli: timeout of 20ms exceeded" {
+		t.Errorf("unexpected stop %v", stopped)
+	}
+	if _, ok := taskDeadline(stopped); !ok {
+		t.Errorf("expected the task to be tagged with its deadline, got %v", stopped.Tags)
+	}
+	if len(eventsOfType(recorder.events, EventTimedOut)) != 1 {
+		t.Errorf("expected a single timeout event")
+	}
+
+	task = newFakeTask()
+	task.Timeout = "soon"
+	if err := c.Run(context.Background(), task); err == nil || !strings.Contains(err.Error(), "invalid timeout") {
+		t.Errorf("expected an invalid timeout, got %v", err)
+	}
+}
+
+func TestReap(t *testing.T) {
+	c, b, _ := newFakeClient()
+	b.ECS.Run = func(task *ecs.Task, container *ecs.ContainerDefinition) fake.ContainerRun {
+		return fake.ContainerRun{RunTime: 24 * time.Hour}
+	}
+
+	for _, timeout := range []string{"1ns", "1h", ""} {
+		task := newFakeTask()
+		task.Detach = true
+		task.Timeout = timeout
+		if err := c.Run(context.Background(), task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	overdue := parseTaskId(aws.StringValue(b.ECS.Tasks()[0].TaskArn))
+
+	reaped, err := c.Reap(context.Background(), &ReapInput{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reaped) != 1 || reaped[0].TaskID != overdue || reaped[0].Cluster != "qa" {
+		t.Fatalf("expected the overdue task, got %v", reaped)
+	}
+	if b.ECS.Tasks()[0].StoppedReason != nil {
+		t.Errorf("expected a dry run to leave the task running")
+	}
+
+	if reaped, err = c.Reap(context.Background(), &ReapInput{Cluster: "qa"}); err != nil || len(reaped) != 1 {
+		t.Fatalf("expected the overdue task to be reaped, got %v %v", reaped, err)
+	}
+	for i, task := range b.ECS.Tasks() {
+		if stopping := aws.StringValue(task.DesiredStatus) == ecs.DesiredStatusStopped; stopping != (i == 0) {
+			t.Errorf("task %d: unexpected desired status %s", i, aws.StringValue(task.DesiredStatus))
+		}
+	}
+}
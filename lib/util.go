@@ -1,49 +1,17 @@
 package ecs
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ecs"
-	"github.com/fatih/color"
 )
 
-var (
-	sess = session.Must(session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-	}))
-	ecsClient            *ecs.ECS
-	ec2Client            *ec2.EC2
-	cloudwatchlogsClient *cloudwatchlogs.CloudWatchLogs
-)
-
-func init() {
-	initAWSClients("")
-}
-
-func initAWSClients(roleArn string) {
-
-	var creds *credentials.Credentials
-	if roleArn != "" {
-		creds = stscreds.NewCredentials(sess, roleArn, func(arp *stscreds.AssumeRoleProvider) {})
-	}
-
-	awsConfig := &aws.Config{Credentials: creds}
-
-	ecsClient = ecs.New(sess, awsConfig)
-	ec2Client = ec2.New(sess, awsConfig)
-	cloudwatchlogsClient = cloudwatchlogs.New(sess, awsConfig)
-}
-
 func buildEnvironmentKeyValuePair(environment []string) (k []*ecs.KeyValuePair) {
 	if len(environment) < 1 {
 		return []*ecs.KeyValuePair{}
@@ -72,43 +40,9 @@ func buildEnvironmentKeyValuePair(environment []string) (k []*ecs.KeyValuePair)
 	return
 }
 
-func buildPortMapping(publish []string) (k []*ecs.PortMapping) {
-	if len(publish) < 1 {
-		return []*ecs.PortMapping{}
-	}
-	for _, env := range publish {
-		envArr := strings.Split(env, ":")
-
-		// If only one port is provided, map it to both container and host
-		// default to TCP map
-		hostPort, _ := strconv.ParseInt(envArr[0], 10, 64)
-		portMap := ecs.PortMapping{
-			ContainerPort: &hostPort,
-			HostPort:      &hostPort,
-			Protocol:      aws.String("tcp"),
-		}
-
-		// Map container port, if defined
-		if len(envArr) > 1 {
-			containerPort, _ := strconv.ParseInt(envArr[1], 10, 64)
-			portMap.ContainerPort = &containerPort
-		}
-
-		// Override tcp protocol with whatever is provided (Should just be "udp")
-		if len(envArr) > 2 {
-			portMap.Protocol = aws.String(envArr[3])
-		}
-
-		// Append to the slice
-		k = append(k, &portMap)
-	}
-
-	return k
-}
-
-func buildMountPoint(volumes []string, efsVolumes []string) (v []*ecs.Volume, k []*ecs.MountPoint) {
+func buildMountPoint(volumes []string, efsVolumes []string) (v []*ecs.Volume, k []*ecs.MountPoint, err error) {
 	if len(volumes) < 1 && len(efsVolumes) < 1 {
-		return []*ecs.Volume{}, []*ecs.MountPoint{}
+		return []*ecs.Volume{}, []*ecs.MountPoint{}, nil
 	}
 
 	// Add Bind Mounts
@@ -147,6 +81,9 @@ func buildMountPoint(volumes []string, efsVolumes []string) (v []*ecs.Volume, k
 	// Add EFS Mounts
 	for i, volume := range efsVolumes {
 		av := strings.Split(volume, ":")
+		if len(av) != 3 {
+			return nil, nil, fmt.Errorf("invalid efs volume %q, expected FILESYSTEM:ROOT_DIRECTORY:CONTAINER_PATH", volume)
+		}
 		efsFileSystemId := av[0]
 		efsDirectory := av[1]
 		containerDirectory := av[2]
@@ -175,12 +112,15 @@ func buildMountPoint(volumes []string, efsVolumes []string) (v []*ecs.Volume, k
 	return
 }
 
-func buildTags(tag []string) (tags []*ecs.Tag) {
+func buildTags(tag []string) (tags []*ecs.Tag, err error) {
 	if len(tag) < 1 {
-		return []*ecs.Tag{}
+		return []*ecs.Tag{}, nil
 	}
 	for _, t := range tag {
-		tagArray := strings.Split(t, "=")
+		tagArray := strings.SplitN(t, "=", 2)
+		if len(tagArray) != 2 {
+			return nil, fmt.Errorf("invalid tag %q, expected KEY=VALUE", t)
+		}
 
 		// Add a new tag to the list
 		tags = append(tags, &ecs.Tag{
@@ -191,51 +131,24 @@ func buildTags(tag []string) (tags []*ecs.Tag) {
 	return
 }
 
-// Log types
-func logCloudWatchEvent(log *cloudwatchlogs.OutputLogEvent) {
-	yellow := color.New(color.FgYellow).SprintFunc()
-	fmt.Printf("%v\t%v\n", yellow(time.Unix(*log.Timestamp/1000, 0)), *log.Message)
-}
-
-func logInfo(s string) {
-	color.Green(s)
-}
-
-func logFatal(s string) {
-	color.Red(s)
-	os.Exit(1)
-}
-
-func logFatalError(e error) {
-	if e != nil {
-		color.Red(e.Error())
-		os.Exit(1)
-	}
-}
-
-func logError(e error) {
-	if e != nil {
-		color.Red(e.Error())
-	}
-}
-
-func logWarning(s string) {
-	color.Yellow(s)
-}
-
-func getEc2InstanceIp(instanceId string) *string {
-	res, err := ec2Client.DescribeInstances(&ec2.DescribeInstancesInput{
+func (c *Client) getEc2InstanceIp(ctx context.Context, instanceId string) (*string, error) {
+	res, err := c.EC2.DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{
 		InstanceIds: aws.StringSlice([]string{instanceId}),
 	})
-	logError(err)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Reservations) == 0 || len(res.Reservations[0].Instances) == 0 {
+		return nil, fmt.Errorf("unable to find EC2 instance %s", instanceId)
+	}
 	if res.Reservations[0].Instances[0].PublicIpAddress != nil {
-		return res.Reservations[0].Instances[0].PublicIpAddress
+		return res.Reservations[0].Instances[0].PublicIpAddress, nil
 	}
-	return res.Reservations[0].Instances[0].PrivateIpAddress
+	return res.Reservations[0].Instances[0].PrivateIpAddress, nil
 }
 
-func getSecurityGroupByName(groupName string) (*string, error) {
-	res, err := ec2Client.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+func (c *Client) getSecurityGroupByName(ctx context.Context, groupName string) (*string, error) {
+	res, err := c.EC2.DescribeSecurityGroupsWithContext(ctx, &ec2.DescribeSecurityGroupsInput{
 		Filters: []*ec2.Filter{
 			{
 				Name:   aws.String("group-name"),
@@ -254,7 +167,7 @@ func getSecurityGroupByName(groupName string) (*string, error) {
 	return nil, fmt.Errorf("unable to find security group with name %s", groupName)
 }
 
-func getSubnetsByFilter(subnetFilters []string) (subnets []*string, err error) {
+func (c *Client) getSubnetsByFilter(ctx context.Context, subnetFilters []string) (subnets []*string, err error) {
 
 	var filters []*ec2.Filter
 
@@ -270,7 +183,7 @@ func getSubnetsByFilter(subnetFilters []string) (subnets []*string, err error) {
 		})
 	}
 
-	result, err := ec2Client.DescribeSubnets(&ec2.DescribeSubnetsInput{
+	result, err := c.EC2.DescribeSubnetsWithContext(ctx, &ec2.DescribeSubnetsInput{
 		Filters: filters,
 	})
 
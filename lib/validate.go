@@ -0,0 +1,436 @@
+package ecs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// Problem is a single reason a task can't be registered or run, located by the path of the
+// offending field
+type Problem struct {
+	Field   string
+	Message string
+}
+
+func (p Problem) String() string {
+	return p.Field + ": " + p.Message
+}
+
+// ValidationError collects every problem found by Validate
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := []string{fmt.Sprintf("%d problem(s) found:", len(e.Problems))}
+	for _, p := range e.Problems {
+		lines = append(lines, "  "+p.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// fargateMemory lists the memory allowed for each Fargate CPU value, in MiB
+var fargateMemory = map[int64]memoryRange{
+	256:   {values: []int64{512, 1024, 2048}},
+	512:   {min: 1024, max: 4096, step: 1024},
+	1024:  {min: 2048, max: 8192, step: 1024},
+	2048:  {min: 4096, max: 16384, step: 1024},
+	4096:  {min: 8192, max: 30720, step: 1024},
+	8192:  {min: 16384, max: 61440, step: 4096},
+	16384: {min: 32768, max: 122880, step: 8192},
+}
+
+// memoryRange is either a list of values or a range with a step
+type memoryRange struct {
+	values         []int64
+	min, max, step int64
+}
+
+func (r memoryRange) allows(memory int64) bool {
+	if len(r.values) > 0 {
+		for _, value := range r.values {
+			if memory == value {
+				return true
+			}
+		}
+		return false
+	}
+	return memory >= r.min && memory <= r.max && (memory-r.min)%r.step == 0
+}
+
+func (r memoryRange) String() string {
+	if len(r.values) > 0 {
+		values := make([]string, len(r.values))
+		for i, value := range r.values {
+			values[i] = strconv.FormatInt(value, 10)
+		}
+		return "one of " + strings.Join(values[:len(values)-1], ", ") + " or " + values[len(values)-1] + " MiB"
+	}
+	return fmt.Sprintf("%d-%d MiB in steps of %d", r.min, r.max, r.step)
+}
+
+type validator struct {
+	problems []Problem
+}
+
+func (v *validator) add(field, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks a task definition and the request to run it for everything AWS would reject,
+// taking the launch type into account, and returns all problems at once
+func Validate(taskDef *ecs.RegisterTaskDefinitionInput, run *ecs.RunTaskInput) error {
+	v := &validator{}
+	fargate := isFargate(taskDef, run)
+	awsvpc := aws.StringValue(taskDef.NetworkMode) == ecs.NetworkModeAwsvpc
+
+	v.validateContainers(taskDef, awsvpc)
+	v.validateVolumes(taskDef, fargate)
+	v.validateTags(taskDef.Tags)
+
+	if fargate {
+		v.validateFargate(taskDef, run)
+	} else if taskDef.EphemeralStorage != nil {
+		v.add("ephemeralStorage", "ephemeral storage can only be configured on Fargate")
+	}
+
+	v.validateRuntimePlatform(taskDef, run, fargate)
+
+	if run != nil {
+		if count := aws.Int64Value(run.Count); count < 1 || count > 10 {
+			v.add("count", "must be between 1 and 10, got %d", count)
+		}
+		if awsvpc && (run.NetworkConfiguration == nil || run.NetworkConfiguration.AwsvpcConfiguration == nil || len(run.NetworkConfiguration.AwsvpcConfiguration.Subnets) == 0) {
+			v.add("networkConfiguration.awsvpcConfiguration.subnets", "the awsvpc network mode requires at least one subnet")
+		}
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+func isFargate(taskDef *ecs.RegisterTaskDefinitionInput, run *ecs.RunTaskInput) bool {
+	for _, c := range taskDef.RequiresCompatibilities {
+		if aws.StringValue(c) == ecs.CompatibilityFargate {
+			return true
+		}
+	}
+	if run == nil {
+		return false
+	}
+	if aws.StringValue(run.LaunchType) == ecs.LaunchTypeFargate {
+		return true
+	}
+	for _, item := range run.CapacityProviderStrategy {
+		if strings.HasPrefix(aws.StringValue(item.CapacityProvider), "FARGATE") {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *validator) validateContainers(taskDef *ecs.RegisterTaskDefinitionInput, awsvpc bool) {
+	if len(taskDef.ContainerDefinitions) == 0 {
+		v.add("containerDefinitions", "at least one container is required")
+		return
+	}
+
+	names := map[string]bool{}
+	volumes := map[string]bool{}
+	for _, volume := range taskDef.Volumes {
+		volumes[aws.StringValue(volume.Name)] = true
+	}
+	taskMemory := parseMemory(aws.StringValue(taskDef.Memory))
+	essential := false
+	totalMemory := int64(0)
+
+	for i, def := range taskDef.ContainerDefinitions {
+		path := fmt.Sprintf("containerDefinitions[%d]", i)
+		name := aws.StringValue(def.Name)
+
+		if name == "" {
+			v.add(path+".name", "is required")
+		} else if names[name] {
+			v.add(path+".name", "duplicate container name %s", name)
+		}
+		names[name] = true
+
+		if aws.StringValue(def.Image) == "" {
+			v.add(path+".image", "is required")
+		}
+
+		if aws.BoolValue(def.Essential) {
+			essential = true
+		}
+
+		memory := aws.Int64Value(def.Memory)
+		reservation := aws.Int64Value(def.MemoryReservation)
+		if memory > 0 && reservation > memory {
+			v.add(path+".memoryReservation", "%d MiB is above the memory limit of %d MiB", reservation, memory)
+		}
+		if memory == 0 && reservation == 0 && taskMemory == 0 {
+			v.add(path+".memory", "a memory limit or reservation is required when the task has no memory")
+		}
+		if memory > 0 {
+			totalMemory += memory
+		} else {
+			totalMemory += reservation
+		}
+
+		v.validatePortMappings(path, def.PortMappings, awsvpc)
+
+		for j, mount := range def.MountPoints {
+			source := aws.StringValue(mount.SourceVolume)
+			if !volumes[source] {
+				v.add(fmt.Sprintf("%s.mountPoints[%d].sourceVolume", path, j), "unknown volume %s", source)
+			}
+			if !strings.HasPrefix(aws.StringValue(mount.ContainerPath), "/") {
+				v.add(fmt.Sprintf("%s.mountPoints[%d].containerPath", path, j), "must be an absolute path, got %q", aws.StringValue(mount.ContainerPath))
+			}
+		}
+	}
+
+	if !essential {
+		v.add("containerDefinitions", "at least one container must be essential")
+	}
+
+	if taskMemory > 0 && totalMemory > taskMemory {
+		v.add("memory", "containers reserve %d MiB, more than the task's %d MiB", totalMemory, taskMemory)
+	}
+
+	for i, def := range taskDef.ContainerDefinitions {
+		for j, dependency := range def.DependsOn {
+			other := aws.StringValue(dependency.ContainerName)
+			if other == aws.StringValue(def.Name) {
+				v.add(fmt.Sprintf("containerDefinitions[%d].dependsOn[%d]", i, j), "a container can't depend on itself")
+			} else if !names[other] {
+				v.add(fmt.Sprintf("containerDefinitions[%d].dependsOn[%d]", i, j), "unknown container %s", other)
+			}
+		}
+	}
+}
+
+func (v *validator) validatePortMappings(path string, mappings []*ecs.PortMapping, awsvpc bool) {
+	names := map[string]bool{}
+	for i, mapping := range mappings {
+		field := fmt.Sprintf("%s.portMappings[%d]", path, i)
+
+		containerPort := aws.Int64Value(mapping.ContainerPort)
+		if mapping.ContainerPortRange == nil && (containerPort < 1 || containerPort > 65535) {
+			v.add(field+".containerPort", "must be between 1 and 65535, got %d", containerPort)
+		}
+
+		hostPort := aws.Int64Value(mapping.HostPort)
+		if hostPort < 0 || hostPort > 65535 {
+			v.add(field+".hostPort", "must be between 0 and 65535, got %d", hostPort)
+		}
+		if awsvpc && hostPort != 0 && hostPort != containerPort {
+			v.add(field+".hostPort", "must equal the container port %d in the awsvpc network mode, got %d", containerPort, hostPort)
+		}
+
+		protocol := aws.StringValue(mapping.Protocol)
+		if protocol != "" && protocol != ecs.TransportProtocolTcp && protocol != ecs.TransportProtocolUdp {
+			v.add(field+".protocol", "must be tcp or udp, got %q", protocol)
+		}
+
+		if name := aws.StringValue(mapping.Name); name != "" {
+			if names[name] {
+				v.add(field+".name", "duplicate port mapping name %s", name)
+			}
+			names[name] = true
+		}
+	}
+}
+
+func (v *validator) validateVolumes(taskDef *ecs.RegisterTaskDefinitionInput, fargate bool) {
+	names := map[string]bool{}
+	for i, volume := range taskDef.Volumes {
+		path := fmt.Sprintf("volumes[%d]", i)
+		name := aws.StringValue(volume.Name)
+		if names[name] {
+			v.add(path+".name", "duplicate volume name %s", name)
+		}
+		names[name] = true
+
+		if fargate && volume.Host != nil && volume.Host.SourcePath != nil {
+			v.add(path+".host.sourcePath", "bind mounts of host paths are not supported on Fargate")
+		}
+		if fargate && volume.DockerVolumeConfiguration != nil {
+			v.add(path+".dockerVolumeConfiguration", "docker volumes are not supported on Fargate")
+		}
+
+		if efs := volume.EfsVolumeConfiguration; efs != nil {
+			if aws.StringValue(efs.FileSystemId) == "" {
+				v.add(path+".efsVolumeConfiguration.fileSystemId", "is required")
+			}
+			if dir := aws.StringValue(efs.RootDirectory); dir != "" && !strings.HasPrefix(dir, "/") {
+				v.add(path+".efsVolumeConfiguration.rootDirectory", "must be an absolute path, got %q", dir)
+			}
+		}
+	}
+}
+
+func (v *validator) validateTags(tags []*ecs.Tag) {
+	keys := map[string]bool{}
+	for i, tag := range tags {
+		key := aws.StringValue(tag.Key)
+		if key == "" {
+			v.add(fmt.Sprintf("tags[%d].key", i), "is required")
+		} else if keys[key] {
+			v.add(fmt.Sprintf("tags[%d].key", i), "duplicate tag %s", key)
+		}
+		keys[key] = true
+	}
+}
+
+func (v *validator) validateFargate(taskDef *ecs.RegisterTaskDefinitionInput, run *ecs.RunTaskInput) {
+	if aws.StringValue(taskDef.NetworkMode) != ecs.NetworkModeAwsvpc {
+		v.add("networkMode", "Fargate requires the awsvpc network mode")
+	}
+
+	cpu := parseCPU(aws.StringValue(taskDef.Cpu))
+	memory := parseMemory(aws.StringValue(taskDef.Memory))
+	allowed, ok := fargateMemory[cpu]
+	switch {
+	case !ok:
+		v.add("cpu", "%q is not a Fargate CPU value, expected one of 256, 512, 1024, 2048, 4096, 8192 or 16384", aws.StringValue(taskDef.Cpu))
+	case !allowed.allows(memory):
+		v.add("memory", "%q is not supported with %d CPU units on Fargate, expected %s. See https://docs.aws.amazon.com/AmazonECS/latest/developerguide/task-cpu-memory-error.html", aws.StringValue(taskDef.Memory), cpu, allowed)
+	}
+
+	if storage := taskDef.EphemeralStorage; storage != nil {
+		if size := aws.Int64Value(storage.SizeInGiB); size < 21 || size > 200 {
+			v.add("ephemeralStorage.sizeInGiB", "must be between 21 and 200 GiB on Fargate, got %d", size)
+		}
+	}
+
+	for i, def := range taskDef.ContainerDefinitions {
+		lc := def.LogConfiguration
+		if lc != nil && aws.StringValue(lc.LogDriver) == ecs.LogDriverAwslogs && aws.StringValue(taskDef.ExecutionRoleArn) == "" {
+			v.add(fmt.Sprintf("containerDefinitions[%d].logConfiguration", i), "the awslogs driver requires an execution role on Fargate")
+		}
+		if aws.BoolValue(def.Privileged) {
+			v.add(fmt.Sprintf("containerDefinitions[%d].privileged", i), "privileged containers are not supported on Fargate")
+		}
+		v.validateFargateContainer(fmt.Sprintf("containerDefinitions[%d]", i), def)
+	}
+
+	if run != nil && aws.StringValue(run.LaunchType) == ecs.LaunchTypeEc2 {
+		v.add("launchType", "a Fargate task definition can't run on the EC2 launch type")
+	}
+}
+
+// validateFargateContainer rejects the docker run options Fargate and its awsvpc network mode
+// can't honour
+func (v *validator) validateFargateContainer(path string, def *ecs.ContainerDefinition) {
+	if def.Hostname != nil {
+		v.add(path+".hostname", "not supported in the awsvpc network mode used by Fargate")
+	}
+	if len(def.ExtraHosts) > 0 {
+		v.add(path+".extraHosts", "not supported in the awsvpc network mode used by Fargate")
+	}
+	if len(def.DnsServers) > 0 {
+		v.add(path+".dnsServers", "not supported in the awsvpc network mode used by Fargate")
+	}
+	if timeout := aws.Int64Value(def.StopTimeout); timeout > 120 {
+		v.add(path+".stopTimeout", "can be at most 120 seconds on Fargate, got %d", timeout)
+	}
+
+	linux := def.LinuxParameters
+	if linux == nil {
+		return
+	}
+	if len(linux.Tmpfs) > 0 {
+		v.add(path+".linuxParameters.tmpfs", "tmpfs mounts are not supported on Fargate")
+	}
+	if linux.SharedMemorySize != nil {
+		v.add(path+".linuxParameters.sharedMemorySize", "not supported on Fargate")
+	}
+	if linux.Capabilities != nil {
+		for j, capability := range linux.Capabilities.Add {
+			if aws.StringValue(capability) != "SYS_PTRACE" {
+				v.add(fmt.Sprintf("%s.linuxParameters.capabilities.add[%d]", path, j), "only SYS_PTRACE can be added on Fargate, got %s", aws.StringValue(capability))
+			}
+		}
+	}
+}
+
+func (v *validator) validateRuntimePlatform(taskDef *ecs.RegisterTaskDefinitionInput, run *ecs.RunTaskInput, fargate bool) {
+	platform := taskDef.RuntimePlatform
+	if platform == nil {
+		return
+	}
+
+	architecture := aws.StringValue(platform.CpuArchitecture)
+	if architecture != "" && architecture != ecs.CPUArchitectureX8664 && architecture != ecs.CPUArchitectureArm64 {
+		v.add("runtimePlatform.cpuArchitecture", "must be X86_64 or ARM64, got %q", architecture)
+	}
+
+	if architecture != ecs.CPUArchitectureArm64 || !fargate {
+		return
+	}
+
+	if family := aws.StringValue(platform.OperatingSystemFamily); family != "" && family != ecs.OSFamilyLinux {
+		v.add("runtimePlatform.operatingSystemFamily", "ARM64 is only supported for LINUX on Fargate, got %s", family)
+	}
+	if run != nil {
+		if version := aws.StringValue(run.PlatformVersion); version != "" && version != "LATEST" && compareVersions(version, "1.4.0") < 0 {
+			v.add("platformVersion", "ARM64 requires Fargate platform version 1.4.0 or later, got %s", version)
+		}
+	}
+}
+
+// compareVersions compares dotted versions numerically, eg 1.10.0 is after 1.4.0
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// parseCPU reads a task CPU value in units, eg 1024 or "1 vCPU"
+func parseCPU(s string) int64 {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if strings.HasSuffix(s, "vcpu") {
+		f, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, "vcpu")), 64)
+		if err != nil {
+			return 0
+		}
+		return int64(f * 1024)
+	}
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
+
+// parseMemory reads a task memory value in MiB, eg 2048 or "2 GB"
+func parseMemory(s string) int64 {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if strings.HasSuffix(s, "gb") {
+		f, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, "gb")), 64)
+		if err != nil {
+			return 0
+		}
+		return int64(f * 1024)
+	}
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
@@ -0,0 +1,217 @@
+package ecs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+func fargateTaskDefinition() *ecs.RegisterTaskDefinitionInput {
+	return &ecs.RegisterTaskDefinitionInput{
+		Family:                  aws.String("app"),
+		RequiresCompatibilities: aws.StringSlice([]string{ecs.CompatibilityFargate}),
+		NetworkMode:             aws.String(ecs.NetworkModeAwsvpc),
+		ExecutionRoleArn:        aws.String("arn:aws:iam::123456789012:role/execution"),
+		Cpu:                     aws.String("256"),
+		Memory:                  aws.String("512"),
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				Name:         aws.String("app"),
+				Image:        aws.String("nginx"),
+				Essential:    aws.Bool(true),
+				PortMappings: []*ecs.PortMapping{{ContainerPort: aws.Int64(80), HostPort: aws.Int64(80)}},
+			},
+		},
+	}
+}
+
+func fargateRunTask() *ecs.RunTaskInput {
+	return &ecs.RunTaskInput{
+		Count:      aws.Int64(1),
+		LaunchType: aws.String(ecs.LaunchTypeFargate),
+		NetworkConfiguration: &ecs.NetworkConfiguration{
+			AwsvpcConfiguration: &ecs.AwsVpcConfiguration{Subnets: aws.StringSlice([]string{"subnet-1"})},
+		},
+	}
+}
+
+func problemFields(err error) []string {
+	var fields []string
+	if err == nil {
+		return fields
+	}
+	for _, p := range err.(*ValidationError).Problems {
+		fields = append(fields, p.Field)
+	}
+	return fields
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(fargateTaskDefinition(), fargateRunTask()); err != nil {
+		t.Fatalf("expected a valid task, got: %v", err)
+	}
+
+	taskDef := fargateTaskDefinition()
+	taskDef.Memory = aws.String("4096")
+	taskDef.EphemeralStorage = &ecs.EphemeralStorage{SizeInGiB: aws.Int64(500)}
+	taskDef.ContainerDefinitions[0].PortMappings[0].HostPort = aws.Int64(8080)
+	taskDef.ContainerDefinitions = append(taskDef.ContainerDefinitions, &ecs.ContainerDefinition{
+		Name:        aws.String("app"),
+		Image:       aws.String("envoy"),
+		MountPoints: []*ecs.MountPoint{{SourceVolume: aws.String("volume0"), ContainerPath: aws.String("/data")}},
+	})
+	taskDef.Volumes = []*ecs.Volume{{Name: aws.String("volume0"), Host: &ecs.HostVolumeProperties{SourcePath: aws.String("/data")}}}
+	taskDef.Tags = []*ecs.Tag{{Key: aws.String("team"), Value: aws.String("a")}, {Key: aws.String("team"), Value: aws.String("b")}}
+
+	run := fargateRunTask()
+	run.Count = aws.Int64(11)
+	run.NetworkConfiguration.AwsvpcConfiguration.Subnets = nil
+
+	err := Validate(taskDef, run)
+	expected := []string{
+		"containerDefinitions[0].portMappings[0].hostPort",
+		"containerDefinitions[1].name",
+		"volumes[0].host.sourcePath",
+		"tags[1].key",
+		"memory",
+		"ephemeralStorage.sizeInGiB",
+		"count",
+		"networkConfiguration.awsvpcConfiguration.subnets",
+	}
+	if fields := problemFields(err); strings.Join(fields, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected problems with %v, got:\n%v", expected, err)
+	}
+}
+
+func TestValidateLaunchTypes(t *testing.T) {
+	// the same bind mount and storage are fine on EC2, which has no ephemeral storage setting
+	taskDef := &ecs.RegisterTaskDefinitionInput{
+		ContainerDefinitions: []*ecs.ContainerDefinition{
+			{
+				Name:         aws.String("app"),
+				Image:        aws.String("nginx"),
+				Essential:    aws.Bool(true),
+				Memory:       aws.Int64(512),
+				PortMappings: []*ecs.PortMapping{{ContainerPort: aws.Int64(80), HostPort: aws.Int64(8080)}},
+				MountPoints:  []*ecs.MountPoint{{SourceVolume: aws.String("volume0"), ContainerPath: aws.String("/data")}},
+			},
+		},
+		Volumes: []*ecs.Volume{{Name: aws.String("volume0"), Host: &ecs.HostVolumeProperties{SourcePath: aws.String("/data")}}},
+	}
+	run := &ecs.RunTaskInput{Count: aws.Int64(1), LaunchType: aws.String(ecs.LaunchTypeEc2)}
+	if err := Validate(taskDef, run); err != nil {
+		t.Errorf("got: %v", err)
+	}
+
+	taskDef.EphemeralStorage = &ecs.EphemeralStorage{SizeInGiB: aws.Int64(50)}
+	if fields := problemFields(Validate(taskDef, run)); len(fields) != 1 || fields[0] != "ephemeralStorage" {
+		t.Errorf("expected ephemeral storage to be rejected on EC2, got %v", fields)
+	}
+
+	// ARM64 on Fargate needs platform version 1.4.0
+	fargate := fargateTaskDefinition()
+	fargate.RuntimePlatform = &ecs.RuntimePlatform{CpuArchitecture: aws.String(ecs.CPUArchitectureArm64), OperatingSystemFamily: aws.String(ecs.OSFamilyLinux)}
+	fargateRun := fargateRunTask()
+	fargateRun.PlatformVersion = aws.String("1.3.0")
+	if fields := problemFields(Validate(fargate, fargateRun)); len(fields) != 1 || fields[0] != "platformVersion" {
+		t.Errorf("expected the platform version to be rejected, got %v", fields)
+	}
+	fargateRun.PlatformVersion = aws.String("1.10.0")
+	if err := Validate(fargate, fargateRun); err != nil {
+		t.Errorf("expected a later platform version to be accepted, got %v", err)
+	}
+}
+
+func TestValidateFargateDockerOptions(t *testing.T) {
+	task := &Task{Privileged: true, Hostname: "app", DNS: []string{"10.0.0.2"}, Tmpfs: []string{"/run"}, CapAdd: []string{"NET_ADMIN"}, StopTimeout: 300}
+	def := &ecs.ContainerDefinition{Name: aws.String("app"), Image: aws.String("nginx"), Essential: aws.Bool(true), Memory: aws.Int64(512)}
+	if err := task.applyDockerOptions(def); err != nil {
+		t.Fatal(err)
+	}
+
+	ec2 := &ecs.RegisterTaskDefinitionInput{ContainerDefinitions: []*ecs.ContainerDefinition{def}}
+	if err := Validate(ec2, &ecs.RunTaskInput{Count: aws.Int64(1), LaunchType: aws.String(ecs.LaunchTypeEc2)}); err != nil {
+		t.Errorf("expected EC2 to support every option, got: %v", err)
+	}
+
+	// a Fargate capacity provider has no launch type
+	taskDef := fargateTaskDefinition()
+	taskDef.RequiresCompatibilities = nil
+	taskDef.ContainerDefinitions = []*ecs.ContainerDefinition{def}
+	run := fargateRunTask()
+	run.LaunchType = nil
+	run.CapacityProviderStrategy = []*ecs.CapacityProviderStrategyItem{{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: aws.Int64(1)}}
+	expected := []string{
+		"containerDefinitions[0].privileged",
+		"containerDefinitions[0].hostname",
+		"containerDefinitions[0].dnsServers",
+		"containerDefinitions[0].stopTimeout",
+		"containerDefinitions[0].linuxParameters.tmpfs",
+		"containerDefinitions[0].linuxParameters.capabilities.add[0]",
+	}
+	if fields := problemFields(Validate(taskDef, run)); strings.Join(fields, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected problems with %v, got %v", expected, fields)
+	}
+
+	task = &Task{CapAdd: []string{"SYS_PTRACE"}, Init: true, ReadOnly: true}
+	def = fargateTaskDefinition().ContainerDefinitions[0]
+	if err := task.applyDockerOptions(def); err != nil {
+		t.Fatal(err)
+	}
+	taskDef = fargateTaskDefinition()
+	taskDef.ContainerDefinitions = []*ecs.ContainerDefinition{def}
+	if err := Validate(taskDef, fargateRunTask()); err != nil {
+		t.Errorf("got: %v", err)
+	}
+}
+
+func TestFargateCPUMemory(t *testing.T) {
+	for _, combination := range []struct {
+		cpu, memory string
+		valid       bool
+	}{
+		{"256", "512", true},
+		{"256", "1024", true},
+		{"256", "2048", true},
+		{"256", "1536", false},
+		{"256", "4096", false},
+		{"1 vCPU", "2 GB", true},
+		{"4096", "30720", true},
+		{"8192", "20480", true},
+		{"8192", "18432", false},
+		{"3072", "8192", false},
+	} {
+		taskDef := fargateTaskDefinition()
+		taskDef.Cpu = aws.String(combination.cpu)
+		taskDef.Memory = aws.String(combination.memory)
+		if err := Validate(taskDef, fargateRunTask()); (err == nil) != combination.valid {
+			t.Errorf("%s/%s: expected valid=%v, got: %v", combination.cpu, combination.memory, combination.valid, err)
+		}
+	}
+}
+
+func TestParsePlatform(t *testing.T) {
+	platform, err := parsePlatform("linux/arm64")
+	if err != nil || aws.StringValue(platform.CpuArchitecture) != ecs.CPUArchitectureArm64 || aws.StringValue(platform.OperatingSystemFamily) != ecs.OSFamilyLinux {
+		t.Errorf("unexpected platform %v %v", platform, err)
+	}
+	for _, invalid := range []string{"windows/amd64", "linux/s390x"} {
+		if _, err := parsePlatform(invalid); err == nil {
+			t.Errorf("expected %s to fail", invalid)
+		}
+	}
+}
+
+func TestBuildersRejectMalformedInput(t *testing.T) {
+	if _, err := buildTags([]string{"team"}); err == nil {
+		t.Errorf("expected a tag without a value to fail")
+	}
+	if tags, err := buildTags([]string{"query=a=b"}); err != nil || aws.StringValue(tags[0].Value) != "a=b" {
+		t.Errorf("expected the value to keep its equals sign, got %v %v", tags, err)
+	}
+	if _, _, err := buildMountPoint(nil, []string{"fs-1234:/data"}); err == nil {
+		t.Errorf("expected an efs volume without a container path to fail")
+	}
+}